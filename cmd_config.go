@@ -0,0 +1,81 @@
+package main
+
+import (
+    "fmt"
+    "os"
+    "sort"
+
+    "github-downloader/config"
+)
+
+// runConfig 查看、检查或初始化配置文件
+func runConfig(a *app, args []string) int {
+    fs := newFlagSet("config", "[选项] [show|check|init]",
+        "config show",
+        "config check -conf /path/to/repos.conf",
+        "config init",
+    )
+    configFile := fs.String("conf", a.defaultConfig, "配置文件路径")
+    if code, ok := parseFlags(fs, args); !ok {
+        return code
+    }
+
+    action := "show"
+    if fs.NArg() > 0 {
+        action = fs.Arg(0)
+    }
+
+    switch action {
+    case "init":
+        // 不初始化日志文件，提示信息直接输出到终端
+        generateExampleConfigs(a.execDir)
+        return exitOK
+    case "show", "check":
+    default:
+        return usageError(fs, fmt.Errorf("未知操作: %s", action))
+    }
+
+    repos, issues, err := config.CheckRepos(*configFile)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "加载配置文件失败: %v\n", err)
+        return exitError
+    }
+
+    if action == "show" {
+        for _, r := range repos {
+            host := r.GitLabHost
+            if host == "" {
+                host = "github.com"
+            }
+            proxy := r.Proxy
+            if proxy == "" {
+                proxy = "-"
+            }
+            fmt.Printf("%-7s %-20s %s/%s\t代理: %s", r.Type, host, r.Owner, r.Repo, proxy)
+            for _, key := range sortedKeys(r.Options) {
+                fmt.Printf(" %s=%s", key, r.Options[key])
+            }
+            fmt.Println()
+        }
+    }
+
+    for _, issue := range issues {
+        fmt.Fprintf(os.Stderr, "%s\n", issue)
+    }
+    if action == "check" {
+        fmt.Printf("%s: %d 个有效仓库，%d 个问题\n", *configFile, len(repos), len(issues))
+        if len(issues) > 0 {
+            return exitError
+        }
+    }
+    return exitOK
+}
+
+func sortedKeys(m map[string]string) []string {
+    keys := make([]string, 0, len(m))
+    for k := range m {
+        keys = append(keys, k)
+    }
+    sort.Strings(keys)
+    return keys
+}
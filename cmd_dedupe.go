@@ -0,0 +1,78 @@
+package main
+
+import (
+    "fmt"
+    "os"
+    "sort"
+    "strings"
+
+    "github-downloader/downloader"
+    "github-downloader/logger"
+)
+
+// runDedupe 将已有的下载目录转换为内容寻址存储，相同内容的文件只保留一份
+func runDedupe(a *app, args []string) int {
+    fs := newFlagSet("dedupe", "[选项]",
+        "dedupe -dry-run",
+        "dedupe",
+        "dedupe -top /data/github",
+    )
+    common := a.registerCommon(fs)
+    dryRun := fs.Bool("dry-run", false, "只统计可以节省的空间，不修改任何文件")
+    if code, ok := parseFlags(fs, args); !ok {
+        return code
+    }
+    if fs.NArg() > 0 {
+        return usageError(fs, fmt.Errorf("dedupe 不接受位置参数: %v", fs.Args()))
+    }
+
+    logger.SetConsole(os.Stderr)
+    // 状态库中未变化文件的哈希无需重新计算
+    st, err := downloader.LoadState(*common.topDir)
+    if err != nil {
+        logger.Warn("无法读取状态库: %v", err)
+    }
+    logger.Info("正在扫描: %s", *common.topDir)
+    report, err := downloader.Dedupe(*common.topDir, st, *dryRun)
+    if !*dryRun {
+        if serr := st.Save(); serr != nil {
+            logger.Warn("无法保存状态库: %v", serr)
+        }
+    }
+    printDedupe(report, *dryRun)
+    if err != nil {
+        logger.Error("%v", err)
+        return exitError
+    }
+    if report.Errors > 0 {
+        return exitError
+    }
+    return exitOK
+}
+
+func printDedupe(r downloader.DedupeReport, dryRun bool) {
+    if dryRun {
+        fmt.Printf("检查 %d 个文件，%d 个可以链接到存储，可节省 %s\n", r.Files, r.Linked, downloader.ByteCountIEC(r.Saved))
+        fmt.Printf("已指向存储: %d 个文件，需要放入存储: %d 个文件\n", r.Shared, r.Stored)
+        if r.Orphans > 0 {
+            fmt.Printf("存储中有 %d 个不再被引用的文件（%s）可以清理\n", r.Orphans, downloader.ByteCountIEC(r.Freed))
+        }
+        return
+    }
+    fmt.Printf("检查 %d 个文件，链接 %d 个，节省 %s\n", r.Files, r.Linked, downloader.ByteCountIEC(r.Saved))
+    if len(r.Methods) > 0 {
+        var methods []string
+        for m, n := range r.Methods {
+            methods = append(methods, fmt.Sprintf("%s %d", m, n))
+        }
+        sort.Strings(methods)
+        fmt.Printf("链接方式: %s\n", strings.Join(methods, "，"))
+    }
+    fmt.Printf("已指向存储: %d 个文件，新放入存储: %d 个文件\n", r.Shared, r.Stored)
+    if r.Orphans > 0 {
+        fmt.Printf("清理存储中不再被引用的文件: %d 个，释放 %s\n", r.Orphans, downloader.ByteCountIEC(r.Freed))
+    }
+    if r.Errors > 0 {
+        fmt.Printf("失败: %d 个文件\n", r.Errors)
+    }
+}
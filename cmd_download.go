@@ -0,0 +1,182 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+    "os"
+    "strings"
+
+    "github-downloader/config"
+    "github-downloader/downloader"
+    "github-downloader/logger"
+)
+
+// runDownload 下载单个仓库的 Release
+func runDownload(a *app, args []string) int {
+    fs := newFlagSet("download", "[选项] <所有者>/<仓库名>",
+        "download nginx/nginx",
+        "download -all nginx/nginx",
+        "download -gitlab ryubing/canary",
+        "download -host gitlab.com group/project",
+    )
+    common := a.registerCommon(fs)
+    downloadAll := fs.Bool("all", false, "下载所有 Release（默认仅最新）")
+    isGitLab := fs.Bool("gitlab", false, "GitLab 仓库（默认 GitHub）")
+    host := fs.String("host", "", "GitLab 实例主机名，指定后自动视为 GitLab 仓库 (默认 \"git.ryujinx.app\")")
+    proxy := fs.String("proxy", "", "仅使用指定的加速代理（默认使用代理列表）")
+    filter := registerFilter(fs)
+    verifyMode := fs.String("verify", "", "合并校验文件的严格程度：strict、normal 或 lenient（默认使用配置文件或 normal）")
+    crossCheck := fs.String("crosscheck", "", "对经过代理下载且没有官方哈希的资产进行交叉校验：off、origin、proxy 或 auto")
+    dryRun := fs.Bool("dry-run", false, "只输出下载计划，不下载也不写入任何文件")
+    latestJSON := fs.Bool("latest-json", false, "更新最新版本指针时，在仓库目录中同时写入 latest.json")
+    cas := fs.Bool("cas", false, "将资产放入内容寻址存储（下载目录的 .cas），相同内容的文件只保存一份")
+    quota := registerQuota(fs)
+    workers := registerWorkers(fs)
+    timeouts := registerTimeouts(fs)
+    rate := a.registerRateLimit(fs)
+    summaryFile := fs.String("summary", "", "将运行汇总以 JSON 格式写入该文件")
+    output := registerOutput(fs)
+    if code, ok := parseFlags(fs, args); !ok {
+        return code
+    }
+
+    r, err := parseRepoArgs(fs.Args())
+    if err != nil {
+        return usageError(fs, err)
+    }
+    r.Proxy = *proxy
+    if *isGitLab || *host != "" {
+        r.Type = "gitlab"
+        r.GitLabHost = *host
+    }
+    filter.apply(&r)
+    err = setOption(&r, "verify", *verifyMode, downloader.ParseVerifyMode)
+    if err == nil {
+        err = setOption(&r, "crosscheck", *crossCheck, downloader.ParseCrossCheckMode)
+    }
+    if err != nil {
+        return usageError(fs, err)
+    }
+
+    if *dryRun {
+        return planRepos(a.setupConsole(common), []config.RepoConfig{r}, *downloadAll, 1)
+    }
+
+    if err := output.validate(); err != nil {
+        return usageError(fs, err)
+    }
+    limit, reserve, err := quota.parse()
+    if err != nil {
+        return usageError(fs, err)
+    }
+    if err := workers.validate(); err != nil {
+        return usageError(fs, err)
+    }
+    if err := timeouts.validate(); err != nil {
+        return usageError(fs, err)
+    }
+    rateRules, err := rate.load()
+    if err != nil {
+        return usageError(fs, err)
+    }
+
+    d, err := a.setup(common)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "%v\n", err)
+        return exitError
+    }
+    defer logger.Close()
+    if err := output.attach(d); err != nil {
+        logger.Error("%v", err)
+        return exitError
+    }
+    defer output.close()
+    d.SetCAS(*cas)
+    d.SetLatestJSON(*latestJSON)
+    d.SetQuota(limit, reserve)
+    workers.apply(d)
+    timeouts.apply(d)
+    rate.apply(d, rateRules)
+
+    logger.Info("======== 开始下载指定仓库 ========")
+    logger.Info("下载目录: %s", *common.topDir)
+    logger.Info("代理列表: %s", *common.proxiesFile)
+    logger.Info("日志目录: %s", *common.logDir)
+    logger.Info("指定仓库: %s/%s", r.Owner, r.Repo)
+    logger.Info("仓库类型: %s", map[bool]string{true: "GitLab", false: "GitHub"}[r.Type == "gitlab"])
+    logger.Info("下载模式: %s", map[bool]string{true: "所有 Release", false: "最新 Release"}[*downloadAll])
+
+    ctx, stop := interruptContext()
+    defer stop()
+    if err := processRepo(ctx, d, r, *downloadAll); err != nil {
+        logger.Error("处理仓库 %s/%s 失败: %v", r.Owner, r.Repo, err)
+    }
+
+    logger.Info("======== 仓库处理完成 ========")
+    return finishRun(ctx, d, *summaryFile)
+}
+
+// parseRepoArgs 解析 "<所有者>/<仓库名>" 或 "<所有者> <仓库名>" 形式的位置参数
+// 对于 GitLab 子组（group/subgroup/project），最后一段为仓库名
+func parseRepoArgs(args []string) (config.RepoConfig, error) {
+    r := config.RepoConfig{Type: "github"}
+    switch len(args) {
+    case 1:
+        i := strings.LastIndex(args[0], "/")
+        if i <= 0 || i == len(args[0])-1 {
+            return r, fmt.Errorf("无效的仓库: %q，应为 <所有者>/<仓库名>", args[0])
+        }
+        r.Owner, r.Repo = args[0][:i], args[0][i+1:]
+    case 2:
+        r.Owner, r.Repo = args[0], args[1]
+    default:
+        return r, fmt.Errorf("需要指定一个仓库")
+    }
+    return r, nil
+}
+
+// filterFlags 是资产筛选相关的命令行选项，对应 repos.conf 中的同名选项
+type filterFlags struct {
+    include  *string
+    exclude  *string
+    platform *string
+}
+
+func registerFilter(fs *flag.FlagSet) *filterFlags {
+    return &filterFlags{
+        include:  fs.String("include", "", "只选择匹配的资产，逗号分隔的通配符，例如 \"*.tar.gz,*.zip\""),
+        exclude:  fs.String("exclude", "", "排除匹配的资产，逗号分隔的通配符"),
+        platform: fs.String("platform", "", "只选择指定平台的资产，例如 \"linux/amd64,darwin/arm64\" 或 \"auto\""),
+    }
+}
+
+// apply 用命令行中显式指定的规则覆盖仓库配置中的规则
+func (f *filterFlags) apply(r *config.RepoConfig) {
+    set := func(key, value string) {
+        if value == "" {
+            return
+        }
+        if r.Options == nil {
+            r.Options = make(map[string]string)
+        }
+        r.Options[key] = value
+    }
+    set("include", *f.include)
+    set("exclude", *f.exclude)
+    set("platform", *f.platform)
+}
+
+// setOption 校验并设置命令行中显式指定的仓库选项，value 为空时保持不变
+func setOption(r *config.RepoConfig, key, value string, parse func(string) (string, error)) error {
+    if value == "" {
+        return nil
+    }
+    if _, err := parse(value); err != nil {
+        return err
+    }
+    if r.Options == nil {
+        r.Options = make(map[string]string)
+    }
+    r.Options[key] = value
+    return nil
+}
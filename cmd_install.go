@@ -0,0 +1,172 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+    "os"
+    "path/filepath"
+    "strings"
+
+    "github-downloader/downloader"
+    "github-downloader/logger"
+)
+
+// runInstall 下载仓库的可执行文件并安装到 bin 目录
+func runInstall(a *app, args []string) int {
+    fs := newFlagSet("install", "[选项] <所有者>/<仓库名>[@版本]",
+        "install junegunn/fzf",
+        "install BurntSushi/ripgrep@14.1.1 -name rg",
+        "install -bin /usr/local/bin -path \"*/bin/gh\" cli/cli",
+        "install -platform linux/arm64 sharkdp/fd",
+    )
+    common := a.registerCommon(fs)
+    binDir := registerBinDir(fs)
+    name := fs.String("name", "", "安装后的命令名（默认为仓库名）")
+    member := fs.String("path", "", "归档中可执行文件的路径或文件名，支持通配符（默认使用配置文件中的 bin 或按命令名查找）")
+    isGitLab := fs.Bool("gitlab", false, "GitLab 仓库（默认 GitHub）")
+    host := fs.String("host", "", "GitLab 实例主机名，指定后自动视为 GitLab 仓库 (默认 \"git.ryujinx.app\")")
+    proxy := fs.String("proxy", "", "仅使用指定的加速代理（默认使用代理列表）")
+    filter := registerFilter(fs)
+    verifyMode := fs.String("verify", "", "合并校验文件的严格程度：strict、normal 或 lenient（默认使用配置文件或 normal）")
+    if code, ok := parseFlags(fs, args); !ok {
+        return code
+    }
+
+    // 版本写在最后一个参数的 @ 之后
+    var tag string
+    repoArgs := append([]string(nil), fs.Args()...)
+    if n := len(repoArgs); n > 0 {
+        if i := strings.LastIndex(repoArgs[n-1], "@"); i > 0 {
+            repoArgs[n-1], tag = repoArgs[n-1][:i], repoArgs[n-1][i+1:]
+        }
+    }
+    r, err := parseRepoArgs(repoArgs)
+    if err != nil {
+        return usageError(fs, err)
+    }
+    if *isGitLab || *host != "" {
+        r.Type = "gitlab"
+        r.GitLabHost = *host
+    }
+    // 沿用配置文件中该仓库的筛选规则、校验设置和 bin 选项，命令行中的选项优先
+    if c, ok := findConfigured(*common.configFile, r); ok {
+        r = c
+    }
+    if *proxy != "" {
+        r.Proxy = *proxy
+    }
+    filter.apply(&r)
+    if r.Options["platform"] == "" {
+        // 安装的可执行文件需要能在当前平台运行
+        *filter.platform = "auto"
+        filter.apply(&r)
+    }
+    if err := setOption(&r, "verify", *verifyMode, downloader.ParseVerifyMode); err != nil {
+        return usageError(fs, err)
+    }
+    inst := downloader.InstallOptions{
+        BinDir: *binDir,
+        Name:   *name,
+        Path:   *member,
+    }
+    if inst.Path == "" {
+        inst.Path = r.Options["bin"]
+    }
+
+    d, err := a.setup(common)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "%v\n", err)
+        return exitError
+    }
+    defer logger.Close()
+
+    logger.Info("======== 安装 %s/%s ========", r.Owner, r.Repo)
+    logger.Info("安装目录: %s", inst.BinDir)
+    ctx, stop := interruptContext()
+    defer stop()
+    if err := d.Install(ctx, repoRef(r), tag, repoOptions(r), inst); err != nil {
+        logger.Error("安装 %s/%s 失败: %v", r.Owner, r.Repo, err)
+        if ctx.Err() != nil {
+            return exitInterrupted
+        }
+        return exitError
+    }
+    return exitOK
+}
+
+// runUninstall 删除已安装命令的一个或全部版本
+func runUninstall(a *app, args []string) int {
+    fs := newFlagSet("uninstall", "[选项] <命令名>[@版本]",
+        "uninstall fzf",
+        "uninstall fzf@v0.55.0",
+    )
+    binDir := registerBinDir(fs)
+    if code, ok := parseFlags(fs, args); !ok {
+        return code
+    }
+    if fs.NArg() != 1 {
+        return usageError(fs, fmt.Errorf("需要指定一个命令名"))
+    }
+    name, tag, _ := strings.Cut(fs.Arg(0), "@")
+
+    logger.SetConsole(os.Stderr)
+    if err := downloader.Uninstall(*binDir, name, tag); err != nil {
+        logger.Error("%v", err)
+        return exitError
+    }
+    return exitOK
+}
+
+// runSwitch 将已安装的命令切换到另一个已安装的版本，不指定版本时列出已安装的版本
+func runSwitch(a *app, args []string) int {
+    fs := newFlagSet("switch", "[选项] <命令名> [版本]",
+        "switch fzf",
+        "switch fzf v0.55.0",
+    )
+    binDir := registerBinDir(fs)
+    if code, ok := parseFlags(fs, args); !ok {
+        return code
+    }
+    if fs.NArg() < 1 || fs.NArg() > 2 {
+        return usageError(fs, fmt.Errorf("需要指定命令名和版本"))
+    }
+    name := fs.Arg(0)
+
+    logger.SetConsole(os.Stderr)
+    if fs.NArg() == 1 {
+        installs, err := downloader.LoadInstalls(*binDir)
+        if err != nil {
+            logger.Error("%v", err)
+            return exitError
+        }
+        in := installs[name]
+        if in == nil {
+            logger.Error("没有安装 %s", name)
+            return exitError
+        }
+        fmt.Printf("%s（%s/%s）\n", name, in.Owner, in.Repo)
+        for _, v := range in.Versions {
+            mark := " "
+            if v.Tag == in.Active {
+                mark = "*"
+            }
+            fmt.Printf("  %s %-20s %s  %s\n", mark, v.Tag, v.InstalledAt.Local().Format("2006-01-02 15:04"), v.Asset)
+        }
+        return exitOK
+    }
+    if err := downloader.Switch(*binDir, name, fs.Arg(1)); err != nil {
+        logger.Error("%v", err)
+        return exitError
+    }
+    fmt.Printf("%s -> %s\n", filepath.Join(*binDir, name), fs.Arg(1))
+    return exitOK
+}
+
+// registerBinDir 注册安装目录选项，默认为 ~/.local/bin
+func registerBinDir(fs *flag.FlagSet) *string {
+    def := "bin"
+    if home, err := os.UserHomeDir(); err == nil {
+        def = filepath.Join(home, ".local", "bin")
+    }
+    return fs.String("bin", def, "可执行文件的安装目录")
+}
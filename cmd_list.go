@@ -0,0 +1,194 @@
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "io"
+    "os"
+    "text/tabwriter"
+    "time"
+
+    "github-downloader/config"
+    "github-downloader/downloader"
+    "github-downloader/logger"
+)
+
+// listAsset 和 listRelease 是 list 命令 JSON 输出的结构
+type listAsset struct {
+    Name     string `json:"name"`
+    Size     int64  `json:"size"`
+    Digest   string `json:"digest,omitempty"`
+    URL      string `json:"url"`
+    Selected bool   `json:"selected"`
+    Reason   string `json:"reason,omitempty"` // 未选中的原因
+}
+
+type listRelease struct {
+    Tag         string      `json:"tag"`
+    PublishedAt time.Time   `json:"published_at"`
+    Prerelease  bool        `json:"prerelease"`
+    Assets      []listAsset `json:"assets"`
+}
+
+// runList 查看仓库的 Release 和资产，并标出按筛选规则会下载的资产
+func runList(a *app, args []string) int {
+    fs := newFlagSet("list", "[选项] <所有者>/<仓库名>",
+        "list junegunn/fzf",
+        "list -all -format tsv junegunn/fzf",
+        "list -platform auto -format json junegunn/fzf",
+        "list -gitlab ryubing/canary",
+    )
+    configFile := fs.String("conf", a.defaultConfig, "配置文件路径（用于读取该仓库已配置的筛选规则）")
+    all := fs.Bool("all", false, "列出所有 Release（默认仅最新）")
+    isGitLab := fs.Bool("gitlab", false, "GitLab 仓库（默认 GitHub）")
+    host := fs.String("host", "", "GitLab 实例主机名，指定后自动视为 GitLab 仓库")
+    format := fs.String("format", "table", "输出格式: table, json, tsv")
+    filter := registerFilter(fs)
+    if code, ok := parseFlags(fs, args); !ok {
+        return code
+    }
+    if *format != "table" && *format != "json" && *format != "tsv" {
+        return usageError(fs, fmt.Errorf("不支持的输出格式: %s", *format))
+    }
+
+    r, err := parseRepoArgs(fs.Args())
+    if err != nil {
+        return usageError(fs, err)
+    }
+    if *isGitLab || *host != "" {
+        r.Type = "gitlab"
+        r.GitLabHost = *host
+    }
+    if configured, ok := findConfigured(*configFile, r); ok {
+        r.Options = configured.Options
+    }
+    filter.apply(&r)
+
+    // 标准输出只用于输出结果，日志写到标准错误，不生成日志文件
+    logger.SetConsole(os.Stderr)
+    d := downloader.NewDownloader("", nil)
+
+    releases, err := d.FetchReleases(context.Background(), repoRef(r), *all)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "获取 %s/%s 的 Release 失败: %v\n", r.Owner, r.Repo, err)
+        return exitError
+    }
+
+    rules := repoOptions(r).Filter
+    out := make([]listRelease, 0, len(releases))
+    for _, rel := range releases {
+        lr := listRelease{
+            Tag:         rel.TagName,
+            PublishedAt: rel.PublishedAt,
+            Prerelease:  rel.Prerelease,
+            Assets:      make([]listAsset, 0, len(rel.Assets)),
+        }
+        chosen := make(map[string]bool)
+        for _, asset := range rules.Select(rel.Assets) {
+            chosen[asset.Name] = true
+        }
+        for _, asset := range rel.Assets {
+            selected, reason := chosen[asset.Name], ""
+            if !selected {
+                _, reason = rules.Match(asset.Name)
+            }
+            lr.Assets = append(lr.Assets, listAsset{
+                Name:     asset.Name,
+                Size:     asset.Size,
+                Digest:   asset.Digest,
+                URL:      asset.BrowserDownloadURL,
+                Selected: selected,
+                Reason:   reason,
+            })
+        }
+        out = append(out, lr)
+    }
+
+    switch *format {
+    case "json":
+        enc := json.NewEncoder(os.Stdout)
+        enc.SetIndent("", "  ")
+        if err := enc.Encode(out); err != nil {
+            fmt.Fprintf(os.Stderr, "输出失败: %v\n", err)
+            return exitError
+        }
+    case "tsv":
+        writeListTSV(os.Stdout, out)
+    default:
+        writeListTable(os.Stdout, out, rules)
+    }
+    return exitOK
+}
+
+// findConfigured 在配置文件中查找同一仓库的配置，用于获取其筛选规则
+func findConfigured(configFile string, r config.RepoConfig) (config.RepoConfig, bool) {
+    repos, err := config.LoadRepos(configFile)
+    if err != nil {
+        return config.RepoConfig{}, false
+    }
+    host := r.GitLabHost
+    if r.Type == "gitlab" && host == "" {
+        host = config.DefaultGitLabHost
+    }
+    for _, c := range repos {
+        if c.Type == r.Type && c.Owner == r.Owner && c.Repo == r.Repo && c.GitLabHost == host {
+            return c, true
+        }
+    }
+    return config.RepoConfig{}, false
+}
+
+func writeListTSV(w io.Writer, releases []listRelease) {
+    fmt.Fprintln(w, "tag\tpublished_at\tprerelease\tasset\tsize\tdigest\tselected")
+    for _, rel := range releases {
+        for _, asset := range rel.Assets {
+            fmt.Fprintf(w, "%s\t%s\t%t\t%s\t%d\t%s\t%t\n",
+                rel.Tag, formatTime(rel.PublishedAt), rel.Prerelease,
+                asset.Name, asset.Size, asset.Digest, asset.Selected)
+        }
+    }
+}
+
+func writeListTable(w io.Writer, releases []listRelease, rules downloader.AssetFilter) {
+    if len(releases) == 0 {
+        fmt.Fprintln(w, "没有可用的 Release")
+        return
+    }
+    for i, rel := range releases {
+        if i > 0 {
+            fmt.Fprintln(w)
+        }
+        flag := ""
+        if rel.Prerelease {
+            flag = "  [预发布]"
+        }
+        fmt.Fprintf(w, "%s  %s%s\n", rel.Tag, formatTime(rel.PublishedAt), flag)
+
+        tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+        selected := 0
+        for _, asset := range rel.Assets {
+            mark := "-"
+            if asset.Selected {
+                mark = "✓"
+                selected++
+            }
+            digest := asset.Digest
+            if digest == "" {
+                digest = "-"
+            }
+            fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%s\n", mark, asset.Name, downloader.ByteCountIEC(asset.Size), digest, asset.Reason)
+        }
+        tw.Flush()
+        if !rules.IsZero() {
+            fmt.Fprintf(w, "  按筛选规则选择 %d/%d 个资产\n", selected, len(rel.Assets))
+        }
+    }
+}
+
+func formatTime(t time.Time) string {
+    if t.IsZero() {
+        return "-"
+    }
+    return t.Local().Format("2006-01-02 15:04")
+}
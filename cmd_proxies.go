@@ -0,0 +1,61 @@
+package main
+
+import (
+    "fmt"
+    "os"
+
+    "github-downloader/logger"
+)
+
+// runProxies 查看或测试加速代理
+func runProxies(a *app, args []string) int {
+    fs := newFlagSet("proxies", "[选项] [list|test]",
+        "proxies",
+        "proxies test",
+        "proxies -proxies /path/to/proxies.txt test",
+    )
+    common := a.registerCommon(fs)
+    if code, ok := parseFlags(fs, args); !ok {
+        return code
+    }
+
+    action := "list"
+    if fs.NArg() > 0 {
+        action = fs.Arg(0)
+    }
+    if action != "list" && action != "test" {
+        return usageError(fs, fmt.Errorf("未知操作: %s", action))
+    }
+
+    d, err := a.setup(common)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "%v\n", err)
+        return exitError
+    }
+    defer logger.Close()
+
+    proxies := d.Proxies()
+    if action == "list" {
+        for _, p := range proxies {
+            fmt.Println(p)
+        }
+        return exitOK
+    }
+
+    // 逐个测试代理
+    failed := 0
+    for _, p := range proxies {
+        elapsed, err := d.ProbeProxy(p)
+        if err != nil {
+            failed++
+            logger.Warn("❌ %-24s 不可用: %v", p, err)
+            continue
+        }
+        logger.Info("✅ %-24s %d ms", p, elapsed.Milliseconds())
+    }
+    logger.Info("共 %d 个代理，%d 个可用", len(proxies), len(proxies)-failed)
+    if failed == len(proxies) {
+        return exitError
+    }
+    return exitOK
+}
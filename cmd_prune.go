@@ -0,0 +1,209 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+    "time"
+
+    "github-downloader/config"
+    "github-downloader/downloader"
+    "github-downloader/logger"
+)
+
+// runPrune 按保留策略清理旧版本
+func runPrune(a *app, args []string) int {
+    fs := newFlagSet("prune", "[选项] [仓库名...]",
+        "prune -dry-run",
+        "prune -keep 5",
+        "prune -keep 3 -keep-days 90 -keep-major fzf",
+        "prune -pin \"v1.*\" -keep 1",
+    )
+    common := a.registerCommon(fs)
+    retention := registerRetention(fs)
+    dryRun := fs.Bool("dry-run", false, "只输出清理计划，不删除任何文件")
+    if code, ok := parseFlags(fs, args); !ok {
+        return code
+    }
+
+    logger.SetConsole(os.Stderr)
+    // 配置文件不存在时只使用命令行中的规则
+    repos, err := config.LoadRepos(*common.configFile)
+    if err != nil && !os.IsNotExist(err) {
+        logger.Error("加载配置文件失败: %v", err)
+        return exitError
+    }
+    st, err := downloader.LoadState(*common.topDir)
+    if err != nil {
+        logger.Warn("无法读取状态库: %v", err)
+    }
+    d := downloader.NewDownloader(*common.topDir, nil)
+    d.SetState(st)
+
+    names := fs.Args()
+    if len(names) == 0 {
+        if names, err = repoDirs(*common.topDir); err != nil {
+            logger.Error("%v", err)
+            return exitError
+        }
+    }
+    plans, total := pruneRepos(d, repos, names, retention, *dryRun)
+    printPrune(plans, total, *dryRun)
+    if total.Errors > 0 {
+        return exitError
+    }
+    return exitOK
+}
+
+// retentionFlags 是命令行中的全局保留规则，仓库配置中的同名选项优先
+type retentionFlags struct {
+    keep      *int
+    keepDays  *int
+    keepMajor *bool
+    pin       *string
+}
+
+func registerRetention(fs *flag.FlagSet) *retentionFlags {
+    return &retentionFlags{
+        keep:      fs.Int("keep", 0, "保留每个仓库最新的 N 个版本"),
+        keepDays:  fs.Int("keep-days", 0, "保留 N 天内发布的版本"),
+        keepMajor: fs.Bool("keep-major", false, "保留每个主版本号的最新版本"),
+        pin:       fs.String("pin", "", "总是保留的版本，逗号分隔的通配符，例如 \"v1.2.3,v2.*\""),
+    }
+}
+
+// retention 返回仓库的保留策略：仓库配置中的 keep、keep-days、keep-major、pin 覆盖命令行中的规则
+func (f *retentionFlags) retention(r config.RepoConfig) downloader.Retention {
+    ret := downloader.Retention{
+        Last:  *f.keep,
+        Days:  *f.keepDays,
+        Major: *f.keepMajor,
+    }
+    for _, p := range strings.Split(*f.pin, ",") {
+        if p = strings.TrimSpace(p); p != "" {
+            ret.Pin = append(ret.Pin, p)
+        }
+    }
+    if n, err := strconv.Atoi(r.Options["keep"]); err == nil {
+        ret.Last = n
+    }
+    if n, err := strconv.Atoi(r.Options["keep-days"]); err == nil {
+        ret.Days = n
+    }
+    if v, ok := r.Bool("keep-major"); ok {
+        ret.Major = v
+    }
+    if pin := r.List("pin"); pin != nil {
+        ret.Pin = pin
+    }
+    return ret
+}
+
+// evictionPolicy 返回空间不足时用于腾出空间的保留策略，与 prune 使用相同的规则
+func evictionPolicy(repos []config.RepoConfig, f *retentionFlags) func(string) downloader.Retention {
+    return func(repo string) downloader.Retention {
+        r, _ := configuredByName(repos, repo)
+        return f.retention(r)
+    }
+}
+
+// repoDirs 返回下载目录中的仓库目录名
+func repoDirs(topDir string) ([]string, error) {
+    entries, err := os.ReadDir(topDir)
+    if err != nil {
+        return nil, err
+    }
+    var names []string
+    for _, entry := range entries {
+        if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+            names = append(names, entry.Name())
+        }
+    }
+    return names, nil
+}
+
+// pruneRepos 按保留策略清理各仓库目录中的旧版本，返回每个仓库的清理计划和合计结果
+func pruneRepos(d *downloader.Downloader, repos []config.RepoConfig, names []string, f *retentionFlags, dryRun bool) ([]*downloader.PrunePlan, downloader.PruneResult) {
+    var plans []*downloader.PrunePlan
+    var total downloader.PruneResult
+    now := time.Now()
+    for _, name := range names {
+        r, _ := configuredByName(repos, name)
+        plan, err := d.PlanPrune(name, f.retention(r), now)
+        if err != nil {
+            logger.Warn("无法读取仓库目录 %s: %v", name, err)
+            total.Errors++
+            continue
+        }
+        if len(plan.Versions) == 0 {
+            continue
+        }
+        plans = append(plans, plan)
+        res := d.Prune(plan, dryRun)
+        total.Removed += res.Removed
+        total.Freed += res.Freed
+        total.Shared += res.Shared
+        total.Errors += res.Errors
+    }
+    return plans, total
+}
+
+// printPrune 输出每个仓库的保留和删除的版本
+func printPrune(plans []*downloader.PrunePlan, total downloader.PruneResult, dryRun bool) {
+    skipped := 0
+    for _, plan := range plans {
+        if plan.Retention.IsZero() {
+            skipped++
+            continue
+        }
+        fmt.Printf("%s（保留策略: %s）\n", plan.Repo, plan.Retention)
+        for _, v := range plan.Versions {
+            if v.Keep {
+                fmt.Printf("  ✓ %-20s %s  %10s  %s\n", v.Tag, v.Published.Local().Format("2006-01-02"), downloader.ByteCountIEC(v.Size), v.Reason)
+            } else {
+                fmt.Printf("  ✗ %-20s %s  %10s\n", v.Tag, v.Published.Local().Format("2006-01-02"), downloader.ByteCountIEC(v.Size))
+            }
+        }
+    }
+    if skipped > 0 {
+        fmt.Printf("%d 个仓库未配置保留策略，已跳过\n", skipped)
+    }
+
+    verb, freed := "删除", "释放"
+    if dryRun {
+        verb, freed = "将删除", "可释放"
+    }
+    fmt.Printf("\n共%s %d 个版本，%s %s\n", verb, total.Removed, freed, downloader.ByteCountIEC(total.Freed))
+    if total.Shared > 0 {
+        fmt.Printf("另有 %s 的文件与内容寻址存储共享，不再被其他版本引用的内容在运行 dedupe 时清理\n", downloader.ByteCountIEC(total.Shared))
+    }
+    if total.Errors > 0 {
+        fmt.Printf("失败: %d\n", total.Errors)
+    }
+}
+
+// pruneAfterSync 在同步完成后按保留策略清理配置文件中各仓库的旧版本
+func pruneAfterSync(d *downloader.Downloader, topDir string, repos []config.RepoConfig, f *retentionFlags) {
+    logger.Info("======== 清理旧版本 ========")
+    var names []string
+    seen := make(map[string]bool)
+    for _, r := range repos {
+        if !seen[r.Repo] && dirExists(filepath.Join(topDir, r.Repo)) {
+            seen[r.Repo] = true
+            names = append(names, r.Repo)
+        }
+    }
+    _, total := pruneRepos(d, repos, names, f, false)
+    logger.Info("删除 %d 个旧版本，释放 %s", total.Removed, downloader.ByteCountIEC(total.Freed))
+    if total.Shared > 0 {
+        logger.Info("另有 %s 的文件与内容寻址存储共享，不再被其他版本引用的内容在运行 dedupe 时清理", downloader.ByteCountIEC(total.Shared))
+    }
+}
+
+func dirExists(path string) bool {
+    info, err := os.Stat(path)
+    return err == nil && info.IsDir()
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+    "flag"
+    "io"
+    "reflect"
+    "testing"
+
+    "github-downloader/config"
+    "github-downloader/downloader"
+)
+
+func TestRetentionOverrides(t *testing.T) {
+    tests := []struct {
+        name string
+        args []string
+        opts map[string]string
+        want downloader.Retention
+    }{
+        {"只有命令行", []string{"-keep", "3", "-keep-major"}, nil, downloader.Retention{Last: 3, Major: true}},
+        {"仓库选项覆盖命令行", []string{"-keep", "3", "-keep-major"}, map[string]string{"keep": "5", "keep-major": "off"}, downloader.Retention{Last: 5}},
+        {"keep-major=true", nil, map[string]string{"keep-major": "true"}, downloader.Retention{Major: true}},
+        {"keep-major=1", nil, map[string]string{"keep-major": "1"}, downloader.Retention{Major: true}},
+        {"keep-major=0 覆盖命令行", []string{"-keep-major"}, map[string]string{"keep-major": "0"}, downloader.Retention{}},
+        {"无效的 keep-major 使用命令行", []string{"-keep-major"}, map[string]string{"keep-major": "maybe"}, downloader.Retention{Major: true}},
+        {"pin", []string{"-pin", "v1.*, v2.0.0"}, nil, downloader.Retention{Pin: []string{"v1.*", "v2.0.0"}}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            fs := flag.NewFlagSet("prune", flag.ContinueOnError)
+            fs.SetOutput(io.Discard)
+            f := registerRetention(fs)
+            if err := fs.Parse(tt.args); err != nil {
+                t.Fatal(err)
+            }
+            got := f.retention(config.RepoConfig{Options: tt.opts})
+            if !reflect.DeepEqual(got, tt.want) {
+                t.Errorf("retention() = %+v, want %+v", got, tt.want)
+            }
+        })
+    }
+}
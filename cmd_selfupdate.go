@@ -0,0 +1,105 @@
+package main
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+    "runtime"
+    "strings"
+
+    "github-downloader/downloader"
+    "github-downloader/logger"
+)
+
+// runSelfUpdate 下载并校验本程序的最新版本，替换正在运行的程序
+func runSelfUpdate(a *app, args []string) int {
+    fs := newFlagSet("self-update", "[选项]",
+        "self-update",
+        "self-update -check",
+        "self-update -proxy gh-proxy.com",
+    )
+    proxiesFile := fs.String("proxies", a.defaultProxies, "代理列表文件路径")
+    proxy := fs.String("proxy", "", "仅使用指定的加速代理（默认使用代理列表）")
+    repo := fs.String("repo", selfRepo, "发布本程序的 GitHub 仓库")
+    check := fs.Bool("check", false, "只检查是否有新版本，不下载")
+    force := fs.Bool("force", false, "已是最新版本或当前版本号未知时也下载并替换")
+    crossCheck := fs.String("crosscheck", "auto", "对经过代理下载且没有官方哈希的资产进行交叉校验：off、origin、proxy 或 auto")
+    if code, ok := parseFlags(fs, args); !ok {
+        return code
+    }
+    if fs.NArg() > 0 {
+        return usageError(fs, fmt.Errorf("self-update 不接受位置参数: %v", fs.Args()))
+    }
+    r, err := parseRepoArgs([]string{*repo})
+    if err == nil {
+        r.Proxy = *proxy
+        err = setOption(&r, "crosscheck", *crossCheck, downloader.ParseCrossCheckMode)
+    }
+    if err != nil {
+        return usageError(fs, err)
+    }
+
+    logger.SetConsole(os.Stderr)
+    if unknownVersion(version) && !*force {
+        logger.Error("当前程序的版本号未知（%s），可能是直接 go build 或构建时没有指定 VERSION，无法判断是否需要更新；使用 -force 强制更新到最新版本", version)
+        return exitError
+    }
+    exe, err := os.Executable()
+    if err == nil {
+        exe, err = filepath.EvalSymlinks(exe)
+    }
+    if err != nil {
+        logger.Error("无法获取程序路径: %v", err)
+        return exitError
+    }
+    // 新版本先下载到临时目录，校验通过后再替换
+    tmpDir, err := os.MkdirTemp("", appName+"-update-")
+    if err != nil {
+        logger.Error("%v", err)
+        return exitError
+    }
+    defer os.RemoveAll(tmpDir)
+    d := downloader.NewDownloader(tmpDir, loadProxies(*proxiesFile))
+
+    su := downloader.SelfUpdateOptions{
+        Exe:       exe,
+        Current:   version,
+        Asset:     selfAssetName(),
+        Force:     *force,
+        CheckOnly: *check,
+    }
+    ctx, stop := interruptContext()
+    defer stop()
+    release, updated, err := d.SelfUpdate(ctx, repoRef(r), repoOptions(r), su)
+    if err != nil {
+        logger.Error("更新失败: %v", err)
+        if ctx.Err() != nil {
+            return exitInterrupted
+        }
+        return exitError
+    }
+    switch {
+    case updated:
+        fmt.Printf("已从 %s 更新到 %s\n", version, release.TagName)
+    case *check && !downloader.SameVersion(version, release.TagName):
+        fmt.Printf("有新版本: %s（当前 %s），运行 %s self-update 更新\n", release.TagName, version, filepath.Base(os.Args[0]))
+    default:
+        fmt.Printf("已是最新版本: %s\n", version)
+    }
+    return exitOK
+}
+
+// unknownVersion 判断版本号是否为构建时没有指定时的默认值：直接 go build 为 dev，
+// 手动触发的 CI 构建为 dev-<提交>，旧版 Makefile 默认为 1.0.0
+func unknownVersion(v string) bool {
+    return v == "" || v == "dev" || strings.HasPrefix(v, "dev-") || v == "1.0.0"
+}
+
+// selfAssetName 返回当前平台的发布资产名，与 Makefile 中 build-* 目标的输出文件名一致
+func selfAssetName() string {
+    name := fmt.Sprintf("%s-%s-%s", appName, runtime.GOOS, runtime.GOARCH)
+    if runtime.GOOS == "windows" {
+        name += ".exe"
+    }
+    return name
+}
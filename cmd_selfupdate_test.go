@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestUnknownVersion(t *testing.T) {
+    tests := []struct {
+        version string
+        unknown bool
+    }{
+        {"dev", true},
+        {"dev-1a2b3c4", true},
+        {"", true},
+        {"1.0.0", true},
+        {"1.2.0", false},
+        {"v1.2.0", false},
+        {"2.0.0-rc1", false},
+    }
+    for _, tt := range tests {
+        if got := unknownVersion(tt.version); got != tt.unknown {
+            t.Errorf("unknownVersion(%q) = %v, want %v", tt.version, got, tt.unknown)
+        }
+    }
+}
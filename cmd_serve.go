@@ -0,0 +1,240 @@
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "os"
+    "sync"
+    "time"
+
+    "github-downloader/downloader"
+    "github-downloader/logger"
+)
+
+// runServe 以守护进程方式定时执行 sync，并提供简单的 HTTP 状态接口
+func runServe(a *app, args []string) int {
+    fs := newFlagSet("serve", "[选项]",
+        "serve",
+        "serve -interval 6h -listen 127.0.0.1:8080",
+    )
+    common := a.registerCommon(fs)
+    concurrent := fs.Int("j", 1, "同时处理的仓库数（下载数由 -workers 限制）")
+    interval := fs.Duration("interval", time.Hour, "同步间隔")
+    listen := fs.String("listen", "127.0.0.1:8080", "HTTP 接口监听地址，为空则不启用")
+    summaryFile := fs.String("summary", "", "每轮同步后将运行汇总以 JSON 格式写入该文件")
+    prune := fs.Bool("prune", false, "每轮同步完成后按保留策略清理旧版本（见 prune 命令）")
+    retention := registerRetention(fs)
+    quota := registerQuota(fs)
+    workers := registerWorkers(fs)
+    timeouts := registerTimeouts(fs)
+    rate := a.registerRateLimit(fs)
+    evict := fs.Bool("evict", false, "超出配额或磁盘空间不足时，按保留策略（-keep 等）删除最旧的版本腾出空间")
+    latestJSON := fs.Bool("latest-json", false, "更新最新版本指针时，在仓库目录中同时写入 latest.json")
+    cas := fs.Bool("cas", false, "将资产放入内容寻址存储（下载目录的 .cas），相同内容的文件只保存一份")
+    output := registerOutput(fs)
+    if code, ok := parseFlags(fs, args); !ok {
+        return code
+    }
+    if *interval <= 0 {
+        fmt.Fprintf(os.Stderr, "同步间隔必须大于 0\n")
+        return exitUsage
+    }
+
+    if err := output.validate(); err != nil {
+        return usageError(fs, err)
+    }
+    limit, reserve, err := quota.parse()
+    if err != nil {
+        return usageError(fs, err)
+    }
+    if err := workers.validate(); err != nil {
+        return usageError(fs, err)
+    }
+    if err := timeouts.validate(); err != nil {
+        return usageError(fs, err)
+    }
+    rateRules, err := rate.load()
+    if err != nil {
+        return usageError(fs, err)
+    }
+
+    d, err := a.setup(common)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "%v\n", err)
+        return exitError
+    }
+    defer logger.Close()
+    if err := output.attach(d); err != nil {
+        logger.Error("%v", err)
+        return exitError
+    }
+    defer output.close()
+    d.SetCAS(*cas)
+    d.SetLatestJSON(*latestJSON)
+    d.SetQuota(limit, reserve)
+    workers.apply(d)
+    timeouts.apply(d)
+    rate.apply(d, rateRules)
+
+    // 收到 SIGINT 或 SIGTERM 时中断正在进行的同步，写入汇总后退出
+    ctx, stop := interruptContext()
+    defer stop()
+
+    s := &server{d: d, trigger: make(chan struct{}, 1)}
+    if *listen != "" {
+        mux := http.NewServeMux()
+        mux.HandleFunc("/status", s.handleStatus)
+        mux.HandleFunc("/sync", s.handleSync)
+        mux.HandleFunc("/limit", s.handleLimit)
+        srv := &http.Server{Addr: *listen, Handler: mux}
+        go func() {
+            logger.Info("HTTP 接口监听: %s", *listen)
+            if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+                logger.Error("HTTP 接口启动失败: %v", err)
+            }
+        }()
+        defer func() {
+            shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+            defer cancel()
+            srv.Shutdown(shutdownCtx)
+        }()
+    }
+
+    logger.Info("======== 守护进程已启动，同步间隔 %s ========", *interval)
+    ticker := time.NewTicker(*interval)
+    defer ticker.Stop()
+    for {
+        s.begin()
+        d.Summary().Reset()
+        // 每轮重新读取代理列表，修改 proxies.txt 无需重启
+        d.SetProxies(loadProxies(*common.proxiesFile))
+        repos, err := loadRepoConfig(*common.configFile)
+        if err == nil {
+            if *evict {
+                d.SetEviction(evictionPolicy(repos, retention))
+            }
+            syncRepos(ctx, d, repos, false, *concurrent)
+            if *prune && ctx.Err() == nil {
+                pruneAfterSync(d, *common.topDir, repos, retention)
+            }
+        }
+        code := finishRun(ctx, d, *summaryFile)
+        if err := logger.CleanOldLogs(logRetentionDays); err != nil {
+            logger.Error("清理旧日志失败: %v", err)
+        }
+        report := d.Summary().Report()
+        s.end(err, &report, time.Now().Add(*interval))
+        if ctx.Err() != nil {
+            return code
+        }
+        logger.Info("======== 本轮同步完成，下次同步: %s ========", s.status().NextRun.Format("2006-01-02 15:04:05"))
+
+        select {
+        case <-ticker.C:
+        case <-s.trigger:
+            logger.Info("收到手动同步请求")
+            ticker.Reset(*interval)
+        case <-ctx.Done():
+            logger.Info("======== 守护进程已停止 ========")
+            return exitOK
+        }
+    }
+}
+
+// serverStatus 是 /status 接口返回的内容
+type serverStatus struct {
+    Running   bool      `json:"running"`
+    Runs      int       `json:"runs"`
+    LastStart time.Time `json:"last_start"`
+    LastEnd   time.Time `json:"last_end"`
+    LastError string    `json:"last_error,omitempty"`
+    NextRun   time.Time `json:"next_run"`
+
+    LastSummary *downloader.SummaryReport `json:"last_summary,omitempty"`
+}
+
+type server struct {
+    mu      sync.Mutex
+    st      serverStatus
+    d       *downloader.Downloader
+    trigger chan struct{}
+}
+
+func (s *server) begin() {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.st.Running = true
+    s.st.LastStart = time.Now()
+}
+
+func (s *server) end(err error, summary *downloader.SummaryReport, next time.Time) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.st.Running = false
+    s.st.Runs++
+    s.st.LastEnd = time.Now()
+    s.st.LastError = ""
+    if err != nil {
+        s.st.LastError = err.Error()
+    }
+    s.st.NextRun = next
+    s.st.LastSummary = summary
+}
+
+func (s *server) status() serverStatus {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return s.st
+}
+
+func (s *server) handleStatus(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(s.status())
+}
+
+// handleSync 请求立即同步；已有请求排队时忽略重复请求
+func (s *server) handleSync(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        http.Error(w, "仅支持 POST", http.StatusMethodNotAllowed)
+        return
+    }
+    select {
+    case s.trigger <- struct{}{}:
+    default:
+    }
+    w.WriteHeader(http.StatusAccepted)
+}
+
+// limitStatus 是 /limit 接口返回的内容
+type limitStatus struct {
+    Rules   []downloader.RateRule `json:"rules"`
+    Current map[string]int64      `json:"current"` // 每个主机当前生效的速度（字节/秒），* 为总速度
+}
+
+// handleLimit 查看限速规则；POST 时将 host（默认 * 即总速度）的速度设为 rate，优先于时间段规则，
+// 立即对正在进行的下载生效，直到 SIGHUP 重新读取限速配置
+func (s *server) handleLimit(w http.ResponseWriter, r *http.Request) {
+    switch r.Method {
+    case http.MethodGet:
+    case http.MethodPost:
+        host := r.FormValue("host")
+        if host == "" {
+            host = downloader.AllHosts
+        }
+        rate, err := parseRate(r.FormValue("rate"))
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+        s.d.SetRateLimit(host, rate)
+        logger.Info("通过 HTTP 接口调整限速: %s %s", host, formatRate(rate))
+    default:
+        http.Error(w, "仅支持 GET 和 POST", http.StatusMethodNotAllowed)
+        return
+    }
+    rules, current := s.d.RateLimits()
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(limitStatus{Rules: rules, Current: current})
+}
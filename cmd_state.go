@@ -0,0 +1,91 @@
+package main
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "text/tabwriter"
+
+    "github-downloader/downloader"
+    "github-downloader/logger"
+)
+
+// runState 查看或重建下载目录中的本地状态库
+func runState(a *app, args []string) int {
+    fs := newFlagSet("state", "[选项] [show|rebuild]",
+        "state",
+        "state rebuild",
+        "state -top /data/github rebuild",
+    )
+    common := a.registerCommon(fs)
+    if code, ok := parseFlags(fs, args); !ok {
+        return code
+    }
+
+    action := "show"
+    if fs.NArg() > 0 {
+        action = fs.Arg(0)
+    }
+    if action != "show" && action != "rebuild" {
+        return usageError(fs, fmt.Errorf("未知操作: %s", action))
+    }
+
+    logger.SetConsole(os.Stderr)
+    path := filepath.Join(*common.topDir, downloader.StateFile)
+    if action == "show" {
+        st, err := downloader.LoadState(*common.topDir)
+        if err != nil {
+            logger.Error("%v", err)
+            return exitError
+        }
+        printState(path, st)
+        return exitOK
+    }
+
+    // 重新计算所有文件的哈希，替换原有的状态库
+    logger.Info("正在重建状态库: %s", path)
+    st, err := downloader.RebuildState(*common.topDir)
+    if err != nil {
+        logger.Error("重建状态库失败: %v", err)
+        return exitError
+    }
+    if err := st.Save(); err != nil {
+        logger.Error("保存状态库失败: %v", err)
+        return exitError
+    }
+    logger.Info("状态库已重建: %d 个仓库，%d 个文件", len(st.Repos), len(st.Files))
+    return exitOK
+}
+
+// printState 输出状态库概况和每个仓库的最新版本
+func printState(path string, st *downloader.State) {
+    fmt.Printf("状态库: %s\n", path)
+    if st.UpdatedAt.IsZero() {
+        fmt.Println("尚未建立（下次同步时自动建立，或运行 state rebuild）")
+        return
+    }
+    fmt.Printf("更新时间: %s\n", st.UpdatedAt.Format("2006-01-02 15:04:05"))
+    fmt.Printf("仓库: %d，文件: %d\n", len(st.Repos), len(st.Files))
+    if len(st.Repos) == 0 {
+        return
+    }
+
+    keys := make([]string, 0, len(st.Repos))
+    for k := range st.Repos {
+        keys = append(keys, k)
+    }
+    sort.Strings(keys)
+    fmt.Println()
+    tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+    fmt.Fprintln(tw, "仓库\t最新版本\t已处理版本\t检查时间")
+    for _, k := range keys {
+        rs := st.Repos[k]
+        checked := "-"
+        if !rs.CheckedAt.IsZero() {
+            checked = rs.CheckedAt.Format("2006-01-02 15:04:05")
+        }
+        fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", k, rs.LastTag, len(rs.Releases), checked)
+    }
+    tw.Flush()
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "os"
+    "sync"
+
+    "github-downloader/config"
+    "github-downloader/downloader"
+    "github-downloader/logger"
+)
+
+// runSync 按配置文件批量下载
+func runSync(a *app, args []string) int {
+    fs := newFlagSet("sync", "[选项]",
+        "sync",
+        "sync -conf /path/to/repos.conf",
+        "sync -j 3",
+        "sync -all -dry-run",
+        "sync -all -prune -keep 5",
+    )
+    common := a.registerCommon(fs)
+    concurrent := fs.Int("j", 1, "同时处理的仓库数（下载数由 -workers 限制）")
+    downloadAll := fs.Bool("all", false, "下载每个仓库的所有 Release（默认仅最新）")
+    dryRun := fs.Bool("dry-run", false, "只输出同步计划，不下载也不写入任何文件")
+    latestJSON := fs.Bool("latest-json", false, "更新最新版本指针时，在仓库目录中同时写入 latest.json")
+    cas := fs.Bool("cas", false, "将资产放入内容寻址存储（下载目录的 .cas），相同内容的文件只保存一份")
+    prune := fs.Bool("prune", false, "同步完成后按保留策略清理旧版本（见 prune 命令）")
+    retention := registerRetention(fs)
+    quota := registerQuota(fs)
+    workers := registerWorkers(fs)
+    timeouts := registerTimeouts(fs)
+    rate := a.registerRateLimit(fs)
+    evict := fs.Bool("evict", false, "超出配额或磁盘空间不足时，按保留策略（-keep 等）删除最旧的版本腾出空间")
+    summaryFile := fs.String("summary", "", "将运行汇总以 JSON 格式写入该文件")
+    output := registerOutput(fs)
+    if code, ok := parseFlags(fs, args); !ok {
+        return code
+    }
+    if fs.NArg() > 0 {
+        return usageError(fs, fmt.Errorf("sync 不接受位置参数: %v", fs.Args()))
+    }
+
+    if *dryRun {
+        d := a.setupConsole(common)
+        repos, err := loadRepoConfig(*common.configFile)
+        if err != nil {
+            return exitError
+        }
+        return planRepos(d, repos, *downloadAll, *concurrent)
+    }
+
+    if err := output.validate(); err != nil {
+        return usageError(fs, err)
+    }
+    limit, reserve, err := quota.parse()
+    if err != nil {
+        return usageError(fs, err)
+    }
+    if err := workers.validate(); err != nil {
+        return usageError(fs, err)
+    }
+    if err := timeouts.validate(); err != nil {
+        return usageError(fs, err)
+    }
+    rateRules, err := rate.load()
+    if err != nil {
+        return usageError(fs, err)
+    }
+
+    d, err := a.setup(common)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "%v\n", err)
+        return exitError
+    }
+    defer logger.Close()
+    if err := output.attach(d); err != nil {
+        logger.Error("%v", err)
+        return exitError
+    }
+    defer output.close()
+    d.SetCAS(*cas)
+    d.SetLatestJSON(*latestJSON)
+    d.SetQuota(limit, reserve)
+    workers.apply(d)
+    timeouts.apply(d)
+    rate.apply(d, rateRules)
+
+    // 使用配置文件模式
+    logger.Info("======== 开始批量下载 ========")
+    logger.Info("下载目录: %s", *common.topDir)
+    logger.Info("配置文件: %s", *common.configFile)
+    logger.Info("代理列表: %s", *common.proxiesFile)
+    logger.Info("日志目录: %s", *common.logDir)
+
+    repos, err := loadRepoConfig(*common.configFile)
+    if err != nil {
+        return exitError
+    }
+    if *evict {
+        d.SetEviction(evictionPolicy(repos, retention))
+    }
+    ctx, stop := interruptContext()
+    defer stop()
+    syncRepos(ctx, d, repos, *downloadAll, *concurrent)
+    if *prune && ctx.Err() == nil {
+        pruneAfterSync(d, *common.topDir, repos, retention)
+    }
+
+    // 清理旧日志
+    logger.Info("======== 清理旧日志 ========")
+    if err := logger.CleanOldLogs(logRetentionDays); err != nil {
+        logger.Error("清理旧日志失败: %v", err)
+    }
+
+    logger.Info("======== 所有仓库处理完成 ========")
+    return finishRun(ctx, d, *summaryFile)
+}
+
+// loadRepoConfig 加载仓库配置，失败时记录日志
+func loadRepoConfig(configFile string) ([]config.RepoConfig, error) {
+    repos, err := config.LoadRepos(configFile)
+    if err != nil {
+        if os.IsNotExist(err) {
+            logger.Error("配置文件 %s 不存在", configFile)
+            logger.Info("请参考程序目录下的 conf/repos.conf.example 文件创建配置文件")
+        } else {
+            logger.Error("加载配置文件失败: %v", err)
+        }
+        return nil, err
+    }
+    if len(repos) == 0 {
+        logger.Warn("配置文件中没有有效的仓库")
+    }
+    return repos, nil
+}
+
+// syncRepos 并发处理每个仓库，单个仓库的失败只记录日志；ctx 被取消后不再开始处理新的仓库
+func syncRepos(ctx context.Context, d *downloader.Downloader, repos []config.RepoConfig, downloadAll bool, concurrent int) {
+    if concurrent < 1 {
+        concurrent = 1
+    }
+
+    // 使用并发处理
+    var wg sync.WaitGroup
+    sem := make(chan struct{}, concurrent)
+
+    for _, repo := range repos {
+        select {
+        case sem <- struct{}{}: // 占用一个槽位
+        case <-ctx.Done():
+        }
+        if ctx.Err() != nil {
+            break
+        }
+        wg.Add(1)
+        go func(r config.RepoConfig) {
+            defer wg.Done()
+            defer func() { <-sem }() // 释放槽位
+
+            if err := processRepo(ctx, d, r, downloadAll); err != nil {
+                if r.Type == "gitlab" {
+                    logger.Error("处理 GitLab 仓库 %s/%s 失败: %v", r.Owner, r.Repo, err)
+                } else {
+                    logger.Error("处理 GitHub 仓库 %s/%s 失败: %v", r.Owner, r.Repo, err)
+                }
+            }
+        }(repo)
+    }
+
+    wg.Wait()
+}
+
+// processRepo 根据仓库类型和下载模式调用对应的处理流程
+func processRepo(ctx context.Context, d *downloader.Downloader, r config.RepoConfig, downloadAll bool) error {
+    opts := repoOptions(r)
+    if r.Type == "gitlab" {
+        if downloadAll {
+            return d.ProcessGitLabRepoAll(ctx, r.GitLabHost, r.Owner, r.Repo, opts)
+        }
+        return d.ProcessGitLabRepo(ctx, r.GitLabHost, r.Owner, r.Repo, opts)
+    }
+    if downloadAll {
+        return d.ProcessRepoAll(ctx, r.Owner, r.Repo, opts)
+    }
+    return d.ProcessRepo(ctx, r.Owner, r.Repo, opts)
+}
+
+// repoOptions 将仓库配置转换为下载器选项
+func repoOptions(r config.RepoConfig) downloader.RepoOptions {
+    return downloader.RepoOptions{
+        Proxy: r.Proxy,
+        Filter: downloader.AssetFilter{
+            Include:   r.List("include"),
+            Exclude:   r.List("exclude"),
+            Platforms: r.List("platform"),
+        },
+        Verify:     r.Options["verify"],
+        CrossCheck: r.Options["crosscheck"],
+        Timeouts: downloader.Timeouts{
+            Connect: r.Duration("connect-timeout"),
+            TLS:     r.Duration("tls-timeout"),
+            Header:  r.Duration("header-timeout"),
+            Idle:    r.Duration("idle-timeout"),
+            Total:   r.Duration("timeout"),
+        },
+        Keys: downloader.TrustedKeys{
+            Dir:      r.Dir,
+            GPG:      r.List("gpg-key"),
+            Minisign: r.List("minisign-key"),
+            Cosign:   r.List("cosign-key"),
+        },
+    }
+}
+
+// repoRef 将仓库配置转换为下载器使用的仓库标识
+func repoRef(r config.RepoConfig) downloader.RepoRef {
+    return downloader.RepoRef{
+        GitLab: r.Type == "gitlab",
+        Host:   r.GitLabHost,
+        Owner:  r.Owner,
+        Repo:   r.Repo,
+    }
+}
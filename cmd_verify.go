@@ -0,0 +1,194 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "strings"
+    "text/tabwriter"
+
+    "github-downloader/config"
+    "github-downloader/downloader"
+    "github-downloader/logger"
+)
+
+// runVerify 审计已下载的文件：重新计算哈希并与校验文件、签名比对
+func runVerify(a *app, args []string) int {
+    fs := newFlagSet("verify", "[选项] [路径]",
+        "verify",
+        "verify /data/github/fzf",
+        "verify -repair",
+        "verify -format json > audit.json",
+    )
+    common := a.registerCommon(fs)
+    repair := fs.Bool("repair", false, "重新下载损坏或缺失的文件（需要仓库在配置文件中或有 manifest.json）")
+    format := fs.String("format", "table", "输出格式: table、json")
+    if code, ok := parseFlags(fs, args); !ok {
+        return code
+    }
+    if fs.NArg() > 1 {
+        return usageError(fs, fmt.Errorf("只能指定一个路径"))
+    }
+    if *format != "table" && *format != "json" {
+        return usageError(fs, fmt.Errorf("不支持的输出格式: %s", *format))
+    }
+    root := *common.topDir
+    if fs.NArg() == 1 {
+        root = fs.Arg(0)
+    }
+
+    d := a.setupConsole(common)
+    dirs, err := downloader.FindVersionDirs(root)
+    if err != nil {
+        logger.Error("读取 %s 失败: %v", root, err)
+        return exitError
+    }
+    if len(dirs) == 0 {
+        logger.Warn("%s 中没有找到版本目录", root)
+        return exitOK
+    }
+
+    // 配置文件用于获取仓库的筛选规则、严格程度、公钥以及修复时的下载地址；不存在时按默认规则审计
+    repos, err := config.LoadRepos(*common.configFile)
+    if err != nil && !os.IsNotExist(err) {
+        logger.Warn("加载配置文件失败: %v", err)
+    }
+
+    // 中断时停止审计其余的版本目录，仍然输出已有的结果
+    ctx, stop := interruptContext()
+    defer stop()
+    var results []downloader.AuditResult
+    for _, dir := range dirs {
+        if ctx.Err() != nil {
+            break
+        }
+        r, found := configuredByDir(repos, dir)
+        opts := repoOptions(r)
+        auditOpts := downloader.AuditOptions{Filter: opts.Filter, Verify: opts.Verify, Keys: opts.Keys}
+        logger.Info("审计: %s", dir)
+        res := d.AuditVersion(dir, auditOpts)
+
+        if *repair && len(res.Damaged()) > 0 {
+            ref := repoRef(r)
+            if !found {
+                // 不在配置文件中的仓库按 manifest.json 记录的来源重新下载
+                if m, err := downloader.ReadManifest(dir); err == nil {
+                    ref, found = m.Ref(), true
+                }
+            }
+            if !found {
+                logger.Warn("配置文件中没有仓库 %s，且没有 manifest.json，无法重新下载", res.Repo)
+            } else if repaired, err := d.RepairFiles(ctx, ref, dir, res.Tag, res.Damaged(), r.Proxy); err != nil {
+                logger.Error("修复 %s 失败: %v", dir, err)
+            } else if len(repaired) > 0 {
+                logger.Info("已重新下载 %d 个文件，重新审计", len(repaired))
+                res = d.AuditVersion(dir, auditOpts)
+            }
+        }
+        results = append(results, res)
+    }
+
+    if *format == "json" {
+        enc := json.NewEncoder(os.Stdout)
+        enc.SetIndent("", "  ")
+        if err := enc.Encode(results); err != nil {
+            logger.Error("%v", err)
+            return exitError
+        }
+    } else {
+        writeAuditTable(os.Stdout, results)
+    }
+
+    if ctx.Err() != nil {
+        return exitInterrupted
+    }
+    for _, res := range results {
+        if !res.OK() {
+            return exitError
+        }
+    }
+    return exitOK
+}
+
+// configuredByDir 查找版本目录所属仓库的配置：有 manifest.json 时按其记录的来源（所有者/仓库）匹配，
+// 否则按上级目录名匹配
+func configuredByDir(repos []config.RepoConfig, dir string) (config.RepoConfig, bool) {
+    if m, err := downloader.ReadManifest(dir); err == nil && m.Owner != "" {
+        ref := m.Ref()
+        for _, r := range repos {
+            if repoRef(r).GitLab == ref.GitLab && strings.EqualFold(r.Owner, ref.Owner) && strings.EqualFold(r.Repo, ref.Repo) {
+                return r, true
+            }
+        }
+        return config.RepoConfig{}, false
+    }
+    return configuredByName(repos, filepath.Base(filepath.Dir(dir)))
+}
+
+// configuredByName 按仓库目录名（仓库名）查找配置；不同所有者的同名仓库无法区分，视为不在配置文件中
+func configuredByName(repos []config.RepoConfig, name string) (config.RepoConfig, bool) {
+    var matched []config.RepoConfig
+    for _, r := range repos {
+        if strings.EqualFold(r.Repo, name) {
+            matched = append(matched, r)
+        }
+    }
+    if len(matched) > 1 {
+        logger.Warn("配置文件中有 %d 个名为 %s 的仓库，无法确定目录所属的仓库，按默认规则处理", len(matched), name)
+    }
+    if len(matched) != 1 {
+        return config.RepoConfig{}, false
+    }
+    return matched[0], true
+}
+
+// writeAuditTable 输出每个版本目录的问题，通过的文件不逐一列出
+func writeAuditTable(w io.Writer, results []downloader.AuditResult) {
+    files, problems, unverified := 0, 0, 0
+    for _, res := range results {
+        files += len(res.Files)
+        unverified += len(res.Unverified)
+        failed := res.Failed()
+        n := len(failed) + len(res.Unexpected)
+        if res.Error != "" {
+            n++
+        }
+        problems += n
+        tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+        if n == 0 {
+            fmt.Fprintf(w, "✓ %s/%s  %d 个文件\n", res.Repo, res.Tag, len(res.Files))
+            writeUnverified(tw, res)
+            tw.Flush()
+            continue
+        }
+
+        fmt.Fprintf(w, "✗ %s/%s  %d 个问题\n", res.Repo, res.Tag, n)
+        for _, fc := range failed {
+            fmt.Fprintf(tw, "    %s\t%s\t%s\n", fc.Name, fc.Status, fc.Error)
+        }
+        for _, name := range res.Unexpected {
+            fmt.Fprintf(tw, "    %s\t%s\t%s\n", name, "unexpected", "没有任何哈希来源，或为残留的临时文件")
+        }
+        if res.Error != "" {
+            fmt.Fprintf(tw, "    %s\t%s\t%s\n", "-", "signature", res.Error)
+        }
+        writeUnverified(tw, res)
+        tw.Flush()
+    }
+    fmt.Fprintf(w, "\n共 %d 个版本目录，%d 个文件，%d 个问题\n", len(results), files, problems)
+    if unverified > 0 {
+        fmt.Fprintf(w, "%d 个文件所在目录没有 manifest.json 且没有哈希来源，未校验\n", unverified)
+    }
+    if problems > 0 {
+        fmt.Fprintln(w, "可以使用 -repair 重新下载损坏或缺失的文件")
+    }
+}
+
+// writeUnverified 以警告列出来源未知的目录中无法校验的文件，这些文件不算问题
+func writeUnverified(tw io.Writer, res downloader.AuditResult) {
+    for _, name := range res.Unverified {
+        fmt.Fprintf(tw, "    %s\t%s\t%s\n", name, "unverified", "目录没有 manifest.json，来源未知，没有哈希来源")
+    }
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+    "os"
+    "path/filepath"
+    "testing"
+
+    "github-downloader/config"
+)
+
+func TestConfiguredByDir(t *testing.T) {
+    repos := []config.RepoConfig{
+        {Type: "github", Owner: "acme", Repo: "tool"},
+        {Type: "github", Owner: "other", Repo: "tool"},
+        {Type: "github", Owner: "acme", Repo: "cli"},
+    }
+    tests := []struct {
+        name     string
+        repo     string // 版本目录的上级目录名
+        manifest string // 为空时不写入 manifest.json
+        owner    string
+        found    bool
+    }{
+        {"manifest 区分同名仓库", "tool", `{"provider":"github","owner":"other","repo":"tool"}`, "other", true},
+        {"manifest 忽略大小写", "cli", `{"provider":"github","owner":"ACME","repo":"CLI"}`, "acme", true},
+        {"manifest 中的所有者不在配置中", "cli", `{"provider":"github","owner":"someone","repo":"cli"}`, "", false},
+        {"manifest 中的平台不同", "cli", `{"provider":"gitlab","owner":"acme","repo":"cli"}`, "", false},
+        {"没有 manifest 时按唯一的目录名匹配", "cli", "", "acme", true},
+        {"没有 manifest 且有多个同名仓库", "tool", "", "", false},
+        {"没有 manifest 且不在配置中", "fzf", "", "", false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            dir := filepath.Join(t.TempDir(), tt.repo, "v1.0.0")
+            if err := os.MkdirAll(dir, 0755); err != nil {
+                t.Fatal(err)
+            }
+            if tt.manifest != "" {
+                if err := os.WriteFile(filepath.Join(dir, "manifest.json"), []byte(tt.manifest), 0644); err != nil {
+                    t.Fatal(err)
+                }
+            }
+            r, ok := configuredByDir(repos, dir)
+            if ok != tt.found || r.Owner != tt.owner {
+                t.Errorf("configuredByDir() = %q, %v, want %q, %v", r.Owner, ok, tt.owner, tt.found)
+            }
+        })
+    }
+}
+
+func TestConfiguredByName(t *testing.T) {
+    repos := []config.RepoConfig{
+        {Owner: "acme", Repo: "tool"},
+        {Owner: "other", Repo: "tool"},
+        {Owner: "acme", Repo: "cli"},
+    }
+    if r, ok := configuredByName(repos, "cli"); !ok || r.Owner != "acme" {
+        t.Errorf("configuredByName(cli) = %+v, %v", r, ok)
+    }
+    if _, ok := configuredByName(repos, "tool"); ok {
+        t.Errorf("同名仓库有多个时不应匹配")
+    }
+    if _, ok := configuredByName(repos, "fzf"); ok {
+        t.Errorf("不在配置中的仓库不应匹配")
+    }
+}
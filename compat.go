@@ -0,0 +1,94 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+    "io"
+    "os"
+)
+
+// runLegacy 兼容旧版命令格式，将其转换为对应的子命令：
+//   [选项]                                  -> sync
+//   [选项] <所有者> <仓库名> [all]           -> download
+//   [选项] gitlab <所有者> <仓库名> [all]    -> download -gitlab
+//   [选项] gitlab <主机名> <所有者> <仓库名> [all] -> download -host <主机名>
+func (a *app) runLegacy(args []string) int {
+    name, cmdArgs, err := translateLegacy(args)
+    if err == flag.ErrHelp {
+        printUsage()
+        return exitOK
+    }
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "%v\n\n", err)
+        printUsage()
+        return exitUsage
+    }
+    return findCommand(name).run(a, cmdArgs)
+}
+
+// translateLegacy 将旧版格式的参数转换为子命令名和子命令的参数，
+// 只转发显式设置过的选项，保留各子命令自己的默认值
+func translateLegacy(args []string) (string, []string, error) {
+    fs := flag.NewFlagSet("legacy", flag.ContinueOnError)
+    fs.SetOutput(io.Discard)
+    fs.String("top", "", "")
+    fs.String("conf", "", "")
+    fs.String("proxies", "", "")
+    fs.String("log", "", "")
+    fs.Int("j", 1, "")
+    help := fs.Bool("h", false, "")
+    if err := fs.Parse(args); err != nil {
+        return "", nil, err
+    }
+    if *help {
+        return "", nil, flag.ErrHelp
+    }
+
+    var forwarded []string
+    jSet := false
+    fs.Visit(func(f *flag.Flag) {
+        if f.Name == "j" {
+            jSet = true
+        }
+        forwarded = append(forwarded, fmt.Sprintf("-%s=%s", f.Name, f.Value.String()))
+    })
+
+    rest := fs.Args()
+    if len(rest) < 2 {
+        return "sync", forwarded, nil
+    }
+    // -j 是同时处理的仓库数，下载单个仓库时没有意义；旧版会静默忽略，这里明确报错，避免误以为生效
+    if jSet {
+        return "", nil, fmt.Errorf("-j 只用于按配置文件批量下载（sync），下载单个仓库时请去掉 -j，" +
+            "调整同时进行的下载数请使用 download -workers")
+    }
+
+    if rest[0] == "gitlab" {
+        switch {
+        case len(rest) == 4 && rest[3] == "all":
+            // gitlab <所有者> <仓库名> all：旧版按参数个数会误判为带主机名的格式
+            forwarded = append(forwarded, "-gitlab", "-all")
+            return "download", append(forwarded, rest[1]+"/"+rest[2]), nil
+        case len(rest) >= 4:
+            // gitlab <主机名> <所有者> <仓库名> [all]
+            forwarded = append(forwarded, "-host="+rest[1])
+            if len(rest) >= 5 && rest[4] == "all" {
+                forwarded = append(forwarded, "-all")
+            }
+            return "download", append(forwarded, rest[2]+"/"+rest[3]), nil
+        case len(rest) >= 3:
+            // gitlab <所有者> <仓库名> [all]（默认 git.ryujinx.app）
+            forwarded = append(forwarded, "-gitlab")
+            if len(rest) >= 4 && rest[3] == "all" {
+                forwarded = append(forwarded, "-all")
+            }
+            return "download", append(forwarded, rest[1]+"/"+rest[2]), nil
+        }
+    }
+
+    // <所有者> <仓库名> [all]
+    if len(rest) >= 3 && rest[2] == "all" {
+        forwarded = append(forwarded, "-all")
+    }
+    return "download", append(forwarded, rest[0], rest[1]), nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+    "flag"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+func TestTranslateLegacy(t *testing.T) {
+    tests := []struct {
+        args     string
+        wantName string
+        wantArgs []string
+    }{
+        {"", "sync", nil},
+        {"-top /data -conf /etc/repos.conf -j 4", "sync", []string{"-conf=/etc/repos.conf", "-j=4", "-top=/data"}},
+        {"-log /var/log/gd -proxies p.txt", "sync", []string{"-log=/var/log/gd", "-proxies=p.txt"}},
+        {"nginx nginx", "download", []string{"nginx", "nginx"}},
+        {"nginx nginx all", "download", []string{"-all", "nginx", "nginx"}},
+        {"-top /data nginx nginx all", "download", []string{"-top=/data", "-all", "nginx", "nginx"}},
+        {"gitlab ryubing canary", "download", []string{"-gitlab", "ryubing/canary"}},
+        {"gitlab ryubing canary all", "download", []string{"-gitlab", "-all", "ryubing/canary"}},
+        {"gitlab git.example.com owner repo", "download", []string{"-host=git.example.com", "owner/repo"}},
+        {"gitlab git.example.com owner repo all", "download", []string{"-host=git.example.com", "-all", "owner/repo"}},
+    }
+    for _, tt := range tests {
+        name, args, err := translateLegacy(strings.Fields(tt.args))
+        if err != nil {
+            t.Errorf("translateLegacy(%q) = %v", tt.args, err)
+            continue
+        }
+        if name != tt.wantName || !reflect.DeepEqual(args, tt.wantArgs) {
+            t.Errorf("translateLegacy(%q) = %s %q, want %s %q", tt.args, name, args, tt.wantName, tt.wantArgs)
+        }
+        if findCommand(name) == nil {
+            t.Errorf("translateLegacy(%q) 返回了不存在的命令 %s", tt.args, name)
+        }
+    }
+}
+
+func TestTranslateLegacyErrors(t *testing.T) {
+    tests := []struct {
+        args string
+        want string // 错误信息中应包含的内容
+    }{
+        {"-j 4 nginx nginx", "-j"},
+        {"-j 2 gitlab ryubing canary all", "-j"},
+        {"-unknown nginx nginx", "unknown"},
+    }
+    for _, tt := range tests {
+        _, _, err := translateLegacy(strings.Fields(tt.args))
+        if err == nil || !strings.Contains(err.Error(), tt.want) {
+            t.Errorf("translateLegacy(%q) = %v, want 包含 %q 的错误", tt.args, err, tt.want)
+        }
+    }
+    if _, _, err := translateLegacy([]string{"-h"}); err != flag.ErrHelp {
+        t.Errorf("translateLegacy(-h) = %v, want flag.ErrHelp", err)
+    }
+}
+
+func TestParseRepoArgs(t *testing.T) {
+    tests := []struct {
+        args        string
+        owner, repo string
+        wantErr     bool
+    }{
+        {"nginx/nginx", "nginx", "nginx", false},
+        {"nginx nginx", "nginx", "nginx", false},
+        {"group/subgroup/project", "group/subgroup", "project", false},
+        {"nginx", "", "", true},
+        {"/nginx", "", "", true},
+        {"nginx/", "", "", true},
+        {"", "", "", true},
+        {"a b c", "", "", true},
+    }
+    for _, tt := range tests {
+        r, err := parseRepoArgs(strings.Fields(tt.args))
+        if (err != nil) != tt.wantErr {
+            t.Errorf("parseRepoArgs(%q) error = %v, wantErr %v", tt.args, err, tt.wantErr)
+            continue
+        }
+        if !tt.wantErr && (r.Owner != tt.owner || r.Repo != tt.repo || r.Type != "github") {
+            t.Errorf("parseRepoArgs(%q) = %s/%s (%s), want %s/%s", tt.args, r.Owner, r.Repo, r.Type, tt.owner, tt.repo)
+        }
+    }
+}
@@ -2,10 +2,17 @@ package config
 
 import (
     "bufio"
+    "fmt"
     "os"
+    "path/filepath"
+    "strconv"
     "strings"
+    "time"
 )
 
+// DefaultGitLabHost 是 gitlab 行未指定主机名时使用的实例
+const DefaultGitLabHost = "git.ryujinx.app"
+
 // RepoConfig 表示一个仓库的配置
 type RepoConfig struct {
     Type       string // 仓库类型：github 或 gitlab
@@ -13,6 +20,143 @@ type RepoConfig struct {
     Repo       string
     Proxy      string // 代理，如果为空则使用默认
     GitLabHost string // GitLab 实例主机名，例如 git.ryujinx.app
+    Options    map[string]string // 行尾的 key=value 选项，例如 include=*.tar.gz
+    Dir        string            // 配置文件所在目录，选项中的相对路径（如公钥文件）基于该目录
+}
+
+// knownOptions 是仓库行支持的 key=value 选项
+var knownOptions = map[string]string{
+    "include":  "只下载匹配的资产，逗号分隔的通配符，例如 include=*.tar.gz,*.zip",
+    "exclude":  "不下载匹配的资产，逗号分隔的通配符，例如 exclude=*.deb",
+    "platform": "只下载指定平台的资产，例如 platform=linux/amd64,darwin/arm64 或 platform=auto",
+    "verify":   "合并校验文件的严格程度：strict、normal（默认）或 lenient，例如 verify=strict",
+    "crosscheck": "对经过代理下载且没有官方哈希的资产进行交叉校验：off（默认）、origin、proxy 或 auto",
+
+    "gpg-key":      "验证 .asc/.sig 签名的 GPG 公钥文件，逗号分隔，例如 gpg-key=keys/fzf.asc",
+    "minisign-key": "验证 .minisig 签名的 minisign 公钥（RW 开头的字符串或公钥文件）",
+    "cosign-key":   "验证 cosign 签名（.sig/.bundle）的 PEM 公钥文件，例如 cosign-key=keys/cosign.pub",
+
+    "keep":       "清理时保留最新的 N 个版本，例如 keep=5",
+    "keep-days":  "清理时保留 N 天内发布的版本，例如 keep-days=90",
+    "keep-major": "清理时保留每个主版本号的最新版本：on/off、true/false 或 1/0，例如 keep-major=on",
+    "pin":        "清理时总是保留的版本，逗号分隔的通配符，例如 pin=v1.2.3,v2.*",
+
+    "bin": "install 时归档中可执行文件的路径或文件名（支持通配符），例如 bin=*/rg",
+
+    "connect-timeout": "建立连接的超时，例如 connect-timeout=10s",
+    "tls-timeout":     "TLS 握手的超时，例如 tls-timeout=10s",
+    "header-timeout":  "等待响应头的超时，例如 header-timeout=30s",
+    "idle-timeout":    "下载停滞的超时：超过该时间没有收到数据时更换代理，例如 idle-timeout=2m",
+    "timeout":         "单次下载一个文件的最长时间，例如 timeout=2h",
+}
+
+// optionValues 是取值有限的选项及其可选值
+var optionValues = map[string][]string{
+    "verify":     {"strict", "normal", "lenient"},
+    "crosscheck": {"off", "origin", "proxy", "auto"},
+}
+
+// boolOptions 是开关选项，取值见 parseBool
+var boolOptions = map[string]bool{
+    "keep-major": true,
+}
+
+// countOptions 是取值为非负整数的选项
+var countOptions = map[string]bool{
+    "keep":      true,
+    "keep-days": true,
+}
+
+// durationOptions 是取值为正的时间长度的选项
+var durationOptions = map[string]bool{
+    "connect-timeout": true,
+    "tls-timeout":     true,
+    "header-timeout":  true,
+    "idle-timeout":    true,
+    "timeout":         true,
+}
+
+// KnownOptions 返回仓库行支持的选项及说明
+func KnownOptions() map[string]string {
+    return knownOptions
+}
+
+// List 返回逗号分隔的选项值列表，选项不存在时返回 nil
+func (r RepoConfig) List(key string) []string {
+    v := r.Options[key]
+    if v == "" {
+        return nil
+    }
+    var items []string
+    for _, item := range strings.Split(v, ",") {
+        if item = strings.TrimSpace(item); item != "" {
+            items = append(items, item)
+        }
+    }
+    return items
+}
+
+// Duration 返回时间长度选项的值，选项不存在或无效时返回 0
+func (r RepoConfig) Duration(key string) time.Duration {
+    v, err := time.ParseDuration(r.Options[key])
+    if err != nil || v < 0 {
+        return 0
+    }
+    return v
+}
+
+// Bool 返回开关选项的值，第二个返回值表示选项存在且有效
+func (r RepoConfig) Bool(key string) (bool, bool) {
+    return parseBool(r.Options[key])
+}
+
+// parseBool 解析开关选项的值：on/off、true/false 或 1/0，不区分大小写
+func parseBool(v string) (bool, bool) {
+    switch strings.ToLower(v) {
+    case "on", "true", "1":
+        return true, true
+    case "off", "false", "0":
+        return false, true
+    }
+    return false, false
+}
+
+func containsValue(values []string, v string) bool {
+    for _, x := range values {
+        if x == v {
+            return true
+        }
+    }
+    return false
+}
+
+// splitOptions 将行中的 key=value 选项与位置字段分开
+func splitOptions(fields []string) ([]string, map[string]string) {
+    var parts []string
+    var opts map[string]string
+    for _, f := range fields {
+        key, value, ok := strings.Cut(f, "=")
+        if !ok || key == "" {
+            parts = append(parts, f)
+            continue
+        }
+        if opts == nil {
+            opts = make(map[string]string)
+        }
+        opts[key] = value
+    }
+    return parts, opts
+}
+
+// ParseIssue 记录配置文件中被跳过的行及原因
+type ParseIssue struct {
+    Line   int
+    Text   string
+    Reason string
+}
+
+func (p ParseIssue) String() string {
+    return fmt.Sprintf("第 %d 行: %s (%s)", p.Line, p.Reason, p.Text)
 }
 
 // LoadRepos 从文件加载仓库配置
@@ -21,14 +165,23 @@ type RepoConfig struct {
 //   gitlab <主机名> 所有者 仓库名 [代理]  // GitLab 仓库（支持自定义实例）
 //   gitlab 所有者 仓库名 [代理]  // GitLab 仓库（默认使用 git.ryujinx.app）
 //   所有者 仓库名 [代理]          // 默认 GitHub 仓库
+// 每行末尾可以附加 key=value 选项（见 KnownOptions），例如：
+//   github junegunn fzf include=*.tar.gz platform=linux/amd64
 func LoadRepos(path string) ([]RepoConfig, error) {
+    repos, _, err := CheckRepos(path)
+    return repos, err
+}
+
+// CheckRepos 与 LoadRepos 相同，但额外返回被跳过的格式错误行
+func CheckRepos(path string) ([]RepoConfig, []ParseIssue, error) {
     file, err := os.Open(path)
     if err != nil {
-        return nil, err
+        return nil, nil, err
     }
     defer file.Close()
 
     var repos []RepoConfig
+    var issues []ParseIssue
     scanner := bufio.NewScanner(file)
     lineNum := 0
 
@@ -40,19 +193,34 @@ func LoadRepos(path string) ([]RepoConfig, error) {
             continue
         }
 
-        parts := strings.Fields(line)
+        parts, opts := splitOptions(strings.Fields(line))
         if len(parts) < 2 {
             // 格式错误，跳过
+            issues = append(issues, ParseIssue{Line: lineNum, Text: line, Reason: "字段不足"})
             continue
         }
 
-        cfg := RepoConfig{}
+        cfg := RepoConfig{Options: opts, Dir: filepath.Dir(path)}
+        for key := range opts {
+            if _, ok := knownOptions[key]; !ok {
+                issues = append(issues, ParseIssue{Line: lineNum, Text: line, Reason: "未知选项 " + key + "，已忽略"})
+            } else if values, ok := optionValues[key]; ok && !containsValue(values, opts[key]) {
+                issues = append(issues, ParseIssue{Line: lineNum, Text: line, Reason: fmt.Sprintf("选项 %s 的值无效，可选 %s", key, strings.Join(values, "、"))})
+            } else if _, ok := parseBool(opts[key]); boolOptions[key] && !ok {
+                issues = append(issues, ParseIssue{Line: lineNum, Text: line, Reason: fmt.Sprintf("选项 %s 的值无效，可选 on/off、true/false 或 1/0", key)})
+            } else if n, err := strconv.Atoi(opts[key]); countOptions[key] && (err != nil || n < 0) {
+                issues = append(issues, ParseIssue{Line: lineNum, Text: line, Reason: fmt.Sprintf("选项 %s 的值必须是非负整数", key)})
+            } else if v, err := time.ParseDuration(opts[key]); durationOptions[key] && (err != nil || v <= 0) {
+                issues = append(issues, ParseIssue{Line: lineNum, Text: line, Reason: fmt.Sprintf("选项 %s 的值必须是时间长度，例如 30s、5m", key)})
+            }
+        }
         
         // 检查是否指定了仓库类型
         if parts[0] == "github" || parts[0] == "gitlab" {
             if parts[0] == "github" {
                 // GitHub 格式：github 所有者 仓库名 [代理]
                 if len(parts) < 3 {
+                    issues = append(issues, ParseIssue{Line: lineNum, Text: line, Reason: "github 需要 所有者 和 仓库名"})
                     continue
                 }
                 cfg.Type = parts[0]
@@ -77,13 +245,14 @@ func LoadRepos(path string) ([]RepoConfig, error) {
                 } else if len(parts) >= 3 {
                     // 格式2: 无主机名，使用默认值
                     cfg.Type = parts[0]
-                    cfg.GitLabHost = "git.ryujinx.app" // 默认值
+                    cfg.GitLabHost = DefaultGitLabHost // 默认值
                     cfg.Owner = parts[1]
                     cfg.Repo = parts[2]
                     if len(parts) >= 4 {
                         cfg.Proxy = parts[3]
                     }
                 } else {
+                    issues = append(issues, ParseIssue{Line: lineNum, Text: line, Reason: "gitlab 需要 所有者 和 仓库名"})
                     continue
                 }
             }
@@ -101,8 +270,8 @@ func LoadRepos(path string) ([]RepoConfig, error) {
     }
 
     if err := scanner.Err(); err != nil {
-        return nil, err
+        return nil, nil, err
     }
 
-    return repos, nil
+    return repos, issues, nil
 }
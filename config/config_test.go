@@ -0,0 +1,51 @@
+package config
+
+import (
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+func TestKeepMajorValues(t *testing.T) {
+    tests := []struct {
+        value string
+        want  bool
+        valid bool
+    }{
+        {"on", true, true},
+        {"ON", true, true},
+        {"true", true, true},
+        {"1", true, true},
+        {"off", false, true},
+        {"false", false, true},
+        {"0", false, true},
+        {"yes-please", false, false},
+        {"", false, false},
+    }
+    for _, tt := range tests {
+        r := RepoConfig{Options: map[string]string{"keep-major": tt.value}}
+        got, ok := r.Bool("keep-major")
+        if got != tt.want || ok != tt.valid {
+            t.Errorf("Bool(keep-major=%q) = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.valid)
+        }
+    }
+}
+
+func TestCheckReposRejectsInvalidKeepMajor(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "repos.txt")
+    content := "github acme tool keep-major=true\ngithub acme cli keep-major=maybe\n"
+    if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+        t.Fatal(err)
+    }
+    repos, issues, err := CheckRepos(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(repos) != 2 {
+        t.Fatalf("repos = %+v", repos)
+    }
+    if len(issues) != 1 || issues[0].Line != 2 || !strings.Contains(issues[0].Reason, "keep-major") {
+        t.Errorf("issues = %v, want 第 2 行 keep-major 的值无效", issues)
+    }
+}
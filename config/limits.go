@@ -0,0 +1,56 @@
+package config
+
+import (
+    "bufio"
+    "fmt"
+    "os"
+    "strings"
+)
+
+// RateLimit 是限速配置文件中的一行
+type RateLimit struct {
+    Line   int
+    Host   string // 主机名，* 表示所有下载的总速度
+    Rate   string // 例如 5MiB/s，0 表示不限速
+    Window string // 生效的时间段，例如 00:00-07:00，为空时全天生效
+}
+
+// LoadRateLimits 从文件读取限速规则，支持 # 注释和空行
+// 格式：
+//   <主机名|*> <速度> [时间段]
+// 例如：
+//   *          5MiB/s
+//   *          0       00:00-07:00   // 夜间不限速
+//   github.com 2MiB/s
+func LoadRateLimits(path string) ([]RateLimit, error) {
+    file, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    defer file.Close()
+
+    var limits []RateLimit
+    scanner := bufio.NewScanner(file)
+    lineNum := 0
+    for scanner.Scan() {
+        lineNum++
+        line := strings.TrimSpace(scanner.Text())
+        // 跳过空行和注释
+        if line == "" || strings.HasPrefix(line, "#") {
+            continue
+        }
+        fields := strings.Fields(line)
+        if len(fields) < 2 || len(fields) > 3 {
+            return nil, fmt.Errorf("%s 第 %d 行: 格式应为 <主机名|*> <速度> [时间段] (%s)", path, lineNum, line)
+        }
+        rl := RateLimit{Line: lineNum, Host: fields[0], Rate: fields[1]}
+        if len(fields) == 3 {
+            rl.Window = fields[2]
+        }
+        limits = append(limits, rl)
+    }
+    if err := scanner.Err(); err != nil {
+        return nil, err
+    }
+    return limits, nil
+}
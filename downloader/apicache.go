@@ -0,0 +1,132 @@
+package downloader
+
+import (
+    "context"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "os"
+    "path/filepath"
+    "time"
+
+    "github-downloader/logger"
+)
+
+// CacheDir 是下载目录中的缓存目录，API 响应缓存在其中的 api 子目录
+const CacheDir = ".cache"
+
+// cachedResponse 是缓存的 API 响应
+type cachedResponse struct {
+    URL       string          `json:"url"`
+    ETag      string          `json:"etag"`
+    FetchedAt time.Time       `json:"fetched_at"`
+    Body      json.RawMessage `json:"body"`
+}
+
+// SetCacheDir 设置缓存目录，为空时不缓存 API 响应
+func (d *Downloader) SetCacheDir(dir string) {
+    d.cacheDir = dir
+}
+
+// cachePath 返回 url 对应的缓存文件
+func (d *Downloader) cachePath(url string) string {
+    sum := sha256.Sum256([]byte(url))
+    return filepath.Join(d.cacheDir, "api", hex.EncodeToString(sum[:])+".json")
+}
+
+// readCache 读取 url 的缓存，不存在或无法解析时返回 nil
+func (d *Downloader) readCache(url string) *cachedResponse {
+    if d.cacheDir == "" {
+        return nil
+    }
+    data, err := os.ReadFile(d.cachePath(url))
+    if err != nil {
+        return nil
+    }
+    var c cachedResponse
+    if err := json.Unmarshal(data, &c); err != nil || c.URL != url || c.ETag == "" {
+        return nil
+    }
+    return &c
+}
+
+// writeCache 写入缓存（先写临时文件再重命名，并发写入同一文件时不会留下不完整的内容）
+func (d *Downloader) writeCache(c *cachedResponse) error {
+    path := d.cachePath(c.URL)
+    if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+        return err
+    }
+    data, err := json.Marshal(c)
+    if err != nil {
+        return err
+    }
+    f, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+    if err != nil {
+        return err
+    }
+    _, err = f.Write(data)
+    if cerr := f.Close(); err == nil {
+        err = cerr
+    }
+    if err != nil {
+        os.Remove(f.Name())
+        return err
+    }
+    return os.Rename(f.Name(), path)
+}
+
+// getJSON 请求 API 并将响应解析到 v
+// 启用缓存时带上次响应的 ETag 发送 If-None-Match，返回 304 时使用缓存的响应（GitHub 不计入速率限制）
+// 整个请求不超过 apiTimeout
+func (d *Downloader) getJSON(ctx context.Context, url string, v interface{}) error {
+    ctx, cancel := context.WithTimeout(ctx, apiTimeout)
+    defer cancel()
+    req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+    if err != nil {
+        return err
+    }
+    req.Header.Set("User-Agent", d.userAgent)
+    // 可选的 GitHub Token：设置 Authorization: token YOUR_TOKEN
+    cached := d.readCache(url)
+    if cached != nil {
+        req.Header.Set("If-None-Match", cached.ETag)
+    }
+
+    resp, err := d.client.Do(req)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode == http.StatusNotModified && cached != nil {
+        logger.Info("Release 信息未变化（304），使用 %s 的缓存", cached.FetchedAt.Format("2006-01-02 15:04:05"))
+        return json.Unmarshal(cached.Body, v)
+    }
+    if resp.StatusCode != http.StatusOK {
+        return fmt.Errorf("API 返回状态码 %d", resp.StatusCode)
+    }
+
+    body, err := io.ReadAll(resp.Body)
+    if err != nil {
+        return err
+    }
+    if err := json.Unmarshal(body, v); err != nil {
+        return err
+    }
+    etag := resp.Header.Get("ETag")
+    switch {
+    case d.cacheDir == "":
+    case etag != "":
+        c := &cachedResponse{URL: url, ETag: etag, FetchedAt: time.Now(), Body: body}
+        if err := d.writeCache(c); err != nil {
+            logger.Warn("无法写入 API 缓存: %v", err)
+        }
+    case cached != nil:
+        // 响应没有 ETag，旧的缓存已经过期
+        os.Remove(d.cachePath(url))
+    }
+    return nil
+}
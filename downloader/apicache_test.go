@@ -0,0 +1,97 @@
+package downloader
+
+import (
+    "context"
+    "net/http"
+    "net/http/httptest"
+    "sync"
+    "testing"
+)
+
+// etagServer 返回当前的 Release，If-None-Match 与当前 ETag 一致时返回 304
+type etagServer struct {
+    *httptest.Server
+    mu          sync.Mutex
+    etag, body  string
+    ifNoneMatch string // 最后一个请求的 If-None-Match
+}
+
+func newETagServer(t *testing.T) *etagServer {
+    es := &etagServer{}
+    es.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        es.mu.Lock()
+        defer es.mu.Unlock()
+        es.ifNoneMatch = r.Header.Get("If-None-Match")
+        if es.etag != "" && es.ifNoneMatch == es.etag {
+            w.WriteHeader(http.StatusNotModified)
+            return
+        }
+        if es.etag != "" {
+            w.Header().Set("ETag", es.etag)
+        }
+        w.Write([]byte(es.body))
+    }))
+    t.Cleanup(es.Close)
+    return es
+}
+
+func (es *etagServer) set(etag, tag string) {
+    es.mu.Lock()
+    defer es.mu.Unlock()
+    es.etag, es.body = etag, `{"tag_name":"`+tag+`"}`
+}
+
+func (es *etagServer) lastIfNoneMatch() string {
+    es.mu.Lock()
+    defer es.mu.Unlock()
+    return es.ifNoneMatch
+}
+
+func TestGetJSONConditionalRequests(t *testing.T) {
+    es := newETagServer(t)
+    d := NewDownloader(t.TempDir(), nil)
+    d.SetCacheDir(t.TempDir())
+    url := es.URL + "/repos/acme/tool/releases/latest"
+
+    steps := []struct {
+        name            string
+        etag, tag       string // 服务器当前的 ETag 和版本，etag 为空表示响应不带 ETag
+        wantIfNoneMatch string
+        wantTag         string
+    }{
+        {"第一次请求没有缓存", `"v1"`, "v1", "", "v1"},
+        {"未变化时使用缓存（304）", `"v1"`, "v1", `"v1"`, "v1"},
+        {"ETag 变化后使用新的响应", `"v2"`, "v2", `"v1"`, "v2"},
+        {"缓存已更新为新的 ETag", `"v2"`, "v2", `"v2"`, "v2"},
+        {"响应没有 ETag 时删除缓存", "", "v3", `"v2"`, "v3"},
+        {"缓存删除后不发送 If-None-Match", `"v4"`, "v4", "", "v4"},
+    }
+    for _, step := range steps {
+        es.set(step.etag, step.tag)
+        var release Release
+        if err := d.getJSON(context.Background(), url, &release); err != nil {
+            t.Fatalf("%s: getJSON() = %v", step.name, err)
+        }
+        if got := es.lastIfNoneMatch(); got != step.wantIfNoneMatch {
+            t.Errorf("%s: If-None-Match = %q, want %q", step.name, got, step.wantIfNoneMatch)
+        }
+        if release.TagName != step.wantTag {
+            t.Errorf("%s: TagName = %q, want %q", step.name, release.TagName, step.wantTag)
+        }
+    }
+}
+
+func TestGetJSONWithoutCache(t *testing.T) {
+    es := newETagServer(t)
+    es.set(`"v1"`, "v1")
+    d := NewDownloader(t.TempDir(), nil)
+    for i := 0; i < 2; i++ {
+        var release Release
+        if err := d.getJSON(context.Background(), es.URL, &release); err != nil || release.TagName != "v1" {
+            t.Fatalf("getJSON() = %v, %q", err, release.TagName)
+        }
+        if got := es.lastIfNoneMatch(); got != "" {
+            t.Errorf("未启用缓存时 If-None-Match = %q", got)
+        }
+    }
+}
@@ -0,0 +1,242 @@
+package downloader
+
+import (
+    "context"
+    "fmt"
+    "io/fs"
+    "os"
+    "path/filepath"
+    "strings"
+
+    "github-downloader/logger"
+)
+
+// releaseNotesFile 是每个版本目录中都会写入的 release notes，用于识别版本目录
+const releaseNotesFile = "release_notes.txt"
+
+// metadataFiles 是版本目录中由下载器生成、不属于资产的文件
+var metadataFiles = map[string]bool{
+    releaseNotesFile: true,
+    signaturesFile:   true,
+    manifestFile:     true,
+}
+
+// AuditOptions 是审计单个版本目录时使用的仓库配置
+type AuditOptions struct {
+    Filter AssetFilter // 用于区分被筛选规则排除的资产和缺失的资产
+    Verify string      // 严格程度，见 VerifyStrict 等
+    Keys   TrustedKeys // 验证签名使用的受信任公钥
+}
+
+// AuditResult 是单个版本目录的审计结果
+type AuditResult struct {
+    Dir        string      `json:"dir"`
+    Repo       string      `json:"repo"`
+    Tag        string      `json:"tag"`
+    Mode       string      `json:"mode"`
+    Files      []FileCheck `json:"files"`
+    Unexpected []string    `json:"unexpected,omitempty"` // 没有任何哈希来源覆盖的文件，以及残留的临时文件
+    // 没有 manifest.json 的目录来源未知（例如早期版本下载或手动放入的文件），
+    // 无法判断哪些文件是多余的，没有哈希来源的文件只作为警告列在 Unverified 中
+    UnknownProvenance bool     `json:"unknown_provenance,omitempty"`
+    Unverified        []string `json:"unverified,omitempty"`
+    Signatures []SigCheck  `json:"signatures,omitempty"`
+    Error      string      `json:"error,omitempty"` // 读取目录或签名校验失败
+}
+
+// Failed 返回按严格程度视为失败的文件校验结果
+func (r AuditResult) Failed() []FileCheck {
+    var failed []FileCheck
+    for _, fc := range r.Files {
+        if checkFailed(fc.Status, r.Mode) {
+            failed = append(failed, fc)
+        }
+    }
+    return failed
+}
+
+// Damaged 返回损坏或缺失、可以通过重新下载修复的文件
+func (r AuditResult) Damaged() []string {
+    var names []string
+    for _, fc := range r.Failed() {
+        if fc.Status == CheckMismatch || fc.Status == CheckMissing {
+            names = append(names, fc.Name)
+        }
+    }
+    return names
+}
+
+// OK 判断版本目录是否通过审计
+func (r AuditResult) OK() bool {
+    return r.Error == "" && len(r.Unexpected) == 0 && len(r.Failed()) == 0
+}
+
+// FindVersionDirs 返回 root 下的所有版本目录（包含 release_notes.txt 的目录），
+// 跳过隐藏目录（隔离目录、缓存和内容寻址存储）
+// root 可以是下载目录、仓库目录或版本目录
+func FindVersionDirs(root string) ([]string, error) {
+    var dirs []string
+    err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+        if err != nil {
+            return err
+        }
+        if !entry.IsDir() {
+            return nil
+        }
+        if path != root && strings.HasPrefix(entry.Name(), ".") {
+            return filepath.SkipDir
+        }
+        if fileExists(filepath.Join(path, releaseNotesFile)) {
+            dirs = append(dirs, path)
+            return filepath.SkipDir
+        }
+        return nil
+    })
+    return dirs, err
+}
+
+// AuditVersion 重新计算版本目录中所有文件的哈希，与合并校验文件、单文件校验文件和签名比对，
+// 报告损坏、缺失和多余的文件；不会修改任何文件
+func (d *Downloader) AuditVersion(dir string, opts AuditOptions) AuditResult {
+    mode, err := ParseVerifyMode(opts.Verify)
+    if err != nil {
+        mode = VerifyNormal
+    }
+    res := AuditResult{
+        Dir:  dir,
+        Repo: filepath.Base(filepath.Dir(dir)),
+        Tag:  filepath.Base(dir),
+        Mode: mode,
+    }
+
+    entries, err := os.ReadDir(dir)
+    if err != nil {
+        res.Error = err.Error()
+        return res
+    }
+    var files []string
+    for _, entry := range entries {
+        // 使用内容寻址存储时文件可能是指向存储的符号链接
+        if entry.Type().IsRegular() || entry.Type()&os.ModeSymlink != 0 {
+            files = append(files, entry.Name())
+        }
+    }
+
+    // 收集期望哈希：manifest.json 中下载时记录的 SHA-256、合并校验文件、单文件校验文件；
+    // 这些文件本身以及签名、元数据不算多余文件
+    cs := newChecksumSet()
+    known := make(map[string]bool)
+    if m, err := ReadManifest(dir); err == nil {
+        if m.Owner != "" {
+            res.Repo = m.Ref().String()
+        }
+        for _, ma := range m.Assets {
+            cs.add(ma.Name, Digest{Algo: "sha256", Hex: ma.SHA256}, manifestFile)
+        }
+    } else {
+        if !os.IsNotExist(err) {
+            logger.Warn("%v", err)
+        }
+        res.UnknownProvenance = true
+    }
+    for _, name := range files {
+        if isChecksumFile(name) {
+            if err := cs.addFile(filepath.Join(dir, name)); err != nil {
+                logger.Warn("%v", err)
+            }
+            known[name] = true
+        }
+    }
+    for _, name := range files {
+        if metadataFiles[name] {
+            known[name] = true
+            continue
+        }
+        if _, _, ok := signatureTarget(name); ok {
+            known[name] = true
+            continue
+        }
+        if target, _, ok := sidecarTarget(name); ok && !known[name] {
+            if dg, err := readSidecar(filepath.Join(dir, name), target); err == nil {
+                cs.add(target, dg, name)
+                known[name] = true
+            }
+        }
+    }
+
+    res.Files = cs.verify(dir)
+    for i := range res.Files {
+        fc := &res.Files[i]
+        if fc.Status == CheckMissing && !opts.Filter.IsZero() {
+            if ok, _ := opts.Filter.Match(fc.Name); !ok {
+                fc.Status, fc.Error = CheckNotSelected, "被筛选规则排除，未下载"
+            }
+        }
+    }
+    for _, name := range files {
+        switch {
+        case strings.HasSuffix(name, ".tmp"):
+            res.Unexpected = append(res.Unexpected, name)
+        case known[name] || cs.has(name):
+        case res.UnknownProvenance:
+            res.Unverified = append(res.Unverified, name)
+        default:
+            res.Unexpected = append(res.Unexpected, name)
+        }
+    }
+    if len(res.Unverified) > 0 {
+        logger.Warn("%s 没有 manifest.json，来源未知，%d 个文件没有哈希来源，无法校验", dir, len(res.Unverified))
+    }
+
+    if sigs, err := checkSignatures(dir, files, opts.Keys, nil); err != nil {
+        res.Signatures, res.Error = sigs, err.Error()
+    } else {
+        res.Signatures = sigs
+    }
+    return res
+}
+
+// RepairFiles 从对应的 Release 重新下载版本目录中损坏或缺失的资产，返回成功修复的文件
+// ctx 被取消时停止修复其余的文件，返回 ctx 的错误
+func (d *Downloader) RepairFiles(ctx context.Context, ref RepoRef, dir, tag string, names []string, proxy string) ([]string, error) {
+    releases, err := d.FetchReleases(ctx, ref, true)
+    if err != nil {
+        return nil, err
+    }
+    var release *Release
+    for _, r := range releases {
+        if r.TagName == tag {
+            release = r
+            break
+        }
+    }
+    if release == nil {
+        return nil, fmt.Errorf("%s 中没有版本 %s", ref, tag)
+    }
+
+    assets := make(map[string]Asset, len(release.Assets))
+    for _, a := range release.Assets {
+        assets[a.Name] = a
+    }
+    var repaired []string
+    for _, name := range names {
+        asset, ok := assets[name]
+        if !ok {
+            logger.Warn("%s 不是版本 %s 的资产，无法重新下载", name, tag)
+            continue
+        }
+        localPath := filepath.Join(dir, name)
+        // 大小相同的损坏文件会被视为完整，需要先删除
+        os.Remove(localPath)
+        logger.Info("重新下载: %s", name)
+        if _, err := d.downloadFileWithProxyList(ctx, ref.String(), asset.BrowserDownloadURL, localPath, asset.Size, officialDigest(asset), proxy, Timeouts{}, nil); err != nil {
+            logger.Error("重新下载 %s 失败: %v", name, err)
+            if ctx.Err() != nil {
+                return repaired, ctx.Err()
+            }
+            continue
+        }
+        repaired = append(repaired, name)
+    }
+    return repaired, nil
+}
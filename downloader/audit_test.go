@@ -0,0 +1,89 @@
+package downloader
+
+import (
+    "path/filepath"
+    "reflect"
+    "testing"
+)
+
+// newVersionDir 创建 <top>/<repo>/<tag> 版本目录，写入 release notes 和给定的文件
+func newVersionDir(t *testing.T, files map[string]string) string {
+    t.Helper()
+    dir := filepath.Join(t.TempDir(), "tool", "v1.0.0")
+    writeFile(t, filepath.Join(dir, releaseNotesFile), "notes")
+    for name, content := range files {
+        writeFile(t, filepath.Join(dir, name), content)
+    }
+    return dir
+}
+
+func TestAuditVersionWithoutManifest(t *testing.T) {
+    dir := newVersionDir(t, map[string]string{
+        "tool.tar.gz":     "hello",
+        "tool.tar.gz.tmp": "hel",
+        "tool-linux.zip":  "hello",
+        "checksums.txt":   sha256Hello + "  tool-linux.zip\n",
+    })
+    d := NewDownloader(t.TempDir(), nil)
+    res := d.AuditVersion(dir, AuditOptions{})
+
+    if !res.UnknownProvenance {
+        t.Errorf("没有 manifest.json 的目录应标记为来源未知")
+    }
+    if want := []string{"tool.tar.gz"}; !reflect.DeepEqual(res.Unverified, want) {
+        t.Errorf("Unverified = %v, want %v", res.Unverified, want)
+    }
+    // 残留的临时文件仍然算多余文件
+    if want := []string{"tool.tar.gz.tmp"}; !reflect.DeepEqual(res.Unexpected, want) {
+        t.Errorf("Unexpected = %v, want %v", res.Unexpected, want)
+    }
+    if len(res.Files) != 1 || res.Files[0].Status != CheckOK {
+        t.Errorf("Files = %+v, want tool-linux.zip 校验通过", res.Files)
+    }
+}
+
+func TestAuditVersionHealthyTreeWithoutManifestPasses(t *testing.T) {
+    dir := newVersionDir(t, map[string]string{"tool.tar.gz": "hello", "tool.zip": "world"})
+    d := NewDownloader(t.TempDir(), nil)
+    res := d.AuditVersion(dir, AuditOptions{})
+    if !res.OK() {
+        t.Fatalf("没有哈希来源的健康目录不应失败: %+v", res)
+    }
+    if len(res.Unverified) != 2 {
+        t.Errorf("Unverified = %v, want 2 个文件", res.Unverified)
+    }
+}
+
+func TestAuditVersionWithManifest(t *testing.T) {
+    dir := newVersionDir(t, map[string]string{
+        "tool.tar.gz": "hello",
+        "extra.bin":   "dropped in later",
+    })
+    m := &Manifest{
+        Version:  manifestVersion,
+        Provider: ProviderGitHub,
+        Host:     githubHost,
+        Owner:    "acme",
+        Repo:     "tool",
+        Tag:      "v1.0.0",
+        Assets:   []ManifestAsset{{Name: "tool.tar.gz", Size: 5, SHA256: sha256Hello}},
+    }
+    if err := writeManifest(dir, m); err != nil {
+        t.Fatal(err)
+    }
+    d := NewDownloader(t.TempDir(), nil)
+    res := d.AuditVersion(dir, AuditOptions{})
+
+    if res.UnknownProvenance || len(res.Unverified) != 0 {
+        t.Errorf("有 manifest.json 的目录来源已知: %+v", res)
+    }
+    if res.Repo != "acme/tool" {
+        t.Errorf("Repo = %q, want acme/tool", res.Repo)
+    }
+    if want := []string{"extra.bin"}; !reflect.DeepEqual(res.Unexpected, want) {
+        t.Errorf("Unexpected = %v, want %v", res.Unexpected, want)
+    }
+    if res.OK() {
+        t.Errorf("有多余文件时不应通过审计")
+    }
+}
@@ -0,0 +1,270 @@
+package downloader
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+    "strings"
+    "time"
+
+    "github-downloader/logger"
+)
+
+// casDir 是下载目录中的内容寻址存储目录，文件按 SHA-256 存放在 .cas/sha256/<前两位>/<哈希>
+const casDir = ".cas"
+
+// 版本目录中的文件指向存储的方式，按优先级排列
+const (
+    LinkHard    = "hardlink" // 硬链接，与存储共享同一份数据
+    LinkReflink = "reflink"  // 写时复制的克隆（btrfs、XFS 等）
+    LinkSymlink = "symlink"  // 符号链接，指向存储中的文件
+)
+
+// hardLink 和 cloneFile 创建硬链接和克隆，测试中替换以模拟不支持它们的文件系统
+var (
+    hardLink  = os.Link
+    cloneFile = reflink
+)
+
+// SetCAS 设置是否将下载的资产放入内容寻址存储，相同内容的文件只保存一份
+func (d *Downloader) SetCAS(enabled bool) {
+    d.cas = enabled
+}
+
+// blobPath 返回内容在存储中的路径
+func blobPath(root, sum string) string {
+    return filepath.Join(root, casDir, "sha256", sum[:2], sum)
+}
+
+// storeFile 将 path 的内容放入存储，并使 path 指向存储中的文件
+// 存储中已有相同内容时返回使用的链接方式和节省的字节数；path 已指向存储时什么也不做
+func storeFile(root, path, sum string) (string, int64, error) {
+    blob := blobPath(root, sum)
+    info, err := os.Stat(path)
+    if err != nil {
+        return "", 0, err
+    }
+    if bi, err := os.Stat(blob); err == nil {
+        if os.SameFile(info, bi) {
+            return "", 0, nil
+        }
+        if bi.Size() != info.Size() {
+            return "", 0, fmt.Errorf("存储中的 %s 大小与 %s 不一致", sum, filepath.Base(path))
+        }
+        method, err := linkBlob(blob, path)
+        if err != nil {
+            return "", 0, err
+        }
+        return method, info.Size(), nil
+    } else if !os.IsNotExist(err) {
+        return "", 0, err
+    }
+
+    // 存储中还没有该内容：把文件本身加入存储
+    if err := os.MkdirAll(filepath.Dir(blob), 0755); err != nil {
+        return "", 0, err
+    }
+    err = hardLink(path, blob)
+    if err != nil && !os.IsExist(err) {
+        err = cloneFile(path, blob)
+    }
+    if os.IsExist(err) {
+        // 并发处理的其他仓库刚刚存入了相同的内容
+        return storeFile(root, path, sum)
+    }
+    if err == nil {
+        return "", 0, nil
+    }
+    // 不支持硬链接和克隆：移动文件到存储，原位置改为符号链接
+    if err := os.Rename(path, blob); err != nil {
+        return "", 0, err
+    }
+    if _, err := linkBlob(blob, path); err != nil {
+        os.Rename(blob, path)
+        return "", 0, err
+    }
+    return "", 0, nil
+}
+
+// storeAsset 将下载完成的资产放入存储，返回链接后文件的修改时间（用于下载记录）
+func (d *Downloader) storeAsset(localPath string, ma ManifestAsset) time.Time {
+    method, saved, err := storeFile(d.topDir, localPath, ma.SHA256)
+    if err != nil {
+        logger.Warn("无法放入存储 %s: %v", ma.Name, err)
+    } else if saved > 0 {
+        logger.Info("%s 与已有文件内容相同，已链接（%s，节省 %s）", ma.Name, method, ByteCountIEC(saved))
+    }
+    if info, err := os.Stat(localPath); err == nil {
+        return info.ModTime()
+    }
+    return ma.ModTime
+}
+
+// linkBlob 用指向 blob 的链接替换 dst，依次尝试硬链接、克隆和符号链接
+func linkBlob(blob, dst string) (string, error) {
+    tmp := dst + ".link.tmp"
+    os.Remove(tmp)
+    method := LinkHard
+    err := hardLink(blob, tmp)
+    if err != nil {
+        method, err = LinkReflink, cloneFile(blob, tmp)
+    }
+    if err != nil {
+        rel, rerr := filepath.Rel(filepath.Dir(dst), blob)
+        if rerr != nil {
+            return "", rerr
+        }
+        method, err = LinkSymlink, os.Symlink(rel, tmp)
+    }
+    if err != nil {
+        return "", err
+    }
+    if err := os.Rename(tmp, dst); err != nil {
+        os.Remove(tmp)
+        return "", err
+    }
+    return method, nil
+}
+
+// DedupeReport 是 Dedupe 的结果
+type DedupeReport struct {
+    Files   int            `json:"files"`       // 检查的文件数
+    Stored  int            `json:"stored"`      // 首次放入存储的文件数
+    Linked  int            `json:"linked"`      // 改为指向存储中已有内容的文件数
+    Shared  int            `json:"shared"`      // 已经指向存储的文件数
+    Saved   int64          `json:"saved_bytes"` // 节省的空间
+    Methods map[string]int `json:"methods,omitempty"`
+    Orphans int            `json:"orphans"` // 删除的不再被引用的存储文件数
+    Freed   int64          `json:"freed_bytes"`
+    Errors  int            `json:"errors"`
+}
+
+// Dedupe 将下载目录中所有版本目录的资产放入内容寻址存储，相同内容只保留一份，
+// 并删除存储中不再被任何版本目录引用的文件
+// st 用于获取未变化文件的哈希，并记录链接后的文件；dryRun 时只统计可以节省的空间
+func Dedupe(root string, st *State, dryRun bool) (DedupeReport, error) {
+    report := DedupeReport{Methods: make(map[string]int)}
+    dirs, err := FindVersionDirs(root)
+    if err != nil {
+        return report, err
+    }
+
+    used := make(map[string]bool) // 版本目录中出现的内容
+    for _, dir := range dirs {
+        entries, err := os.ReadDir(dir)
+        if err != nil {
+            return report, err
+        }
+        var relinked []string
+        for _, entry := range entries {
+            name := entry.Name()
+            // 元数据文件每次运行都会原地重写，不能与其他文件共享数据
+            if metadataFiles[name] || strings.HasSuffix(name, ".tmp") || !(entry.Type().IsRegular() || entry.Type()&os.ModeSymlink != 0) {
+                continue
+            }
+            path := filepath.Join(dir, name)
+            info, err := os.Stat(path)
+            if err != nil || info.Size() == 0 {
+                continue
+            }
+            report.Files++
+
+            sum := ""
+            if sums := st.digests(path, []string{"sha256"}); sums != nil {
+                sum = sums["sha256"]
+            } else if sum, err = computeSHA256(path); err != nil {
+                logger.Warn("计算哈希失败 %s: %v", path, err)
+                report.Errors++
+                continue
+            }
+
+            bi, err := os.Stat(blobPath(root, sum))
+            shared := err == nil && os.SameFile(info, bi)
+            if shared {
+                report.Shared++
+            }
+            if dryRun {
+                switch {
+                case shared:
+                case err == nil || used[sum]:
+                    report.Linked++
+                    report.Saved += info.Size()
+                default:
+                    report.Stored++
+                }
+                used[sum] = true
+                continue
+            }
+
+            used[sum] = true
+            if shared {
+                continue
+            }
+            method, saved, err := storeFile(root, path, sum)
+            if err != nil {
+                logger.Warn("无法链接 %s: %v", path, err)
+                report.Errors++
+                continue
+            }
+            if method == "" {
+                report.Stored++
+                continue
+            }
+            report.Linked++
+            report.Saved += saved
+            report.Methods[method]++
+            relinked = append(relinked, name)
+            st.recordFile(path, map[string]string{"sha256": sum})
+        }
+        if len(relinked) > 0 {
+            refreshManifest(dir, relinked)
+        }
+    }
+
+    if report.Errors > 0 {
+        // 有文件未能计算哈希时无法确定哪些存储文件仍被引用
+        logger.Warn("有 %d 个文件处理失败，跳过清理存储", report.Errors)
+        return report, nil
+    }
+    report.Orphans, report.Freed, err = removeOrphanBlobs(root, used, dryRun)
+    return report, err
+}
+
+// refreshManifest 更新 manifest.json 中重新链接的文件的修改时间，使其记录仍然有效
+func refreshManifest(dir string, names []string) {
+    m, err := ReadManifest(dir)
+    if err != nil {
+        return
+    }
+    for _, name := range names {
+        if ma := m.Asset(name); ma != nil {
+            if info, err := os.Stat(filepath.Join(dir, name)); err == nil {
+                ma.ModTime = info.ModTime()
+            }
+        }
+    }
+    if err := writeManifest(dir, m); err != nil {
+        logger.Warn("无法更新 %s: %v", filepath.Join(dir, manifestFile), err)
+    }
+}
+
+// removeOrphanBlobs 删除存储中不在 used 中的文件，返回删除的文件数和字节数
+func removeOrphanBlobs(root string, used map[string]bool, dryRun bool) (int, int64, error) {
+    var count int
+    var freed int64
+    err := filepath.Walk(filepath.Join(root, casDir), func(path string, info os.FileInfo, err error) error {
+        if os.IsNotExist(err) {
+            return nil
+        }
+        if err != nil || info.IsDir() || used[info.Name()] {
+            return err
+        }
+        count++
+        freed += info.Size()
+        if dryRun {
+            return nil
+        }
+        return os.Remove(path)
+    })
+    return count, freed, err
+}
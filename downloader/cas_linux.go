@@ -0,0 +1,28 @@
+package downloader
+
+import (
+    "os"
+    "syscall"
+)
+
+// ficlone 是 Linux 的 FICLONE ioctl 请求号
+const ficlone = 0x40049409
+
+// reflink 创建 src 的写时复制克隆 dst，文件系统不支持时返回错误
+func reflink(src, dst string) error {
+    in, err := os.Open(src)
+    if err != nil {
+        return err
+    }
+    defer in.Close()
+    out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+    if err != nil {
+        return err
+    }
+    if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, out.Fd(), ficlone, in.Fd()); errno != 0 {
+        out.Close()
+        os.Remove(dst)
+        return errno
+    }
+    return out.Close()
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package downloader
+
+import "errors"
+
+// reflink 在非 Linux 平台上不受支持
+func reflink(src, dst string) error {
+    return errors.ErrUnsupported
+}
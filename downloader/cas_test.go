@@ -0,0 +1,191 @@
+package downloader
+
+import (
+    "errors"
+    "io"
+    "os"
+    "path/filepath"
+    "testing"
+)
+
+// withLinkFuncs 在测试期间替换创建硬链接和克隆的函数，为 nil 时模拟文件系统不支持
+func withLinkFuncs(t *testing.T, link, clone func(string, string) error) {
+    t.Helper()
+    unsupported := func(string, string) error { return errors.ErrUnsupported }
+    if link == nil {
+        link = unsupported
+    }
+    if clone == nil {
+        clone = unsupported
+    }
+    oldLink, oldClone := hardLink, cloneFile
+    hardLink, cloneFile = link, clone
+    t.Cleanup(func() { hardLink, cloneFile = oldLink, oldClone })
+}
+
+// copyClone 用普通复制模拟克隆：内容相同，但不是同一个文件
+func copyClone(src, dst string) error {
+    in, err := os.Open(src)
+    if err != nil {
+        return err
+    }
+    defer in.Close()
+    out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+    if err != nil {
+        return err
+    }
+    if _, err := io.Copy(out, in); err != nil {
+        out.Close()
+        return err
+    }
+    return out.Close()
+}
+
+// newDedupeTree 创建两个版本目录，其中的 tool.tar.gz 内容相同
+func newDedupeTree(t *testing.T) (root string, paths []string) {
+    t.Helper()
+    root = t.TempDir()
+    for _, tag := range []string{"v1.0.0", "v1.0.1"} {
+        dir := filepath.Join(root, "tool", tag)
+        writeFile(t, filepath.Join(dir, releaseNotesFile), "notes "+tag)
+        writeFile(t, filepath.Join(dir, "tool.tar.gz"), "hello")
+        paths = append(paths, filepath.Join(dir, "tool.tar.gz"))
+    }
+    return root, paths
+}
+
+func readContent(t *testing.T, path string) string {
+    t.Helper()
+    data, err := os.ReadFile(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return string(data)
+}
+
+func TestStoreFileMethods(t *testing.T) {
+    tests := []struct {
+        name    string
+        link    func(string, string) error
+        clone   func(string, string) error
+        method  string
+        same    bool // 链接后的文件与存储中的文件是同一个文件
+        symlink bool
+    }{
+        {"硬链接", os.Link, nil, LinkHard, true, false},
+        {"不支持硬链接时克隆", nil, copyClone, LinkReflink, false, false},
+        {"都不支持时使用符号链接", nil, nil, LinkSymlink, true, true},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            withLinkFuncs(t, tt.link, tt.clone)
+            root, paths := newDedupeTree(t)
+            blob := blobPath(root, sha256Hello)
+
+            // 第一个文件放入存储
+            method, saved, err := storeFile(root, paths[0], sha256Hello)
+            if err != nil || method != "" || saved != 0 {
+                t.Fatalf("首次 storeFile() = %q, %d, %v", method, saved, err)
+            }
+            if readContent(t, blob) != "hello" || readContent(t, paths[0]) != "hello" {
+                t.Fatalf("放入存储后内容不正确")
+            }
+
+            // 第二个文件指向存储中已有的内容
+            method, saved, err = storeFile(root, paths[1], sha256Hello)
+            if err != nil || method != tt.method || saved != 5 {
+                t.Fatalf("storeFile() = %q, %d, %v, want %q, 5", method, saved, err, tt.method)
+            }
+            if readContent(t, paths[1]) != "hello" {
+                t.Errorf("链接后内容不正确")
+            }
+            info, _ := os.Stat(paths[1])
+            bi, _ := os.Stat(blob)
+            if os.SameFile(info, bi) != tt.same {
+                t.Errorf("SameFile = %v, want %v", !tt.same, tt.same)
+            }
+            li, _ := os.Lstat(paths[1])
+            if (li.Mode()&os.ModeSymlink != 0) != tt.symlink {
+                t.Errorf("文件类型 %v, want 符号链接 %v", li.Mode(), tt.symlink)
+            }
+            if _, err := os.Lstat(paths[1] + ".link.tmp"); !os.IsNotExist(err) {
+                t.Errorf("残留临时链接: %v", err)
+            }
+        })
+    }
+}
+
+func TestStoreFileSizeMismatch(t *testing.T) {
+    root, paths := newDedupeTree(t)
+    writeFile(t, blobPath(root, sha256Hello), "hello world")
+    if _, _, err := storeFile(root, paths[0], sha256Hello); err == nil {
+        t.Fatalf("存储中的文件大小不一致时应返回错误")
+    }
+    if readContent(t, paths[0]) != "hello" {
+        t.Errorf("失败时不应改动原文件")
+    }
+}
+
+func TestDedupeRerun(t *testing.T) {
+    tests := []struct {
+        name        string
+        link, clone func(string, string) error
+        method      string
+    }{
+        {"硬链接", os.Link, nil, LinkHard},
+        {"符号链接", nil, nil, LinkSymlink},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            withLinkFuncs(t, tt.link, tt.clone)
+            root, paths := newDedupeTree(t)
+            // 不再被引用的存储文件会被清理
+            writeFile(t, blobPath(root, sha512Hello[:64]), "orphan")
+
+            first, err := Dedupe(root, nil, false)
+            if err != nil {
+                t.Fatal(err)
+            }
+            if first.Files != 2 || first.Stored != 1 || first.Linked != 1 || first.Saved != 5 || first.Methods[tt.method] != 1 || first.Orphans != 1 || first.Errors != 0 {
+                t.Fatalf("首次 Dedupe() = %+v", first)
+            }
+
+            // 再次运行：所有文件都已指向存储，什么也不做
+            second, err := Dedupe(root, nil, false)
+            if err != nil {
+                t.Fatal(err)
+            }
+            if second.Files != 2 || second.Shared != 2 || second.Stored != 0 || second.Linked != 0 || second.Saved != 0 || second.Orphans != 0 || second.Errors != 0 {
+                t.Fatalf("再次 Dedupe() = %+v", second)
+            }
+            for _, p := range paths {
+                if readContent(t, p) != "hello" {
+                    t.Errorf("%s 内容不正确", p)
+                }
+            }
+            if readContent(t, blobPath(root, sha256Hello)) != "hello" {
+                t.Errorf("存储中的文件被删除")
+            }
+        })
+    }
+}
+
+func TestDedupeDryRun(t *testing.T) {
+    withLinkFuncs(t, os.Link, nil)
+    root, paths := newDedupeTree(t)
+    report, err := Dedupe(root, nil, true)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if report.Stored != 1 || report.Linked != 1 || report.Saved != 5 {
+        t.Errorf("Dedupe(dryRun) = %+v", report)
+    }
+    if _, err := os.Stat(filepath.Join(root, casDir)); !os.IsNotExist(err) {
+        t.Errorf("dryRun 不应创建存储: %v", err)
+    }
+    a, _ := os.Stat(paths[0])
+    b, _ := os.Stat(paths[1])
+    if os.SameFile(a, b) {
+        t.Errorf("dryRun 不应链接文件")
+    }
+}
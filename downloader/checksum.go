@@ -0,0 +1,370 @@
+package downloader
+
+import (
+    "bufio"
+    "crypto/md5"
+    "crypto/sha1"
+    "crypto/sha256"
+    "crypto/sha512"
+    "encoding/hex"
+    "fmt"
+    "hash"
+    "io"
+    "os"
+    "path/filepath"
+    "regexp"
+    "strings"
+
+    "golang.org/x/crypto/blake2b"
+    "golang.org/x/crypto/blake2s"
+    "lukechampine.com/blake3"
+)
+
+// Digest 是带算法名称的哈希值，零值表示没有可用的哈希
+type Digest struct {
+    Algo string // sha256, sha512, sha1, md5, blake2b-512, blake2b-256, blake2s-256, blake3
+    Hex  string
+}
+
+// IsZero 判断是否没有哈希值
+func (dg Digest) IsZero() bool {
+    return dg.Hex == ""
+}
+
+func (dg Digest) String() string {
+    if dg.IsZero() {
+        return ""
+    }
+    return dg.Algo + ":" + dg.Hex
+}
+
+// newHash 根据算法名称创建哈希函数
+func newHash(algo string) (hash.Hash, error) {
+    switch algo {
+    case "sha256":
+        return sha256.New(), nil
+    case "sha512":
+        return sha512.New(), nil
+    case "sha1":
+        return sha1.New(), nil
+    case "md5":
+        return md5.New(), nil
+    case "blake2b-512":
+        return blake2b.New512(nil)
+    case "blake2b-256":
+        return blake2b.New256(nil)
+    case "blake2s-256":
+        return blake2s.New256(nil)
+    case "blake3":
+        return blake3.New(32, nil), nil
+    }
+    return nil, fmt.Errorf("不支持的哈希算法: %s", algo)
+}
+
+// digestHexLen 返回算法输出的十六进制长度
+func digestHexLen(algo string) int {
+    switch algo {
+    case "sha512", "blake2b-512":
+        return 128
+    case "sha256", "blake2b-256", "blake2s-256", "blake3":
+        return 64
+    case "sha1":
+        return 40
+    case "md5":
+        return 32
+    }
+    return 0
+}
+
+// algoByHexLen 根据十六进制哈希长度推断算法（长度相同时视为更常见的 SHA 系列）
+func algoByHexLen(n int) string {
+    switch n {
+    case 64:
+        return "sha256"
+    case 128:
+        return "sha512"
+    case 40:
+        return "sha1"
+    case 32:
+        return "md5"
+    }
+    return ""
+}
+
+// supportedAlgo 判断是否支持该算法（blake2b 的长度由哈希值确定）
+func supportedAlgo(algo string) bool {
+    return algo == "blake2b" || digestHexLen(algo) > 0
+}
+
+// resolveAlgo 确定一条哈希记录的算法，优先级：行内显式声明、校验文件名、哈希长度
+// 声明的算法与哈希长度不符时忽略该声明；声明了不支持的算法时返回空字符串，不按长度猜测
+func resolveAlgo(explicit, fromName string, hexLen int) string {
+    if explicit != "" && !supportedAlgo(explicit) {
+        return ""
+    }
+    for _, a := range []string{explicit, fromName} {
+        if a == "blake2b" {
+            // b2sum 默认输出 512 位，可通过 -l 指定其他长度
+            switch hexLen {
+            case 128:
+                return "blake2b-512"
+            case 64:
+                return "blake2b-256"
+            }
+            continue
+        }
+        if a != "" && digestHexLen(a) == hexLen {
+            return a
+        }
+    }
+    return algoByHexLen(hexLen)
+}
+
+// computeDigest 计算文件的指定算法哈希
+func computeDigest(path, algo string) (string, error) {
+    sums, err := computeDigests(path, []string{algo})
+    if err != nil {
+        return "", err
+    }
+    return sums[algo], nil
+}
+
+// computeDigests 只读取一次文件，同时计算多个算法的哈希，返回 算法 -> 十六进制哈希
+func computeDigests(path string, algos []string) (map[string]string, error) {
+    hashes := make(map[string]hash.Hash, len(algos))
+    writers := make([]io.Writer, 0, len(algos))
+    for _, algo := range algos {
+        if _, ok := hashes[algo]; ok {
+            continue
+        }
+        h, err := newHash(algo)
+        if err != nil {
+            return nil, err
+        }
+        hashes[algo] = h
+        writers = append(writers, h)
+    }
+
+    f, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+
+    if _, err := io.Copy(io.MultiWriter(writers...), f); err != nil {
+        return nil, err
+    }
+    sums := make(map[string]string, len(hashes))
+    for algo, h := range hashes {
+        sums[algo] = hex.EncodeToString(h.Sum(nil))
+    }
+    return sums, nil
+}
+
+// verifyDigest 验证文件哈希
+func verifyDigest(path string, expected Digest) (bool, error) {
+    actual, err := computeDigest(path, expected.Algo)
+    if err != nil {
+        return false, err
+    }
+    return strings.EqualFold(actual, expected.Hex), nil
+}
+
+// sidecarExts 是单文件校验文件的扩展名及对应算法，例如 foo.tar.gz.sha256；按算法从强到弱排列
+var sidecarExts = []struct {
+    ext  string
+    algo string
+}{
+    {".sha512", "sha512"},
+    {".sha512sum", "sha512"},
+    {".b2", "blake2b"},
+    {".blake2b", "blake2b"},
+    {".b3", "blake3"},
+    {".blake3", "blake3"},
+    {".sha256", "sha256"},
+    {".sha256sum", "sha256"},
+    {".sha1", "sha1"},
+    {".md5", "md5"},
+    {".md5sum", "md5"},
+}
+
+// sidecarTarget 判断文件名是否为单文件校验文件，返回其对应的资产名和算法
+func sidecarTarget(name string) (target, algo string, ok bool) {
+    lower := strings.ToLower(name)
+    for _, s := range sidecarExts {
+        if strings.HasSuffix(lower, s.ext) && len(name) > len(s.ext) {
+            return name[:len(name)-len(s.ext)], s.algo, true
+        }
+    }
+    return "", "", false
+}
+
+// pairSidecars 按命名约定将资产与其单文件校验文件配对，返回 资产名 -> 校验文件名
+// 同一资产有多个校验文件时，按 sidecarExts 的顺序优先选择更强的算法
+func pairSidecars(assets []Asset) map[string]string {
+    names := make(map[string]bool, len(assets))
+    for _, a := range assets {
+        names[a.Name] = true
+    }
+    pairs := make(map[string]string)
+    for _, s := range sidecarExts {
+        for _, a := range assets {
+            target, _, ok := sidecarTarget(a.Name)
+            if !ok || !names[target] || !strings.HasSuffix(strings.ToLower(a.Name), s.ext) {
+                continue
+            }
+            if _, exists := pairs[target]; !exists {
+                pairs[target] = a.Name
+            }
+        }
+    }
+    return pairs
+}
+
+// isSidecar 判断资产是否为另一个资产的单文件校验文件
+func isSidecar(name string, pairs map[string]string) bool {
+    target, _, ok := sidecarTarget(name)
+    return ok && pairs[target] == name
+}
+
+// sidecarsFirst 调整下载顺序，使校验文件先于对应的资产下载
+func sidecarsFirst(assets []Asset, pairs map[string]string) []Asset {
+    ordered := make([]Asset, 0, len(assets))
+    for _, a := range assets {
+        if isSidecar(a.Name, pairs) {
+            ordered = append(ordered, a)
+        }
+    }
+    for _, a := range assets {
+        if !isSidecar(a.Name, pairs) {
+            ordered = append(ordered, a)
+        }
+    }
+    return ordered
+}
+
+// bsdChecksumLine 匹配 BSD 格式: SHA256 (file) = hash
+var bsdChecksumLine = regexp.MustCompile(`^([A-Za-z0-9-]+) ?\((.+)\) ?= ?([0-9a-fA-F]+)$`)
+
+// parseChecksumLine 解析校验文件中的一行，支持以下格式：
+//   hash                     仅哈希值
+//   hash  name / hash *name  GNU coreutils
+//   SHA256 (name) = hash     BSD（以及 GNU 的 --tag 输出）
+//   sha256:hash  name        带算法前缀
+// algo 为行内显式声明的算法，未声明时为空
+func parseChecksumLine(line string) (name, algo, sum string, ok bool) {
+    line = strings.TrimSpace(line)
+    if line == "" || strings.HasPrefix(line, "#") {
+        return "", "", "", false
+    }
+    if m := bsdChecksumLine.FindStringSubmatch(line); m != nil {
+        name, algo, sum = m[2], normalizeAlgo(m[1]), m[3]
+    } else {
+        parts := strings.Fields(line)
+        sum = parts[0]
+        if prefix, rest, found := strings.Cut(sum, ":"); found {
+            algo, sum = normalizeAlgo(prefix), rest
+        }
+        if len(parts) >= 2 {
+            name = strings.TrimPrefix(strings.Join(parts[1:], " "), "*")
+        }
+    }
+    if !isHex(sum) {
+        return "", "", "", false
+    }
+    return name, algo, strings.ToLower(sum), true
+}
+
+// readSidecar 读取单文件校验文件，返回 assetName 的哈希值
+// 格式见 parseChecksumLine
+func readSidecar(path, assetName string) (Digest, error) {
+    _, defaultAlgo, _ := sidecarTarget(filepath.Base(path))
+
+    f, err := os.Open(path)
+    if err != nil {
+        return Digest{}, err
+    }
+    defer f.Close()
+
+    var entries []Digest
+    scanner := bufio.NewScanner(f)
+    for scanner.Scan() {
+        name, algo, sum, ok := parseChecksumLine(scanner.Text())
+        if !ok {
+            continue
+        }
+        algo = resolveAlgo(algo, defaultAlgo, len(sum))
+        if algo == "" {
+            continue
+        }
+        dg := Digest{Algo: algo, Hex: sum}
+        if name != "" && filepath.Base(name) == assetName {
+            return dg, nil
+        }
+        entries = append(entries, dg)
+    }
+    if err := scanner.Err(); err != nil {
+        return Digest{}, err
+    }
+
+    // 只有一条记录时（仅哈希值，或文件名带有其他路径），视为对应该资产
+    if len(entries) == 1 {
+        return entries[0], nil
+    }
+    return Digest{}, fmt.Errorf("未找到 %s 的哈希值", assetName)
+}
+
+// normalizeAlgo 将 BSD 格式或前缀中的算法名（SHA256、SHA2-256、BLAKE2b、b3 等）转换为内部名称
+// blake2b 未指定长度时返回 "blake2b"，由 resolveAlgo 根据哈希长度确定
+func normalizeAlgo(name string) string {
+    n := strings.ToLower(strings.ReplaceAll(name, "-", ""))
+    switch n {
+    case "sha256", "sha2256":
+        return "sha256"
+    case "sha512", "sha2512":
+        return "sha512"
+    case "blake2b", "b2":
+        return "blake2b"
+    case "blake2b512":
+        return "blake2b-512"
+    case "blake2b256":
+        return "blake2b-256"
+    case "blake2s", "blake2s256":
+        return "blake2s-256"
+    case "blake3", "b3":
+        return "blake3"
+    case "sha1", "md5":
+        return n
+    }
+    // 不支持的算法保留原名，用于提示
+    return strings.ToLower(name)
+}
+
+// algoFromFileName 根据合并校验文件的文件名推断算法，如 SHA512SUMS、b2sums.txt
+func algoFromFileName(name string) string {
+    lower := strings.ToLower(name)
+    for _, c := range []struct{ key, algo string }{
+        {"blake3", "blake3"},
+        {"b3sum", "blake3"},
+        {"blake2s", "blake2s-256"},
+        {"blake2b", "blake2b"},
+        {"b2sum", "blake2b"},
+        {"sha512", "sha512"},
+        {"sha256", "sha256"},
+        {"sha1", "sha1"},
+        {"md5", "md5"},
+    } {
+        if strings.Contains(lower, c.key) {
+            return c.algo
+        }
+    }
+    return ""
+}
+
+func isHex(s string) bool {
+    if s == "" {
+        return false
+    }
+    _, err := hex.DecodeString(s)
+    return err == nil
+}
@@ -0,0 +1,231 @@
+package downloader
+
+import (
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+func TestPairSidecarsPrefersStrongerAlgorithm(t *testing.T) {
+    assets := []Asset{
+        {Name: "tool.tar.gz"},
+        {Name: "tool.tar.gz.md5"},
+        {Name: "tool.tar.gz.sha256"},
+        {Name: "tool.tar.gz.sha512"},
+        {Name: "other.zip"},
+        {Name: "other.zip.sha256sum"},
+        {Name: "orphan.bin.sha256"}, // 没有对应的资产
+    }
+    pairs := pairSidecars(assets)
+    want := map[string]string{
+        "tool.tar.gz": "tool.tar.gz.sha512",
+        "other.zip":   "other.zip.sha256sum",
+    }
+    if len(pairs) != len(want) {
+        t.Fatalf("pairSidecars() = %v, want %v", pairs, want)
+    }
+    for asset, sidecar := range want {
+        if pairs[asset] != sidecar {
+            t.Errorf("pairs[%q] = %q, want %q", asset, pairs[asset], sidecar)
+        }
+    }
+    if isSidecar("tool.tar.gz.sha256", pairs) {
+        t.Errorf("tool.tar.gz.sha256 不应是选中的校验文件")
+    }
+}
+
+func TestSidecarTarget(t *testing.T) {
+    tests := []struct {
+        name, target, algo string
+        ok                 bool
+    }{
+        {"a.tar.gz.sha256", "a.tar.gz", "sha256", true},
+        {"a.tar.gz.SHA512", "a.tar.gz", "sha512", true},
+        {"a.zip.sha256sum", "a.zip", "sha256", true},
+        {"a.zip.b3", "a.zip", "blake3", true},
+        {"a.zip.md5sum", "a.zip", "md5", true},
+        {".sha256", "", "", false},
+        {"a.zip", "", "", false},
+    }
+    for _, tt := range tests {
+        target, algo, ok := sidecarTarget(tt.name)
+        if target != tt.target || algo != tt.algo || ok != tt.ok {
+            t.Errorf("sidecarTarget(%q) = %q, %q, %v, want %q, %q, %v", tt.name, target, algo, ok, tt.target, tt.algo, tt.ok)
+        }
+    }
+}
+
+const (
+    sha256Hello = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824" // "hello"
+    sha512Hello = "9b71d224bd62f3785d96d46ad3ea3d73319bfbc2890caadae2dff72519673ca72323c3d99ba5c11d7c7acc6e14b8c5da0c4663475c2e5c3adef46f73bcdec043"
+    md5Hello    = "5d41402abc4b2a76b9719d911017c592"
+)
+
+func TestParseChecksumLine(t *testing.T) {
+    tests := []struct {
+        line            string
+        name, algo, sum string
+        ok              bool
+    }{
+        {"# comment", "", "", "", false},
+        {"", "", "", "", false},
+        {sha256Hello, "", "", sha256Hello, true},
+        {sha256Hello + "  hello.txt", "hello.txt", "", sha256Hello, true},
+        {sha256Hello + " *hello.txt", "hello.txt", "", sha256Hello, true},
+        {sha256Hello + "  dir/name with space.txt", "dir/name with space.txt", "", sha256Hello, true},
+        {"SHA256 (hello.txt) = " + sha256Hello, "hello.txt", "sha256", sha256Hello, true},
+        {"SHA512 (hello.txt) = " + sha512Hello, "hello.txt", "sha512", sha512Hello, true},
+        {"BLAKE2b (hello.txt) = " + sha512Hello, "hello.txt", "blake2b", sha512Hello, true},
+        {"sha256:" + sha256Hello + "  hello.txt", "hello.txt", "sha256", sha256Hello, true},
+        {strings.ToUpper(md5Hello) + "  hello.txt", "hello.txt", "", md5Hello, true},
+        {"not-a-hash  hello.txt", "", "", "", false},
+        {"SHA256 (hello.txt) = xyz", "", "", "", false},
+        {"abc  hello.txt", "", "", "", false}, // 奇数长度
+    }
+    for _, tt := range tests {
+        name, algo, sum, ok := parseChecksumLine(tt.line)
+        if name != tt.name || algo != tt.algo || sum != tt.sum || ok != tt.ok {
+            t.Errorf("parseChecksumLine(%q) = %q, %q, %q, %v, want %q, %q, %q, %v",
+                tt.line, name, algo, sum, ok, tt.name, tt.algo, tt.sum, tt.ok)
+        }
+    }
+}
+
+func TestResolveAlgo(t *testing.T) {
+    tests := []struct {
+        explicit, fromName string
+        hexLen             int
+        want               string
+    }{
+        {"", "", 64, "sha256"},
+        {"", "", 128, "sha512"},
+        {"", "", 40, "sha1"},
+        {"", "", 32, "md5"},
+        {"", "", 50, ""},
+        {"blake3", "", 64, "blake3"},
+        {"", "blake3", 64, "blake3"},
+        {"sha512", "sha256", 64, "sha256"}, // 声明与长度不符时忽略声明
+        {"blake2b", "", 128, "blake2b-512"},
+        {"", "blake2b", 64, "blake2b-256"},
+        {"sha256", "sha512", 128, "sha512"},
+        // 声明了不支持的算法时不按长度猜测
+        {"sha3-256", "sha256", 64, ""},
+        {"crc32", "", 8, ""},
+    }
+    for _, tt := range tests {
+        if got := resolveAlgo(tt.explicit, tt.fromName, tt.hexLen); got != tt.want {
+            t.Errorf("resolveAlgo(%q, %q, %d) = %q, want %q", tt.explicit, tt.fromName, tt.hexLen, got, tt.want)
+        }
+    }
+}
+
+func TestVerifyChecksumFilesMixedAlgorithms(t *testing.T) {
+    dir := t.TempDir()
+    writeFile(t, filepath.Join(dir, "hello.txt"), "hello")
+    writeFile(t, filepath.Join(dir, "bad.txt"), "tampered")
+    writeFile(t, filepath.Join(dir, "SHA256SUMS"), sha256Hello+"  hello.txt\n"+sha256Hello+" *bad.txt\n"+sha256Hello+"  skipped.txt\n"+sha256Hello+"  other.txt\n")
+    // 同一文件的第二种算法，以及无法识别长度的记录
+    writeFile(t, filepath.Join(dir, "checksums.txt"), "SHA512 (hello.txt) = "+sha512Hello+"\n"+"MD5 (hello.txt) = "+md5Hello+"\n"+"abcdef  weird.txt\n")
+
+    results, err := verifyChecksumFiles(dir, []string{filepath.Join(dir, "SHA256SUMS"), filepath.Join(dir, "checksums.txt")}, nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    classifyMissing(results, []Asset{{Name: "hello.txt"}, {Name: "bad.txt"}, {Name: "skipped.txt"}}, []Asset{{Name: "hello.txt"}, {Name: "bad.txt"}})
+
+    got := make(map[string]FileCheck)
+    for _, fc := range results {
+        got[fc.Name] = fc
+    }
+    want := map[string]string{
+        "hello.txt":   CheckOK,
+        "bad.txt":     CheckMismatch,
+        "skipped.txt": CheckNotSelected,
+        "other.txt":   CheckNotInRelease,
+        "weird.txt":   CheckError,
+    }
+    if len(got) != len(want) {
+        t.Fatalf("got %d results, want %d: %+v", len(got), len(want), results)
+    }
+    for name, status := range want {
+        if got[name].Status != status {
+            t.Errorf("%s: status %q (%s), want %q", name, got[name].Status, got[name].Error, status)
+        }
+    }
+    if algos := strings.Join(got["hello.txt"].Algos, ","); algos != "sha256,sha512,md5" {
+        t.Errorf("hello.txt algos = %s, want sha256,sha512,md5", algos)
+    }
+    if sources := strings.Join(got["hello.txt"].Sources, ","); sources != "SHA256SUMS,checksums.txt" {
+        t.Errorf("hello.txt sources = %s", sources)
+    }
+}
+
+func TestVerifyChecksumFilesUnsupportedAlgorithm(t *testing.T) {
+    dir := t.TempDir()
+    writeFile(t, filepath.Join(dir, "hello.txt"), "hello")
+    writeFile(t, filepath.Join(dir, "both.txt"), "hello")
+    // sha3-256 与 SHA-256 的哈希长度相同，不能当作 SHA-256 比较
+    writeFile(t, filepath.Join(dir, "checksums.txt"),
+        "sha3-256:"+strings.Repeat("ab", 32)+"  hello.txt\n"+
+            "sha3-256:"+strings.Repeat("ab", 32)+"  both.txt\n"+
+            "sha256:"+sha256Hello+"  both.txt\n"+
+            "SHA3-256 (gone.txt) = "+strings.Repeat("ab", 32)+"\n")
+
+    results, err := verifyChecksumFiles(dir, []string{filepath.Join(dir, "checksums.txt")}, nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    classifyMissing(results, []Asset{{Name: "hello.txt"}, {Name: "both.txt"}, {Name: "gone.txt"}}, []Asset{{Name: "hello.txt"}, {Name: "both.txt"}})
+    got := make(map[string]FileCheck)
+    for _, fc := range results {
+        got[fc.Name] = fc
+    }
+    if fc := got["hello.txt"]; fc.Status != CheckUnsupported || !strings.Contains(fc.Error, "sha3-256") {
+        t.Errorf("hello.txt: %+v, want %s", fc, CheckUnsupported)
+    }
+    // 同时有支持的算法时按支持的算法校验
+    if fc := got["both.txt"]; fc.Status != CheckOK {
+        t.Errorf("both.txt: %+v, want %s", fc, CheckOK)
+    }
+    if fc := got["gone.txt"]; fc.Status != CheckNotSelected {
+        t.Errorf("gone.txt: %+v, want %s", fc, CheckNotSelected)
+    }
+
+    // lenient 模式跳过不支持的算法，normal 模式视为失败
+    d := NewDownloader(t.TempDir(), nil)
+    release := &Release{Assets: []Asset{{Name: "hello.txt"}, {Name: "both.txt"}, {Name: "gone.txt"}, {Name: "checksums.txt"}}}
+    selected := release.Assets[:2]
+    if _, err := d.verifyFiles(dir, release, selected, nil, nil, VerifyLenient); err != nil {
+        t.Errorf("lenient: verifyFiles() = %v, want nil", err)
+    }
+    if _, err := d.verifyFiles(dir, release, selected, nil, nil, VerifyNormal); err == nil {
+        t.Errorf("normal: verifyFiles() = nil, want 校验失败")
+    }
+}
+
+func TestCheckFailedByMode(t *testing.T) {
+    statuses := []string{CheckOK, CheckMismatch, CheckError, CheckMissing, CheckNotSelected, CheckNotInRelease, CheckUnsupported}
+    want := map[string][]bool{
+        VerifyStrict:  {false, true, true, true, true, true, true},
+        VerifyNormal:  {false, true, true, true, false, false, true},
+        VerifyLenient: {false, true, true, false, false, false, false},
+    }
+    for mode, fails := range want {
+        for i, status := range statuses {
+            if got := checkFailed(status, mode); got != fails[i] {
+                t.Errorf("checkFailed(%q, %q) = %v, want %v", status, mode, got, fails[i])
+            }
+        }
+    }
+}
+
+func writeFile(t *testing.T, path, content string) {
+    t.Helper()
+    if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+        t.Fatal(err)
+    }
+    if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+        t.Fatal(err)
+    }
+}
@@ -0,0 +1,176 @@
+package downloader
+
+import (
+    "context"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+    "os"
+    "path/filepath"
+    "time"
+
+    "github-downloader/logger"
+)
+
+// 代理内容交叉校验模式，只对经过代理下载且没有官方哈希的资产生效
+const (
+    CrossCheckOff    = "off"    // 不校验（默认）
+    CrossCheckOrigin = "origin" // 从源站完整下载，比较 SHA-256
+    CrossCheckProxy  = "proxy"  // 通过另一个独立代理完整下载，比较 SHA-256
+    CrossCheckAuto   = "auto"   // 优先使用源站，源站不可用时使用另一个代理
+)
+
+// quarantineDir 是下载目录中存放交叉校验不一致文件的目录
+const quarantineDir = ".quarantine"
+
+// errCrossCheckMismatch 表示代理返回的内容与源站或另一个代理不一致
+var errCrossCheckMismatch = errors.New("代理内容与交叉校验来源不一致")
+
+// ParseCrossCheckMode 检查交叉校验模式名称，空字符串表示 off
+func ParseCrossCheckMode(s string) (string, error) {
+    switch s {
+    case "":
+        return CrossCheckOff, nil
+    case CrossCheckOff, CrossCheckOrigin, CrossCheckProxy, CrossCheckAuto:
+        return s, nil
+    }
+    return "", fmt.Errorf("无效的交叉校验模式 %q（可选 off、origin、proxy、auto）", s)
+}
+
+// crossCheck 将经由 usedProxy 下载的文件与源站或另一个代理比对
+// 内容不一致时返回 errCrossCheckMismatch；无法完成校验（源站和其他代理均不可用）时返回其他错误
+func (d *Downloader) crossCheck(ctx context.Context, url, localPath, usedProxy, mode string) error {
+    var originErr error
+    if mode == CrossCheckOrigin || mode == CrossCheckAuto {
+        logger.Info("交叉校验: 从源站完整比对 %s", filepath.Base(localPath))
+        originErr = d.compareVia(ctx, url, localPath)
+        if originErr == nil || errors.Is(originErr, errCrossCheckMismatch) || mode == CrossCheckOrigin || ctx.Err() != nil {
+            return originErr
+        }
+        logger.Warn("源站交叉校验失败: %v，改用其他代理", originErr)
+    }
+
+    for _, proxy := range d.Proxies() {
+        if proxy == usedProxy {
+            continue
+        }
+        logger.Info("交叉校验: 通过代理 %s 比对 %s", proxy, filepath.Base(localPath))
+        err := d.compareVia(ctx, buildProxyURL(url, proxy), localPath)
+        if err == nil || errors.Is(err, errCrossCheckMismatch) || ctx.Err() != nil {
+            return err
+        }
+        logger.Warn("代理 %s 交叉校验失败: %v", proxy, err)
+    }
+    if originErr != nil {
+        return fmt.Errorf("源站和其他代理均无法完成交叉校验: %w", originErr)
+    }
+    return fmt.Errorf("没有其他可用的代理进行交叉校验")
+}
+
+// compareVia 从 url 完整下载文件（不保存），比较其 SHA-256 与本地文件；按全局超时检测停滞，下载速度受限速规则限制
+func (d *Downloader) compareVia(parent context.Context, url, localPath string) error {
+    ctx, wrap, cancel := watch(parent, d.timeouts)
+    defer cancel()
+    req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+    if err != nil {
+        return err
+    }
+    req.Header.Set("User-Agent", d.userAgent)
+    resp, err := d.client.Do(req)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+        return fmt.Errorf("HTTP 错误: %s", resp.Status)
+    }
+
+    h := sha256.New()
+    if _, err := io.Copy(h, d.limiter.reader(ctx, wrap(resp.Body), url)); err != nil {
+        return timeoutError(ctx, d.timeouts, err)
+    }
+    remote := hex.EncodeToString(h.Sum(nil))
+    local, err := computeDigest(localPath, "sha256")
+    if err != nil {
+        return err
+    }
+    if remote != local {
+        return fmt.Errorf("%w: SHA-256 本地 %s，对照 %s", errCrossCheckMismatch, local, remote)
+    }
+    return nil
+}
+
+// crossCheckAsset 对经过代理下载的资产进行交叉校验，返回内容是否已确认可信
+// 内容不一致时隔离文件、发出告警并返回错误；无法完成校验时保留文件，仅记录警告；ctx 被取消时返回 ctx 的错误
+func (d *Downloader) crossCheckAsset(ctx context.Context, rs *RepoSummary, tag string, asset Asset, localPath, proxy, mode string) (bool, error) {
+    // 交叉校验的请求同样占用仓库的连接（按源站计算）
+    release, err := d.sched.acquire(ctx, rs.Repo, asset.BrowserDownloadURL, "")
+    if err != nil {
+        return false, err
+    }
+    err = d.crossCheck(ctx, asset.BrowserDownloadURL, localPath, proxy, mode)
+    release()
+    if ctx.Err() != nil {
+        return false, ctx.Err()
+    }
+    if err == nil {
+        logger.Info("✅ 交叉校验通过: %s", asset.Name)
+        return true, nil
+    }
+    if !errors.Is(err, errCrossCheckMismatch) {
+        logger.Warn("⚠️ 无法交叉校验 %s: %v", asset.Name, err)
+        return false, nil
+    }
+
+    rs.addQuarantined()
+    rec := quarantineRecord{
+        Time:   time.Now(),
+        Repo:   rs.Repo,
+        Tag:    tag,
+        Asset:  asset.Name,
+        URL:    asset.BrowserDownloadURL,
+        Proxy:  proxy,
+        Reason: err.Error(),
+    }
+    if dest, qerr := d.quarantine(localPath, rec); qerr != nil {
+        logger.Error("无法隔离 %s: %v，已删除", asset.Name, qerr)
+        os.Remove(localPath)
+    } else {
+        logger.Error("🚨 代理 %s 返回的 %s 可能被篡改（%v），已隔离到 %s", proxy, asset.Name, err, dest)
+    }
+    d.emit(Event{Type: EventAssetQuarantined, Repo: rs.Repo, Tag: tag, Asset: asset.Name, Size: asset.Size, Proxy: proxy, Error: err.Error()})
+    return false, err
+}
+
+// quarantineRecord 是隔离文件旁的说明
+type quarantineRecord struct {
+    Time   time.Time `json:"time"`
+    Repo   string    `json:"repo"`
+    Tag    string    `json:"tag"`
+    Asset  string    `json:"asset"`
+    URL    string    `json:"url"`
+    Proxy  string    `json:"proxy"`
+    Reason string    `json:"reason"`
+}
+
+// quarantine 将交叉校验不一致的文件移出版本目录，放入 <下载目录>/.quarantine/<仓库>/<版本>/
+// 并在旁边写入 .json 说明，返回隔离后的路径
+func (d *Downloader) quarantine(localPath string, rec quarantineRecord) (string, error) {
+    dir := filepath.Join(d.topDir, quarantineDir, filepath.Base(rec.Repo), rec.Tag)
+    if err := os.MkdirAll(dir, 0755); err != nil {
+        return "", err
+    }
+    dest := filepath.Join(dir, fmt.Sprintf("%s.%s", rec.Asset, rec.Time.Format("20060102-150405")))
+    if err := os.Rename(localPath, dest); err != nil {
+        return "", err
+    }
+    data, _ := json.MarshalIndent(rec, "", "  ")
+    if err := os.WriteFile(dest+".json", data, 0644); err != nil {
+        logger.Warn("无法写入隔离说明: %v", err)
+    }
+    return dest, nil
+}
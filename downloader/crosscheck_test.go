@@ -0,0 +1,61 @@
+package downloader
+
+import (
+    "bytes"
+    "context"
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "path/filepath"
+    "testing"
+)
+
+func TestCrossCheckOriginComparesFullContent(t *testing.T) {
+    local := bytes.Repeat([]byte("0123456789abcdef"), 64*1024) // 1 MiB
+    tampered := bytes.Clone(local)
+    tampered[len(tampered)/2+12345] ^= 0xff // 只改动中间一个字节
+
+    tests := []struct {
+        name     string
+        origin   []byte
+        mismatch bool
+    }{
+        {"相同内容", local, false},
+        {"中间一个字节不同", tampered, true},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                if r.Header.Get("Range") != "" {
+                    t.Errorf("源站校验不应使用范围请求: %s", r.Header.Get("Range"))
+                }
+                w.Write(tt.origin)
+            }))
+            defer srv.Close()
+
+            path := filepath.Join(t.TempDir(), "asset.bin")
+            writeFile(t, path, string(local))
+            d := NewDownloader(t.TempDir(), nil)
+            err := d.crossCheck(context.Background(), srv.URL+"/asset.bin", path, "https://proxy.example/", CrossCheckOrigin)
+            if got := errors.Is(err, errCrossCheckMismatch); got != tt.mismatch {
+                t.Fatalf("crossCheck() = %v, want mismatch %v", err, tt.mismatch)
+            }
+            if !tt.mismatch && err != nil {
+                t.Fatalf("crossCheck() = %v", err)
+            }
+        })
+    }
+}
+
+func TestCrossCheckOriginUnavailable(t *testing.T) {
+    srv := httptest.NewServer(http.NotFoundHandler())
+    defer srv.Close()
+
+    path := filepath.Join(t.TempDir(), "asset.bin")
+    writeFile(t, path, "hello")
+    d := NewDownloader(t.TempDir(), nil)
+    err := d.crossCheck(context.Background(), srv.URL+"/asset.bin", path, "", CrossCheckOrigin)
+    if err == nil || errors.Is(err, errCrossCheckMismatch) {
+        t.Fatalf("源站不可用时 crossCheck() = %v, want 非不一致的错误", err)
+    }
+}
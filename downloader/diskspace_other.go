@@ -0,0 +1,21 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package downloader
+
+import (
+    "errors"
+    "os"
+)
+
+// diskFree 在其他平台上不可用，跳过磁盘空间检查
+func diskFree(path string) (int64, error) {
+    return 0, errors.ErrUnsupported
+}
+
+func fileID(info os.FileInfo) ([2]uint64, bool) {
+    return [2]uint64{}, false
+}
+
+func isDiskFull(err error) bool {
+    return false
+}
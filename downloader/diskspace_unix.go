@@ -0,0 +1,32 @@
+//go:build linux || darwin || freebsd
+
+package downloader
+
+import (
+    "errors"
+    "os"
+    "syscall"
+)
+
+// diskFree 返回 path 所在文件系统中当前用户可用的空间
+func diskFree(path string) (int64, error) {
+    var st syscall.Statfs_t
+    if err := syscall.Statfs(path, &st); err != nil {
+        return 0, err
+    }
+    return int64(uint64(st.Bavail) * uint64(st.Bsize)), nil
+}
+
+// fileID 返回文件的设备号和 inode，用于统计占用空间时不重复计算硬链接
+func fileID(info os.FileInfo) ([2]uint64, bool) {
+    st, ok := info.Sys().(*syscall.Stat_t)
+    if !ok {
+        return [2]uint64{}, false
+    }
+    return [2]uint64{uint64(st.Dev), uint64(st.Ino)}, true
+}
+
+// isDiskFull 判断写入错误是否由磁盘已满导致
+func isDiskFull(err error) bool {
+    return errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EDQUOT)
+}
@@ -0,0 +1,34 @@
+package downloader
+
+import (
+    "errors"
+    "os"
+    "syscall"
+    "unsafe"
+)
+
+var procGetDiskFreeSpaceEx = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+
+// diskFree 返回 path 所在磁盘中当前用户可用的空间
+func diskFree(path string) (int64, error) {
+    p, err := syscall.UTF16PtrFromString(path)
+    if err != nil {
+        return 0, err
+    }
+    var avail uint64
+    r, _, err := procGetDiskFreeSpaceEx.Call(uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(&avail)), 0, 0)
+    if r == 0 {
+        return 0, err
+    }
+    return int64(avail), nil
+}
+
+// fileID 在 Windows 上不可用，硬链接的文件会重复计算
+func fileID(info os.FileInfo) ([2]uint64, bool) {
+    return [2]uint64{}, false
+}
+
+// isDiskFull 判断写入错误是否由磁盘已满导致（ERROR_HANDLE_DISK_FULL、ERROR_DISK_FULL）
+func isDiskFull(err error) bool {
+    return errors.Is(err, syscall.Errno(39)) || errors.Is(err, syscall.Errno(112))
+}
@@ -1,10 +1,10 @@
 package downloader
 
 import (
-    "bufio"
+    "context"
     "crypto/sha256"
     "encoding/hex"
-    "encoding/json"
+    "errors"
     "fmt"
     "io"
     "net/http"
@@ -13,9 +13,9 @@ import (
     "regexp"
     "strconv"
     "strings"
+    "sync"
     "time"
 
-    "github.com/schollz/progressbar/v3"
     "github-downloader/logger"
 )
 
@@ -48,47 +48,85 @@ type GitLabAsset struct {
 
 // Release 表示 GitHub release 信息
 type Release struct {
-    TagName string  `json:"tag_name"`
-    Body    string  `json:"body"`
-    Assets  []Asset `json:"assets"`
+    ID          int64     `json:"id"`
+    TagName     string    `json:"tag_name"`
+    Body        string    `json:"body"`
+    Prerelease  bool      `json:"prerelease"`
+    PublishedAt time.Time `json:"published_at"`
+    Assets      []Asset   `json:"assets"`
 }
 
 // GitLabRelease 表示 GitLab release 信息
 type GitLabRelease struct {
-    TagName     string        `json:"tag_name"`
-    Description string        `json:"description"`
-    Assets      struct {
+    TagName         string        `json:"tag_name"`
+    Description     string        `json:"description"`
+    ReleasedAt      time.Time     `json:"released_at"`
+    UpcomingRelease bool          `json:"upcoming_release"`
+    Assets          struct {
         Links []GitLabAsset `json:"links"`
     } `json:"assets"`
 }
 
+// RepoOptions 是单个仓库的下载选项
+type RepoOptions struct {
+    Proxy  string      // 指定代理，为空则使用全局代理列表
+    Filter AssetFilter // 资产筛选规则
+    Verify string      // 合并校验文件的严格程度（VerifyStrict 等），为空时使用 VerifyNormal
+    Keys   TrustedKeys // 验证签名使用的受信任公钥
+    CrossCheck string  // 代理内容交叉校验模式（CrossCheckOrigin 等），为空时不校验
+    Timeouts Timeouts  // 下载超时，为 0 的值使用全局超时
+}
+
 // Downloader 处理下载逻辑
 type Downloader struct {
     topDir     string
     proxies    []string // 全局代理列表
-    client     *http.Client
+    client     *http.Client // 使用全局超时的 http.Client
+    timeouts   Timeouts     // 全局超时
     userAgent  string
+    summary    *Summary     // 本次运行的处理结果汇总
+    events     EventHandler // 事件处理函数，为 nil 时不产生事件
+    state      *State       // 本地状态库，为 nil 时不使用
+    cacheDir   string       // API 响应缓存目录，为空时不缓存
+    cas        bool         // 是否将资产放入内容寻址存储
+    quota      diskQuota    // 下载前的磁盘空间和配额检查
+    latestJSON bool         // 更新最新版本指针时是否写入 latest.json
+    sched      *scheduler   // 在所有下载之间分配连接
+    limiter    rateLimiter  // 所有下载共享的限速
+
+    clientsMu sync.Mutex
+    clients   map[transportKey]*http.Client // 按超时区分的 http.Client，通过 clientFor() 获取
+
+    progressOnce sync.Once
+    progress     *progressDisplay // 下载进度显示，通过 display() 获取
+
+    latestMu    sync.Mutex
+    latestLocks map[string]*sync.Mutex // 仓库目录 -> 更新最新版本指针的锁，通过 lockLatest() 获取
 }
 
 // NewDownloader 创建下载器
 func NewDownloader(topDir string, proxies []string) *Downloader {
-    return &Downloader{
-        topDir:  topDir,
-        proxies: proxies,
-        client: &http.Client{
-            Timeout: 300 * time.Second,
-        },
+    d := &Downloader{
+        topDir:    topDir,
+        proxies:   proxies,
         userAgent: "Mozilla/5.0 (compatible; GithubDownloader/1.0)",
+        summary:   newSummary(),
+        sched:     newScheduler(1, 0, 0),
     }
+    d.SetTimeouts(Timeouts{})
+    return d
 }
 
 // ProcessRepo 处理单个仓库（仅最新版本）
-func (d *Downloader) ProcessRepo(owner, repo, specifiedProxy string) error {
+func (d *Downloader) ProcessRepo(ctx context.Context, owner, repo string, opts RepoOptions) (err error) {
+    rs := d.beginRepo(owner, repo)
+    defer func() { d.finishRepo(rs, err) }()
+
     logger.Info("========================================")
     logger.Info("开始处理仓库: %s/%s", owner, repo)
 
     // 1. 获取最新 release 信息
-    release, err := d.fetchLatestRelease(owner, repo)
+    release, err := d.fetchLatestRelease(ctx, owner, repo)
     if err != nil {
         logger.Error("获取 release 失败: %v", err)
         return err
@@ -100,49 +138,7 @@ func (d *Downloader) ProcessRepo(owner, repo, specifiedProxy string) error {
 
     logger.Info("当前版本: %s", release.TagName)
 
-    // 2. 创建版本目录
-    versionDir := filepath.Join(d.topDir, repo, release.TagName)
-    if err := os.MkdirAll(versionDir, 0755); err != nil {
-        logger.Error("无法创建目录 %s: %v", versionDir, err)
-        return err
-    }
-
-    // 3. 保存 release notes
-    notesFile := filepath.Join(versionDir, "release_notes.txt")
-    notesContent := release.Body
-    if notesContent == "" {
-        notesContent = "No release notes provided"
-    }
-    if err := os.WriteFile(notesFile, []byte(notesContent), 0644); err != nil {
-        logger.Warn("无法写入 release notes: %v", err)
-    } else {
-        logger.Info("Release 日志已保存到: %s", notesFile)
-    }
-
-    // 4. 处理每个资产
-    var downloadedFiles []string
-    for _, asset := range release.Assets {
-        // 提取 SHA256（如果存在）
-        sha256 := extractSHA256(asset.Digest)
-        if sha256 != "" {
-            logger.Info("官方 SHA256: %s", sha256)
-        } else {
-            logger.Info("没有可用的官方 SHA256 哈希值")
-        }
-
-        // 下载文件（使用代理列表）
-        localPath := filepath.Join(versionDir, asset.Name)
-        if err := d.downloadFileWithProxyList(asset.BrowserDownloadURL, localPath, asset.Size, sha256, specifiedProxy); err != nil {
-            logger.Error("下载 %s 失败: %v", asset.Name, err)
-            continue
-        }
-        downloadedFiles = append(downloadedFiles, localPath)
-        logger.Info("完成下载: %s", asset.Name)
-    }
-
-    // 5. 校验文件
-    if err := d.verifyFiles(versionDir, release, downloadedFiles); err != nil {
-        logger.Error("校验失败: %v", err)
+    if err := d.processRelease(ctx, RepoRef{Owner: owner, Repo: repo}, release, opts, rs); err != nil {
         return err
     }
 
@@ -151,12 +147,15 @@ func (d *Downloader) ProcessRepo(owner, repo, specifiedProxy string) error {
 }
 
 // ProcessRepoAll 处理单个仓库的所有版本
-func (d *Downloader) ProcessRepoAll(owner, repo, specifiedProxy string) error {
+func (d *Downloader) ProcessRepoAll(ctx context.Context, owner, repo string, opts RepoOptions) (err error) {
+    rs := d.beginRepo(owner, repo)
+    defer func() { d.finishRepo(rs, err) }()
+
     logger.Info("========================================")
     logger.Info("开始处理仓库: %s/%s（所有版本）", owner, repo)
 
     // 1. 获取所有 release 信息
-    releases, err := d.fetchAllReleases(owner, repo)
+    releases, err := d.fetchAllReleases(ctx, owner, repo)
     if err != nil {
         logger.Error("获取所有 release 失败: %v", err)
         return err
@@ -166,73 +165,17 @@ func (d *Downloader) ProcessRepoAll(owner, repo, specifiedProxy string) error {
         return nil
     }
 
-    logger.Info("找到 %d 个 Release 版本", len(releases))
-
-    // 2. 遍历处理每个 release
-    for i, release := range releases {
-        if release.TagName == "" {
-            logger.Warn("跳过空版本号的 Release")
-            continue
-        }
-
-        logger.Info("========================================")
-        logger.Info("处理版本 %d/%d: %s", i+1, len(releases), release.TagName)
-
-        // 3. 创建版本目录
-        versionDir := filepath.Join(d.topDir, repo, release.TagName)
-        if err := os.MkdirAll(versionDir, 0755); err != nil {
-            logger.Error("无法创建目录 %s: %v", versionDir, err)
-            continue
-        }
-
-        // 4. 保存 release notes
-        notesFile := filepath.Join(versionDir, "release_notes.txt")
-        notesContent := release.Body
-        if notesContent == "" {
-            notesContent = "No release notes provided"
-        }
-        if err := os.WriteFile(notesFile, []byte(notesContent), 0644); err != nil {
-            logger.Warn("无法写入 release notes: %v", err)
-        } else {
-            logger.Info("Release 日志已保存到: %s", notesFile)
-        }
-
-        // 5. 处理每个资产
-        var downloadedFiles []string
-        for _, asset := range release.Assets {
-            // 提取 SHA256（如果存在）
-            sha256 := extractSHA256(asset.Digest)
-            if sha256 != "" {
-                logger.Info("官方 SHA256: %s", sha256)
-            } else {
-                logger.Info("没有可用的官方 SHA256 哈希值")
-            }
-
-            // 下载文件（使用代理列表）
-            localPath := filepath.Join(versionDir, asset.Name)
-            if err := d.downloadFileWithProxyList(asset.BrowserDownloadURL, localPath, asset.Size, sha256, specifiedProxy); err != nil {
-                logger.Error("下载 %s 失败: %v", asset.Name, err)
-                continue
-            }
-            downloadedFiles = append(downloadedFiles, localPath)
-            logger.Info("完成下载: %s", asset.Name)
-        }
-
-        // 6. 校验文件
-        if err := d.verifyFiles(versionDir, release, downloadedFiles); err != nil {
-            logger.Error("校验失败: %v", err)
-            continue
-        }
-
-        logger.Info("版本 %s 处理完成", release.TagName)
-    }
+    d.processReleases(ctx, RepoRef{Owner: owner, Repo: repo}, releases, opts, rs)
 
     logger.Info("仓库 %s/%s 所有版本处理完成", owner, repo)
     return nil
 }
 
 // ProcessGitLabRepo 处理单个 GitLab 仓库（仅最新版本）
-func (d *Downloader) ProcessGitLabRepo(gitLabHost, owner, repo, specifiedProxy string) error {
+func (d *Downloader) ProcessGitLabRepo(ctx context.Context, gitLabHost, owner, repo string, opts RepoOptions) (err error) {
+    rs := d.beginRepo(owner, repo)
+    defer func() { d.finishRepo(rs, err) }()
+
     // 如果未指定主机名，使用默认值
     if gitLabHost == "" {
         gitLabHost = defaultGitLabHost
@@ -243,7 +186,7 @@ func (d *Downloader) ProcessGitLabRepo(gitLabHost, owner, repo, specifiedProxy s
     logger.Info("GitLab 实例: %s", gitLabHost)
 
     // 1. 获取最新 release 信息
-    release, err := d.fetchGitLabLatestRelease(gitLabHost, owner, repo)
+    release, err := d.fetchGitLabLatestRelease(ctx, gitLabHost, owner, repo)
     if err != nil {
         logger.Error("获取 release 失败: %v", err)
         return err
@@ -255,44 +198,7 @@ func (d *Downloader) ProcessGitLabRepo(gitLabHost, owner, repo, specifiedProxy s
 
     logger.Info("当前版本: %s", release.TagName)
 
-    // 2. 创建版本目录
-    versionDir := filepath.Join(d.topDir, repo, release.TagName)
-    if err := os.MkdirAll(versionDir, 0755); err != nil {
-        logger.Error("无法创建目录 %s: %v", versionDir, err)
-        return err
-    }
-
-    // 3. 保存 release notes
-    notesFile := filepath.Join(versionDir, "release_notes.txt")
-    notesContent := release.Body
-    if notesContent == "" {
-        notesContent = "No release notes provided"
-    }
-    if err := os.WriteFile(notesFile, []byte(notesContent), 0644); err != nil {
-        logger.Warn("无法写入 release notes: %v", err)
-    } else {
-        logger.Info("Release 日志已保存到: %s", notesFile)
-    }
-
-    // 4. 处理每个资产
-    var downloadedFiles []string
-    for _, asset := range release.Assets {
-        // GitLab 资产没有官方 SHA256
-        logger.Info("没有可用的官方 SHA256 哈希值")
-
-        // 下载文件（使用代理列表）
-        localPath := filepath.Join(versionDir, asset.Name)
-        if err := d.downloadFileWithProxyList(asset.BrowserDownloadURL, localPath, asset.Size, "", specifiedProxy); err != nil {
-            logger.Error("下载 %s 失败: %v", asset.Name, err)
-            continue
-        }
-        downloadedFiles = append(downloadedFiles, localPath)
-        logger.Info("完成下载: %s", asset.Name)
-    }
-
-    // 5. 校验文件
-    if err := d.verifyFiles(versionDir, release, downloadedFiles); err != nil {
-        logger.Error("校验失败: %v", err)
+    if err := d.processRelease(ctx, RepoRef{GitLab: true, Host: gitLabHost, Owner: owner, Repo: repo}, release, opts, rs); err != nil {
         return err
     }
 
@@ -301,7 +207,10 @@ func (d *Downloader) ProcessGitLabRepo(gitLabHost, owner, repo, specifiedProxy s
 }
 
 // ProcessGitLabRepoAll 处理单个 GitLab 仓库的所有版本
-func (d *Downloader) ProcessGitLabRepoAll(gitLabHost, owner, repo, specifiedProxy string) error {
+func (d *Downloader) ProcessGitLabRepoAll(ctx context.Context, gitLabHost, owner, repo string, opts RepoOptions) (err error) {
+    rs := d.beginRepo(owner, repo)
+    defer func() { d.finishRepo(rs, err) }()
+
     // 如果未指定主机名，使用默认值
     if gitLabHost == "" {
         gitLabHost = defaultGitLabHost
@@ -312,7 +221,7 @@ func (d *Downloader) ProcessGitLabRepoAll(gitLabHost, owner, repo, specifiedProx
     logger.Info("GitLab 实例: %s", gitLabHost)
 
     // 1. 获取所有 release 信息
-    releases, err := d.fetchGitLabAllReleases(gitLabHost, owner, repo)
+    releases, err := d.fetchGitLabAllReleases(ctx, gitLabHost, owner, repo)
     if err != nil {
         logger.Error("获取所有 release 失败: %v", err)
         return err
@@ -322,146 +231,360 @@ func (d *Downloader) ProcessGitLabRepoAll(gitLabHost, owner, repo, specifiedProx
         return nil
     }
 
+    d.processReleases(ctx, RepoRef{GitLab: true, Host: gitLabHost, Owner: owner, Repo: repo}, releases, opts, rs)
+
+    logger.Info("GitLab 仓库 %s/%s 所有版本处理完成", owner, repo)
+    return nil
+}
+
+// versionDir 返回版本的本地目录：<topDir>/<仓库名>/<版本号>
+func (d *Downloader) versionDir(repo, tag string) string {
+    return filepath.Join(d.topDir, repo, tag)
+}
+
+// processReleases 处理多个版本，同时处理的版本数不超过总连接数；单个版本失败不影响其他版本
+// ctx 被取消后不再开始处理新的版本
+func (d *Downloader) processReleases(ctx context.Context, ref RepoRef, releases []*Release, opts RepoOptions, rs *RepoSummary) {
     logger.Info("找到 %d 个 Release 版本", len(releases))
 
-    // 2. 遍历处理每个 release
-    for i, release := range releases {
+    // 空间不足时不删除本次将要下载的版本
+    dirs := make([]string, 0, len(releases))
+    for _, release := range releases {
+        if release.TagName != "" {
+            dirs = append(dirs, d.versionDir(ref.Repo, release.TagName))
+        }
+    }
+    defer d.quota.protect(dirs...)()
+
+    d.forEach(len(releases), func(i int) {
+        release := releases[i]
+        if ctx.Err() != nil {
+            return
+        }
         if release.TagName == "" {
             logger.Warn("跳过空版本号的 Release")
-            continue
+            return
         }
 
         logger.Info("========================================")
         logger.Info("处理版本 %d/%d: %s", i+1, len(releases), release.TagName)
 
-        // 3. 创建版本目录
-        versionDir := filepath.Join(d.topDir, repo, release.TagName)
-        if err := os.MkdirAll(versionDir, 0755); err != nil {
-            logger.Error("无法创建目录 %s: %v", versionDir, err)
-            continue
+        if err := d.processRelease(ctx, ref, release, opts, rs); err != nil {
+            return
         }
 
-        // 4. 保存 release notes
-        notesFile := filepath.Join(versionDir, "release_notes.txt")
-        notesContent := release.Body
-        if notesContent == "" {
-            notesContent = "No release notes provided"
-        }
-        if err := os.WriteFile(notesFile, []byte(notesContent), 0644); err != nil {
-            logger.Warn("无法写入 release notes: %v", err)
-        } else {
-            logger.Info("Release 日志已保存到: %s", notesFile)
+        logger.Info("版本 %s 处理完成", release.TagName)
+    })
+}
+
+// forEach 并发调用 fn(0) 到 fn(n-1)，同时进行的调用数不超过总连接数，全部完成后返回
+// 实际的下载连接由调度器分配，这里只避免同时创建过多的 goroutine 和打开过多的文件
+func (d *Downloader) forEach(n int, fn func(i int)) {
+    limit := d.sched.total
+    if limit <= 0 || limit > n {
+        limit = n
+    }
+    if limit <= 1 {
+        for i := 0; i < n; i++ {
+            fn(i)
         }
+        return
+    }
+    var wg sync.WaitGroup
+    sem := make(chan struct{}, limit)
+    for i := 0; i < n; i++ {
+        wg.Add(1)
+        sem <- struct{}{}
+        go func(i int) {
+            defer wg.Done()
+            defer func() { <-sem }()
+            fn(i)
+        }(i)
+    }
+    wg.Wait()
+}
 
-        // 5. 处理每个资产
-        var downloadedFiles []string
-        for _, asset := range release.Assets {
-            // GitLab 资产没有官方 SHA256
-            logger.Info("没有可用的官方 SHA256 哈希值")
+// processRelease 下载单个版本：创建版本目录、保存 release notes、下载选中的资产并校验
+// 下载和校验结果记录到 rs 中；ctx 被取消时不再开始新的下载，写入 manifest 后返回，不进行校验
+func (d *Downloader) processRelease(ctx context.Context, ref RepoRef, release *Release, opts RepoOptions, rs *RepoSummary) error {
+    if err := ctx.Err(); err != nil {
+        return err
+    }
+    rs.addRelease()
 
-            // 下载文件（使用代理列表）
-            localPath := filepath.Join(versionDir, asset.Name)
-            if err := d.downloadFileWithProxyList(asset.BrowserDownloadURL, localPath, asset.Size, "", specifiedProxy); err != nil {
-                logger.Error("下载 %s 失败: %v", asset.Name, err)
-                continue
-            }
-            downloadedFiles = append(downloadedFiles, localPath)
-            logger.Info("完成下载: %s", asset.Name)
-        }
+    // 1. 创建版本目录
+    versionDir := d.versionDir(ref.Repo, release.TagName)
+    if err := os.MkdirAll(versionDir, 0755); err != nil {
+        logger.Error("无法创建目录 %s: %v", versionDir, err)
+        rs.failRelease()
+        return err
+    }
 
-        // 6. 校验文件
-        if err := d.verifyFiles(versionDir, release, downloadedFiles); err != nil {
-            logger.Error("校验失败: %v", err)
-            continue
-        }
+    // 2. 保存 release notes
+    notesFile := filepath.Join(versionDir, releaseNotesFile)
+    notesContent := release.Body
+    if notesContent == "" {
+        notesContent = "No release notes provided"
+    }
+    if err := os.WriteFile(notesFile, []byte(notesContent), 0644); err != nil {
+        logger.Warn("无法写入 release notes: %v", err)
+    } else {
+        logger.Info("Release 日志已保存到: %s", notesFile)
+    }
 
-        logger.Info("版本 %s 处理完成", release.TagName)
+    // 3. 按筛选规则选择资产
+    selected := *release
+    selected.Assets = opts.Filter.Select(release.Assets)
+    skipped := len(release.Assets) - len(selected.Assets)
+    if skipped > 0 {
+        logger.Info("按筛选规则选择 %d 个资产，跳过 %d 个", len(selected.Assets), skipped)
     }
+    d.emit(Event{Type: EventReleaseResolved, Repo: rs.Repo, Tag: release.TagName, Prerelease: release.Prerelease, Assets: len(selected.Assets), Excluded: skipped})
 
-    logger.Info("GitLab 仓库 %s/%s 所有版本处理完成", owner, repo)
-    return nil
-}
+    // 上次已成功处理且资产和文件均未变化的版本直接跳过，不读取文件内容
+    fingerprint := releaseFingerprint(selected.Assets, opts)
+    if d.state.releaseUnchanged(ref, versionDir, release.TagName, fingerprint, selected.Assets) {
+        logger.Info("版本 %s 未变化（状态库），跳过", release.TagName)
+        for _, asset := range selected.Assets {
+            res := &fetchResult{skipped: true}
+            rs.recordAsset(res, nil)
+            d.emit(assetDoneEvent(rs.Repo, release.TagName, asset, res, nil))
+        }
+        d.emit(Event{Type: EventVerifyResult, Repo: rs.Repo, Tag: release.TagName, Status: VerifyOK})
+        d.updateLatest(versionDir, release)
+        return nil
+    }
 
-// fetchLatestRelease 调用 GitHub API 获取最新 release
-func (d *Downloader) fetchLatestRelease(owner, repo string) (*Release, error) {
-    url := fmt.Sprintf(githubAPI, owner, repo)
-    req, err := http.NewRequest("GET", url, nil)
+    // 下载前检查磁盘空间和配额，空间不足时不开始下载，避免留下不完整的文件
+    releaseSpace, err := d.claimSpace(versionDir, selected.Assets)
     if err != nil {
-        return nil, err
+        logger.Error("%v", err)
+        rs.failRelease()
+        for _, asset := range selected.Assets {
+            rs.recordAsset(nil, err)
+            d.emit(assetDoneEvent(rs.Repo, release.TagName, asset, nil, err))
+        }
+        return err
     }
-    req.Header.Set("User-Agent", d.userAgent)
-    // 可选的 GitHub Token：设置 Authorization: token YOUR_TOKEN
+    defer releaseSpace()
 
-    resp, err := d.client.Do(req)
+    // 4. 处理每个资产（单文件校验文件先下载，用于校验对应的资产）
+    sidecars := pairSidecars(selected.Assets)
+    verified := make(map[string]bool)
+    // trusted 是内容不依赖代理的资产：通过官方哈希验证、直接下载或交叉校验通过
+    trusted := make(map[string]bool)
+    crossMode, err := ParseCrossCheckMode(opts.CrossCheck)
     if err != nil {
-        return nil, err
+        logger.Warn("%v，不进行交叉校验", err)
+        crossMode = CrossCheckOff
+    }
+    // 上次运行的 manifest 用于判断文件是否未变化，以及沿用未重新下载文件的来源信息
+    prev, err := ReadManifest(versionDir)
+    if err != nil && !os.IsNotExist(err) {
+        logger.Warn("无法读取 %s: %v", manifestFile, err)
+    }
+    manifest := newManifest(ref, release)
+    // 资产并发下载，结果按 ordered 中的顺序记录，使 manifest 与下载完成的先后无关
+    ordered := sidecarsFirst(selected.Assets, sidecars)
+    records := make([]*ManifestAsset, len(ordered))
+    done := make([]bool, len(ordered))
+    var mu sync.Mutex // 保护 verified、trusted
+    fetch := func(i int) {
+        asset := ordered[i]
+        if err := ctx.Err(); err != nil {
+            rs.recordAsset(nil, err)
+            d.emit(assetDoneEvent(rs.Repo, release.TagName, asset, nil, err))
+            return
+        }
+        // 提取 SHA256（如果存在）
+        expected := officialDigest(asset)
+        official := !expected.IsZero()
+        sidecar, hasSidecar := sidecars[asset.Name]
+        if official {
+            logger.Info("官方 SHA256: %s", expected.Hex)
+        } else if hasSidecar {
+            // 没有官方哈希时使用单文件校验文件中的哈希
+            if dg, err := readSidecar(filepath.Join(versionDir, sidecar), asset.Name); err != nil {
+                logger.Warn("无法读取校验文件 %s: %v", sidecar, err)
+            } else {
+                expected = dg
+                logger.Info("校验文件 %s 提供的 %s: %s", sidecar, strings.ToUpper(dg.Algo), dg.Hex)
+            }
+        }
+        if expected.IsZero() {
+            logger.Info("没有可用的官方 SHA256 哈希值")
+        }
+
+        // 下载文件（使用代理列表）
+        localPath := filepath.Join(versionDir, asset.Name)
+        d.emit(Event{Type: EventAssetStart, Repo: rs.Repo, Tag: release.TagName, Asset: asset.Name, Size: asset.Size})
+        progress := func(written, total int64) {
+            d.emit(Event{Type: EventAssetProgress, Repo: rs.Repo, Tag: release.TagName, Asset: asset.Name, Size: total, Bytes: written})
+        }
+        var res *fetchResult
+        var err error
+        if prev.Asset(asset.Name).unchanged(localPath, asset, expected) {
+            // 大小、修改时间与 manifest 记录一致，无需重新计算哈希
+            logger.Info("文件未变化（%s）: %s", manifestFile, asset.Name)
+            res = &fetchResult{skipped: true}
+        } else {
+            res, err = d.downloadFileWithProxyList(ctx, rs.Repo, asset.BrowserDownloadURL, localPath, asset.Size, expected, opts.Proxy, opts.Timeouts, progress)
+        }
+        if err == nil && !res.skipped {
+            mu.Lock()
+            sidecarTrusted := hasSidecar && trusted[sidecar]
+            mu.Unlock()
+            ok := false
+            switch {
+            case official || res.proxy == "":
+                ok = true
+            case sidecarTrusted:
+                // 哈希来自已确认可信的校验文件
+                ok = true
+            case crossMode != CrossCheckOff:
+                // 没有官方哈希时，代理返回的内容（包括校验文件）可能被篡改
+                ok, err = d.crossCheckAsset(ctx, rs, release.TagName, asset, localPath, res.proxy, crossMode)
+            }
+            mu.Lock()
+            trusted[asset.Name] = ok
+            mu.Unlock()
+        }
+        rs.recordAsset(res, err)
+        d.emit(assetDoneEvent(rs.Repo, release.TagName, asset, res, err))
+        if err != nil {
+            logger.Error("下载 %s 失败: %v", asset.Name, err)
+            return
+        }
+        done[i] = true
+        if !expected.IsZero() {
+            mu.Lock()
+            verified[asset.Name] = true
+            mu.Unlock()
+        }
+        if ma, err := record(localPath, asset, expected, res, prev.Asset(asset.Name), d.state); err != nil {
+            logger.Warn("无法记录 %s 的下载信息: %v", asset.Name, err)
+        } else {
+            if d.cas {
+                ma.ModTime = d.storeAsset(localPath, ma)
+            }
+            records[i] = &ma
+            sums := map[string]string{"sha256": ma.SHA256}
+            if !expected.IsZero() {
+                sums[expected.Algo] = expected.Hex
+            }
+            d.state.recordFile(localPath, sums)
+        }
+        logger.Info("完成下载: %s", asset.Name)
     }
-    defer resp.Body.Close()
+    // 单文件校验文件全部下载完成后再下载其他资产
+    n := 0
+    for n < len(ordered) && isSidecar(ordered[n].Name, sidecars) {
+        n++
+    }
+    d.forEach(n, fetch)
+    d.forEach(len(ordered)-n, func(i int) { fetch(n + i) })
 
-    if resp.StatusCode != http.StatusOK {
-        return nil, fmt.Errorf("API 返回状态码 %d", resp.StatusCode)
+    var downloadedFiles []string
+    for i, asset := range ordered {
+        if done[i] {
+            downloadedFiles = append(downloadedFiles, filepath.Join(versionDir, asset.Name))
+        }
+        if records[i] != nil {
+            manifest.Assets = append(manifest.Assets, *records[i])
+        }
     }
 
-    var release Release
-    if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
-        return nil, err
+    // 保留本次未处理（如筛选规则变化）但仍在目录中且未改动的资产记录
+    if prev != nil {
+        for _, ma := range prev.Assets {
+            if manifest.Asset(ma.Name) == nil && ma.unchanged(filepath.Join(versionDir, ma.Name), Asset{Size: ma.Size}, Digest{}) {
+                manifest.Assets = append(manifest.Assets, ma)
+            }
+        }
+    }
+    if err := writeManifest(versionDir, manifest); err != nil {
+        logger.Warn("无法写入 %s: %v", manifestFile, err)
+    }
+    if err := ctx.Err(); err != nil {
+        // 资产不完整，校验和生成本地校验文件留到下次运行
+        logger.Warn("版本 %s 已中断，跳过校验", release.TagName)
+        rs.failRelease()
+        return err
     }
-    return &release, nil
-}
 
-// fetchAllReleases 调用 GitHub API 获取所有 release
-func (d *Downloader) fetchAllReleases(owner, repo string) ([]*Release, error) {
-    url := fmt.Sprintf(githubAPIAll, owner, repo)
-    req, err := http.NewRequest("GET", url, nil)
+    // 5. 校验文件
+    mode, err := ParseVerifyMode(opts.Verify)
     if err != nil {
-        return nil, err
+        logger.Warn("%v，使用 %s", err, VerifyNormal)
+        mode = VerifyNormal
     }
-    req.Header.Set("User-Agent", d.userAgent)
-    // 可选的 GitHub Token：设置 Authorization: token YOUR_TOKEN
+    checks, err := d.verifyFiles(versionDir, release, selected.Assets, downloadedFiles, verified, mode)
 
-    resp, err := d.client.Do(req)
+    // 6. 使用受信任公钥验证签名（签名可能针对资产，也可能针对合并校验文件）
+    sigs, sigErr := d.verifySignatures(versionDir, selected.Assets, opts.Keys)
+    switch {
+    case err == nil:
+        err = sigErr
+    case sigErr != nil:
+        err = fmt.Errorf("%v；%v", err, sigErr)
+    }
+    d.state.recordRelease(ref, release, fingerprint, err == nil)
     if err != nil {
-        return nil, err
+        logger.Error("校验失败: %v", err)
+        rs.failRelease()
+        d.emit(Event{Type: EventVerifyResult, Repo: rs.Repo, Tag: release.TagName, Status: VerifyFailed, Error: err.Error(), Checks: checks, Signatures: sigs})
+        return err
     }
-    defer resp.Body.Close()
+    d.emit(Event{Type: EventVerifyResult, Repo: rs.Repo, Tag: release.TagName, Status: VerifyOK, Checks: checks, Signatures: sigs})
+    // 校验通过后才更新最新版本指针
+    d.updateLatest(versionDir, release)
+    return nil
+}
 
-    if resp.StatusCode != http.StatusOK {
-        return nil, fmt.Errorf("API 返回状态码 %d", resp.StatusCode)
+// assetDoneEvent 根据下载结果生成 asset_done 事件
+func assetDoneEvent(repo, tag string, asset Asset, res *fetchResult, err error) Event {
+    e := Event{Type: EventAssetDone, Repo: repo, Tag: tag, Asset: asset.Name, Size: asset.Size}
+    switch {
+    case err != nil:
+        e.Status = AssetFailed
+        e.Error = err.Error()
+    case res.skipped:
+        e.Status = AssetSkipped
+    default:
+        e.Status = AssetDownloaded
+        e.Bytes = res.bytes
+        e.Proxy = res.proxy
+    }
+    return e
+}
+
+// fetchLatestRelease 调用 GitHub API 获取最新 release
+func (d *Downloader) fetchLatestRelease(ctx context.Context, owner, repo string) (*Release, error) {
+    var release Release
+    if err := d.getJSON(ctx, fmt.Sprintf(githubAPI, owner, repo), &release); err != nil {
+        return nil, err
     }
+    return &release, nil
+}
 
+// fetchAllReleases 调用 GitHub API 获取所有 release
+func (d *Downloader) fetchAllReleases(ctx context.Context, owner, repo string) ([]*Release, error) {
     var releases []*Release
-    if err := json.NewDecoder(resp.Body).Decode(&releases); err != nil {
+    if err := d.getJSON(ctx, fmt.Sprintf(githubAPIAll, owner, repo), &releases); err != nil {
         return nil, err
     }
     return releases, nil
 }
 
 // fetchGitLabLatestRelease 调用 GitLab API 获取最新 release
-func (d *Downloader) fetchGitLabLatestRelease(gitLabHost, owner, repo string) (*Release, error) {
+func (d *Downloader) fetchGitLabLatestRelease(ctx context.Context, gitLabHost, owner, repo string) (*Release, error) {
     // 如果未指定主机名，使用默认值
     if gitLabHost == "" {
         gitLabHost = defaultGitLabHost
     }
     
-    url := fmt.Sprintf(gitlabAPIAllFormat, gitLabHost, owner, repo)
-    req, err := http.NewRequest("GET", url, nil)
-    if err != nil {
-        return nil, err
-    }
-    req.Header.Set("User-Agent", d.userAgent)
-
-    resp, err := d.client.Do(req)
-    if err != nil {
-        return nil, err
-    }
-    defer resp.Body.Close()
-
-    if resp.StatusCode != http.StatusOK {
-        return nil, fmt.Errorf("API 返回状态码 %d", resp.StatusCode)
-    }
-
     var gitlabReleases []*GitLabRelease
-    if err := json.NewDecoder(resp.Body).Decode(&gitlabReleases); err != nil {
+    if err := d.getJSON(ctx, fmt.Sprintf(gitlabAPIAllFormat, gitLabHost, owner, repo), &gitlabReleases); err != nil {
         return nil, err
     }
 
@@ -470,57 +593,24 @@ func (d *Downloader) fetchGitLabLatestRelease(gitLabHost, owner, repo string) (*
     }
 
     // 转换为 GitHub Release 格式
-    gitlabRelease := gitlabReleases[0]
-    release := &Release{
-        TagName: gitlabRelease.TagName,
-        Body:    gitlabRelease.Description,
-        Assets:  make([]Asset, 0),
-    }
-
-    logger.Info("GitLab 资产数量: %d", len(gitlabRelease.Assets.Links))
-    for i, link := range gitlabRelease.Assets.Links {
+    logger.Info("GitLab 资产数量: %d", len(gitlabReleases[0].Assets.Links))
+    for i, link := range gitlabReleases[0].Assets.Links {
         logger.Info("资产 %d: 名称=%s, 大小=%d, DownloadURL=%s, URL=%s", i, link.Name, link.Size, link.DownloadURL, link.URL)
-        downloadURL := link.DownloadURL
-        if downloadURL == "" {
-            downloadURL = link.URL
-        }
-        asset := Asset{
-            Name:               link.Name,
-            Size:               link.Size,
-            BrowserDownloadURL: downloadURL,
-        }
-        release.Assets = append(release.Assets, asset)
     }
+    release := gitlabReleases[0].toRelease()
 
     return release, nil
 }
 
 // fetchGitLabAllReleases 调用 GitLab API 获取所有 release
-func (d *Downloader) fetchGitLabAllReleases(gitLabHost, owner, repo string) ([]*Release, error) {
+func (d *Downloader) fetchGitLabAllReleases(ctx context.Context, gitLabHost, owner, repo string) ([]*Release, error) {
     // 如果未指定主机名，使用默认值
     if gitLabHost == "" {
         gitLabHost = defaultGitLabHost
     }
     
-    url := fmt.Sprintf(gitlabAPIAllFormat, gitLabHost, owner, repo)
-    req, err := http.NewRequest("GET", url, nil)
-    if err != nil {
-        return nil, err
-    }
-    req.Header.Set("User-Agent", d.userAgent)
-
-    resp, err := d.client.Do(req)
-    if err != nil {
-        return nil, err
-    }
-    defer resp.Body.Close()
-
-    if resp.StatusCode != http.StatusOK {
-        return nil, fmt.Errorf("API 返回状态码 %d", resp.StatusCode)
-    }
-
     var gitlabReleases []*GitLabRelease
-    if err := json.NewDecoder(resp.Body).Decode(&gitlabReleases); err != nil {
+    if err := d.getJSON(ctx, fmt.Sprintf(gitlabAPIAllFormat, gitLabHost, owner, repo), &gitlabReleases); err != nil {
         return nil, err
     }
 
@@ -531,50 +621,60 @@ func (d *Downloader) fetchGitLabAllReleases(gitLabHost, owner, repo string) ([]*
     // 转换为 GitHub Release 格式
     releases := make([]*Release, 0, len(gitlabReleases))
     for _, gitlabRelease := range gitlabReleases {
-        release := &Release{
-            TagName: gitlabRelease.TagName,
-            Body:    gitlabRelease.Description,
-            Assets:  make([]Asset, 0),
-        }
+        releases = append(releases, gitlabRelease.toRelease())
+    }
 
-        for _, link := range gitlabRelease.Assets.Links {
-            asset := Asset{
-                Name:               link.Name,
-                Size:               link.Size,
-                BrowserDownloadURL: link.DownloadURL,
-            }
-            release.Assets = append(release.Assets, asset)
-        }
+    return releases, nil
+}
 
-        releases = append(releases, release)
+// toRelease 将 GitLab release 转换为 GitHub Release 格式
+func (r *GitLabRelease) toRelease() *Release {
+    release := &Release{
+        TagName:     r.TagName,
+        Body:        r.Description,
+        Prerelease:  r.UpcomingRelease,
+        PublishedAt: r.ReleasedAt,
+        Assets:      make([]Asset, 0, len(r.Assets.Links)),
     }
+    for _, link := range r.Assets.Links {
+        downloadURL := link.DownloadURL
+        if downloadURL == "" {
+            downloadURL = link.URL
+        }
+        release.Assets = append(release.Assets, Asset{
+            Name:               link.Name,
+            Size:               link.Size,
+            BrowserDownloadURL: downloadURL,
+        })
+    }
+    return release
+}
 
-    return releases, nil
+// fetchResult 描述单个文件的下载结果
+type fetchResult struct {
+    skipped bool   // 本地文件已完整，未下载
+    proxy   string // 实际使用的代理，直接下载时为空
+    bytes   int64  // 下载的字节数
 }
 
 // downloadFileWithProxyList 尝试使用代理列表下载，支持切换代理和进度条
-func (d *Downloader) downloadFileWithProxyList(url, localPath string, expectedSize int64, expectedSHA256, specifiedProxy string) error {
+// 每次下载尝试都向调度器申请属于仓库 repo 的连接；progress 不为 nil 时会按固定间隔收到已下载的字节数
+// expected 为空时仅校验文件大小；ctx 被取消时立即返回，不再重试或尝试其他代理
+// timeouts 中不为 0 的值覆盖全局超时；经过代理的下载停滞时直接更换下一个代理
+func (d *Downloader) downloadFileWithProxyList(ctx context.Context, repo, url, localPath string, expectedSize int64, expected Digest, specifiedProxy string, timeouts Timeouts, progress func(written, total int64)) (*fetchResult, error) {
+    // 状态库中记录的大小、修改时间和哈希与当前文件一致时无需重新计算哈希
+    if d.state.unchanged(localPath, expectedSize, expected) {
+        logger.Info("文件未变化（状态库）: %s", filepath.Base(localPath))
+        return &fetchResult{skipped: true}, nil
+    }
+
     // 检查本地文件是否已存在且完整
-    if info, err := os.Stat(localPath); err == nil {
-        if info.Size() == expectedSize {
-            if expectedSHA256 != "" {
-                // 验证哈希
-                if ok, err := verifySHA256(localPath, expectedSHA256); err == nil && ok {
-                    logger.Info("文件已存在且哈希匹配: %s", filepath.Base(localPath))
-                    return nil
-                } else if err != nil {
-                    logger.Warn("无法验证哈希: %v", err)
-                } else {
-                    logger.Warn("文件哈希不匹配，重新下载: %s", filepath.Base(localPath))
-                }
-            } else {
-                // 没有哈希，仅大小匹配则视为完整
-                logger.Info("文件已存在且大小匹配: %s", filepath.Base(localPath))
-                return nil
-            }
-        } else {
-            logger.Warn("文件大小不匹配，重新下载: %s", filepath.Base(localPath))
-        }
+    switch state, reason := checkLocalFile(localPath, expectedSize, expected); state {
+    case localComplete:
+        logger.Info("%s: %s", reason, filepath.Base(localPath))
+        return &fetchResult{skipped: true}, nil
+    case localMismatch:
+        logger.Warn("%s，重新下载: %s", reason, filepath.Base(localPath))
         // 不匹配则删除旧文件重新下载
         os.Remove(localPath)
     }
@@ -592,7 +692,8 @@ func (d *Downloader) downloadFileWithProxyList(url, localPath string, expectedSi
         }
     }
 
-    logger.Info("开始下载: %s (大小: %s)", filepath.Base(localPath), byteCountIEC(expectedSize))
+    logger.Info("开始下载: %s (大小: %s)", filepath.Base(localPath), ByteCountIEC(expectedSize))
+    t := d.timeouts.Merge(timeouts)
 
     // 检查是否是 GitLab 链接
     isGitLabURL := strings.Contains(url, "git.ryujinx.app") || strings.Contains(url, "gitlab.com")
@@ -605,11 +706,22 @@ func (d *Downloader) downloadFileWithProxyList(url, localPath string, expectedSi
         
         // 重试机制
         for attempt := 1; attempt <= maxRetries; attempt++ {
-            err := d.downloadWithProgress(url, localPath+".tmp", expectedSize)
+            release, err := d.sched.acquire(ctx, repo, url, "")
+            if err != nil {
+                return nil, err
+            }
+            err = d.downloadWithProgress(ctx, url, localPath+".tmp", expectedSize, !expected.IsZero(), t, progress)
+            release()
+            if errors.Is(err, ErrDiskFull) || ctx.Err() != nil {
+                // 重试无济于事
+                return nil, err
+            }
             if err != nil {
                 lastErr = err
                 logger.Warn("下载失败 (尝试 %d/%d): %v", attempt, maxRetries, err)
-                time.Sleep(retryDelay)
+                if err := sleep(ctx, retryDelay); err != nil {
+                    return nil, err
+                }
                 continue
             }
 
@@ -638,8 +750,8 @@ func (d *Downloader) downloadFileWithProxyList(url, localPath string, expectedSi
             }
 
             // 验证哈希（如果提供）
-            if expectedSHA256 != "" {
-                ok, err := verifySHA256(localPath, expectedSHA256)
+            if !expected.IsZero() {
+                ok, err := verifyDigest(localPath, expected)
                 if err != nil {
                     lastErr = fmt.Errorf("哈希验证失败: %w", err)
                     os.Remove(localPath)
@@ -653,28 +765,48 @@ func (d *Downloader) downloadFileWithProxyList(url, localPath string, expectedSi
                 logger.Info("✅ 文件哈希验证成功: %s", filepath.Base(localPath))
             } else {
                 // 没有官方哈希值，验证文件大小
-                logger.Info("✅ 文件大小验证成功: %s (%s)", filepath.Base(localPath), byteCountIEC(info.Size()))
+                logger.Info("✅ 文件大小验证成功: %s (%s)", filepath.Base(localPath), ByteCountIEC(info.Size()))
             }
 
             // 成功
-            return nil
+            return &fetchResult{bytes: info.Size()}, nil
         }
         
-        return fmt.Errorf("下载失败: %w", lastErr)
+        return nil, fmt.Errorf("下载失败: %w", lastErr)
     } else {
         // GitHub 链接使用代理列表
         for _, proxy := range proxiesToTry {
             // 构建代理 URL
-            proxyURL := strings.Replace(url, "https://github.com", fmt.Sprintf("https://%s/github.com", proxy), 1)
+            proxyURL := buildProxyURL(url, proxy)
             logger.Info("尝试使用代理: %s", proxy)
 
             // 重试机制（每个代理最多尝试 maxRetries 次）
             for attempt := 1; attempt <= maxRetries; attempt++ {
-                err := d.downloadWithProgress(proxyURL, localPath+".tmp", expectedSize)
+                via := proxy
+                if proxyURL == url {
+                    via = ""
+                }
+                release, err := d.sched.acquire(ctx, repo, proxyURL, via)
+                if err != nil {
+                    return nil, err
+                }
+                err = d.downloadWithProgress(ctx, proxyURL, localPath+".tmp", expectedSize, !expected.IsZero(), t, progress)
+                release()
+                if errors.Is(err, ErrDiskFull) || ctx.Err() != nil {
+                    return nil, err
+                }
+                if errors.Is(err, errStalled) && via != "" {
+                    // 代理停滞时重试通常也没有进展，已下载的部分由下一个代理继续（下一个代理的 If-Range 校验不通过时重新下载）
+                    lastErr = err
+                    logger.Warn("代理 %s %v，更换代理", proxy, err)
+                    break
+                }
                 if err != nil {
                     lastErr = err
                     logger.Warn("下载失败 (代理 %s, 尝试 %d/%d): %v", proxy, attempt, maxRetries, err)
-                    time.Sleep(retryDelay)
+                    if err := sleep(ctx, retryDelay); err != nil {
+                        return nil, err
+                    }
                     continue
                 }
 
@@ -703,8 +835,8 @@ func (d *Downloader) downloadFileWithProxyList(url, localPath string, expectedSi
                 }
 
                 // 验证哈希（如果提供）
-                if expectedSHA256 != "" {
-                    ok, err := verifySHA256(localPath, expectedSHA256)
+                if !expected.IsZero() {
+                    ok, err := verifyDigest(localPath, expected)
                     if err != nil {
                         lastErr = fmt.Errorf("哈希验证失败: %w", err)
                         os.Remove(localPath)
@@ -718,60 +850,127 @@ func (d *Downloader) downloadFileWithProxyList(url, localPath string, expectedSi
                     logger.Info("✅ 文件哈希验证成功: %s", filepath.Base(localPath))
                 } else {
                     // 没有官方哈希值，再次验证文件大小
-                    logger.Info("✅ 文件大小验证成功: %s (%s)", filepath.Base(localPath), byteCountIEC(info.Size()))
+                    logger.Info("✅ 文件大小验证成功: %s (%s)", filepath.Base(localPath), ByteCountIEC(info.Size()))
                 }
 
-                // 成功
-                return nil
+                // 成功（非 github.com 链接不会经过代理，via 为空）
+                return &fetchResult{proxy: via, bytes: info.Size()}, nil
             }
 
             // 如果这个代理的所有重试都失败，继续尝试下一个代理
         }
 
-        return fmt.Errorf("所有代理尝试均失败: %w", lastErr)
+        return nil, fmt.Errorf("所有代理尝试均失败: %w", lastErr)
     }
 }
 
-// downloadWithProgress 下载文件并显示进度条
-func (d *Downloader) downloadWithProgress(url, tmpPath string, expectedSize int64) error {
-    // 创建临时文件
-    out, err := os.Create(tmpPath)
+// 本地文件状态
+const (
+    localMissing  = iota // 文件不存在
+    localComplete        // 文件完整，无需下载
+    localMismatch        // 文件存在但大小或哈希不匹配，需要重新下载
+)
+
+// checkLocalFile 检查本地文件是否已存在且完整，返回状态和原因说明
+// 有哈希值时校验哈希，否则仅比较大小
+func checkLocalFile(localPath string, expectedSize int64, expected Digest) (int, string) {
+    info, err := os.Stat(localPath)
     if err != nil {
-        return err
+        return localMissing, "文件不存在"
     }
-    defer out.Close()
-
-    req, err := http.NewRequest("GET", url, nil)
+    if info.Size() != expectedSize {
+        return localMismatch, "文件大小不匹配"
+    }
+    if expected.IsZero() {
+        // 没有哈希，仅大小匹配则视为完整
+        return localComplete, "文件已存在且大小匹配"
+    }
+    // 验证哈希
+    ok, err := verifyDigest(localPath, expected)
     if err != nil {
-        // 下载失败，清理临时文件
-        if _, err := os.Stat(tmpPath); err == nil {
+        return localMismatch, fmt.Sprintf("无法验证哈希: %v", err)
+    }
+    if !ok {
+        return localMismatch, "文件哈希不匹配"
+    }
+    return localComplete, "文件已存在且哈希匹配"
+}
+
+// downloadWithProgress 按超时 t 下载文件并显示进度条
+// resumable 表示下载完成后有期望哈希可以校验，此时 tmpPath 中上次保留的部分内容通过 Range 请求从断点继续，
+// 并用 If-Range 带上首次响应的 ETag 或 Last-Modified：服务器上的文件已变化或不支持时重新下载；
+// 没有期望哈希或没有首次响应的校验值时总是重新下载，避免拼接不同来源的内容
+// ctx 被取消、下载停滞或超时时保留已下载的部分（resumable 为 false 时删除），其他错误时删除临时文件
+func (d *Downloader) downloadWithProgress(parent context.Context, url, tmpPath string, expectedSize int64, resumable bool, t Timeouts, progress func(written, total int64)) error {
+    ctx, wrap, cancel := watch(parent, t)
+    defer cancel()
+    // 下载失败，清理临时文件（中断、停滞或超时时保留，下次继续）
+    discard := func() {
+        if ctx.Err() == nil || !resumable {
             os.Remove(tmpPath)
+            removeResumeInfo(tmpPath)
         }
+    }
+
+    var offset int64
+    var resume *resumeInfo
+    if info, err := os.Stat(tmpPath); err == nil && info.Size() > 0 {
+        if ri := readResumeInfo(tmpPath); resumable && ri != nil && ri.Size == expectedSize && expectedSize > 0 && info.Size() < expectedSize {
+            offset, resume = info.Size(), ri
+        } else {
+            logger.Info("无法确认已下载的部分与服务器上的文件一致，重新下载: %s", strings.TrimSuffix(filepath.Base(tmpPath), ".tmp"))
+        }
+    }
+
+    req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+    if err != nil {
+        discard()
         return err
     }
     req.Header.Set("User-Agent", d.userAgent)
+    if offset > 0 {
+        req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+        req.Header.Set("If-Range", resume.validator())
+    }
 
-    resp, err := d.client.Do(req)
+    resp, err := d.clientFor(t).Do(req)
     if err != nil {
-        // 下载失败，清理临时文件
-        if _, err := os.Stat(tmpPath); err == nil {
-            os.Remove(tmpPath)
-        }
-        return err
+        // 没有写入任何数据，保留上次的部分内容
+        return timeoutError(ctx, t, err)
     }
     defer resp.Body.Close()
 
-    if resp.StatusCode != http.StatusOK {
-        // 下载失败，清理临时文件
-        if _, err := os.Stat(tmpPath); err == nil {
-            os.Remove(tmpPath)
+    flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+    switch {
+    case offset > 0 && resp.StatusCode == http.StatusPartialContent && strings.HasPrefix(resp.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", offset)):
+        logger.Info("从 %s 处继续下载: %s", ByteCountIEC(offset), strings.TrimSuffix(filepath.Base(tmpPath), ".tmp"))
+        flags = os.O_WRONLY | os.O_APPEND
+    case resp.StatusCode == http.StatusOK:
+        // 没有部分内容，服务器上的文件已变化，或服务器不支持断点续传
+        if offset > 0 {
+            logger.Info("服务器返回了完整文件，重新下载: %s", strings.TrimSuffix(filepath.Base(tmpPath), ".tmp"))
+        }
+        offset = 0
+        var ri *resumeInfo
+        if resumable {
+            ri = newResumeInfo(url, expectedSize, resp)
+        }
+        if err := writeResumeInfo(tmpPath, ri); err != nil {
+            logger.Warn("无法记录下载来源，中断后将重新下载: %v", err)
         }
+    default:
+        discard()
         return fmt.Errorf("HTTP 错误: %s", resp.Status)
     }
 
+    // 创建临时文件
+    out, err := os.OpenFile(tmpPath, flags, 0644)
+    if err != nil {
+        return err
+    }
+    defer out.Close()
+
     // 处理文件大小
-    var bar *progressbar.ProgressBar
-    var writer io.Writer = out
     fileSize := expectedSize
     
     // 如果预期大小为0，尝试从Content-Length头获取
@@ -780,118 +979,159 @@ func (d *Downloader) downloadWithProgress(url, tmpPath string, expectedSize int6
         if contentLength != "" {
             if size, err := strconv.ParseInt(contentLength, 10, 64); err == nil && size > 0 {
                 fileSize = size
-                logger.Info("从响应头获取文件大小: %s", byteCountIEC(fileSize))
+                logger.Info("从响应头获取文件大小: %s", ByteCountIEC(fileSize))
             }
         }
     }
     
-    if fileSize > 0 {
-        // 创建进度条
-        bar = progressbar.DefaultBytes(
-            fileSize,
-            "下载中",
-        )
-        writer = io.MultiWriter(out, bar)
-    } else {
+    if fileSize <= 0 {
         logger.Info("文件大小未知，开始下载...")
     }
+    // 显示进度（同时下载的文件各占一行）
+    tr := d.display().start(strings.TrimSuffix(filepath.Base(tmpPath), ".tmp"), fileSize, offset)
+    ok := false
+    defer func() { tr.finish(ok) }()
+    var writer io.Writer = io.MultiWriter(out, tr)
+    var pw *progressWriter
+    if progress != nil {
+        pw = &progressWriter{total: fileSize, written: offset, last: time.Now(), callback: progress}
+        writer = io.MultiWriter(writer, pw)
+    }
 
     // 将响应体复制到文件，同时更新进度条
-    written, err := io.Copy(writer, resp.Body)
+    copied, err := io.Copy(writer, d.limiter.reader(ctx, wrap(resp.Body), url))
+    written := offset + copied
     if err != nil {
-        // 下载失败，清理临时文件
-        if _, err := os.Stat(tmpPath); err == nil {
-            os.Remove(tmpPath)
+        if parent.Err() != nil {
+            out.Sync()
+            if !resumable {
+                discard()
+                return fmt.Errorf("下载已中断: %w", parent.Err())
+            }
+            return fmt.Errorf("下载已中断，保留已下载的 %s，下次继续: %w", ByteCountIEC(written), parent.Err())
+        }
+        if ctx.Err() != nil {
+            out.Sync()
+            discard()
+            return fmt.Errorf("%w，已下载 %s", timeoutError(ctx, t, err), ByteCountIEC(written))
+        }
+        discard()
+        if isDiskFull(err) {
+            return fmt.Errorf("%w: 写入 %s 时磁盘已满", ErrDiskFull, filepath.Base(tmpPath))
         }
         return err
     }
 
-    // 确保进度条完成（显示100%）
-    if bar != nil {
-        bar.Finish()
-        fmt.Println() // 换行，避免与后续日志重叠
+    if pw != nil {
+        progress(written, fileSize)
     }
 
     // 验证下载的字节数是否与预期一致
     if expectedSize > 0 && written != expectedSize {
         // 下载不完整，清理临时文件
-        if _, err := os.Stat(tmpPath); err == nil {
-            os.Remove(tmpPath)
-        }
+        os.Remove(tmpPath)
+        removeResumeInfo(tmpPath)
         return fmt.Errorf("下载不完整: 期望 %d 字节，实际下载 %d 字节", expectedSize, written)
     }
 
     // 强制刷新文件缓冲区，确保所有数据写入磁盘
     if err := out.Sync(); err != nil {
         // 刷新失败，清理临时文件
-        if _, err := os.Stat(tmpPath); err == nil {
-            os.Remove(tmpPath)
-        }
+        os.Remove(tmpPath)
+        removeResumeInfo(tmpPath)
         return fmt.Errorf("无法刷新文件缓冲区: %w", err)
     }
 
     // 下载成功，临时文件将由调用者重命名
+    removeResumeInfo(tmpPath)
+    ok = true
     return nil
 }
 
 // verifyFiles 校验下载的文件
-func (d *Downloader) verifyFiles(dir string, release *Release, downloadedFiles []string) error {
-    // 检查是否所有文件都有官方哈希
+// release 包含版本的全部资产，selected 是按筛选规则选中的资产；
+// verified 是下载时已通过官方哈希或单文件校验文件验证的资产；mode 为合并校验文件的严格程度
+func (d *Downloader) verifyFiles(dir string, release *Release, selected []Asset, downloadedFiles []string, verified map[string]bool, mode string) ([]FileCheck, error) {
+    // 检查是否所有文件都已通过哈希验证（单文件校验文件本身无需验证）
+    sidecars := pairSidecars(selected)
     allHaveHash := true
-    for _, asset := range release.Assets {
-        if extractSHA256(asset.Digest) == "" {
+    for _, asset := range selected {
+        if !verified[asset.Name] && !isSidecar(asset.Name, sidecars) {
             allHaveHash = false
             break
         }
     }
     if allHaveHash {
-        logger.Info("✅ 所有文件都已通过官方哈希验证，跳过额外校验")
-        return nil
+        logger.Info("✅ 所有文件都已通过官方哈希或校验文件验证，跳过额外校验")
+        return nil, nil
     }
 
     logger.Info("正在检查校验文件...")
-    // 常见校验文件名
-    checksumFiles := []string{
-        "SHA256SUMS", "SHA512SUMS",
-        "sha256sum.txt", "sha512sum.txt",
-        "checksums.txt", release.TagName + "_checksums.txt",
-    }
-
-    for _, name := range checksumFiles {
-        path := filepath.Join(dir, name)
-        if _, err := os.Stat(path); err == nil {
-            logger.Info("找到校验文件: %s，开始验证...", name)
-
-            // 切换到目录执行校验
-            if err := verifyChecksumFile(path, dir); err != nil {
-                logger.Error("❌ 文件校验失败: %v", err)
-                return err
+    checksumFiles, err := findChecksumFiles(dir)
+    if err != nil {
+        return nil, err
+    }
+    if len(checksumFiles) > 0 {
+        for _, path := range checksumFiles {
+            logger.Info("找到校验文件: %s", filepath.Base(path))
+        }
+        results, err := verifyChecksumFiles(dir, checksumFiles, d.state)
+        if err != nil {
+            return nil, err
+        }
+        classifyMissing(results, release.Assets, selected)
+
+        failed, tolerated, skipped := 0, 0, 0
+        for _, fc := range results {
+            switch {
+            case checkFailed(fc.Status, mode):
+                failed++
+            case fc.Status == CheckUnsupported:
+                skipped++
+            case fc.Status != CheckOK:
+                tolerated++
             }
+        }
+        logger.Info("校验结果（模式 %s）:", mode)
+        for _, line := range formatChecks(results, mode) {
+            if failed > 0 {
+                logger.Warn("%s", line)
+            } else {
+                logger.Info("%s", line)
+            }
+        }
+        if failed > 0 {
+            return results, fmt.Errorf("%d/%d 个文件校验失败", failed, len(results))
+        }
+        switch {
+        case skipped > 0:
+            // 只有 lenient 模式会跳过不支持的算法
+            logger.Warn("⚠️ %d 个文件在校验文件中只有不支持的算法的哈希，已跳过，这些文件没有经过校验", skipped)
+            logger.Info("✅ 其余已下载的文件校验成功（%d 个未下载的文件已忽略）", tolerated)
+        case tolerated > 0:
+            logger.Info("✅ 已下载的文件校验成功（%d 个未下载的文件已忽略）", tolerated)
+        default:
             logger.Info("✅ 所有文件校验成功！")
-            return nil
         }
+        return results, nil
     }
 
     logger.Warn("⚠️ 未找到官方校验文件，正在生成本地校验...")
-    // 生成 checksums.txt（排除自身）
+    // 生成 checksums.txt（排除自身，以及每次运行都会重写的 manifest.json 和 signatures.json）
+    // 先写临时文件再重命名：已有的 checksums.txt 可能是指向内容寻址存储的链接，不能原地改写
     checksumPath := filepath.Join(dir, "checksums.txt")
-    f, err := os.Create(checksumPath)
-    if err != nil {
-        return err
-    }
-    defer f.Close()
-
     entries, err := os.ReadDir(dir)
     if err != nil {
-        return err
+        return nil, err
     }
 
+    var sb strings.Builder
     for _, entry := range entries {
         if entry.IsDir() {
             continue
         }
         name := entry.Name()
-        if name == "checksums.txt" {
+        if name == "checksums.txt" || name == manifestFile || name == signaturesFile || strings.HasSuffix(name, ".tmp") {
             continue
         }
         fullPath := filepath.Join(dir, name)
@@ -900,12 +1140,18 @@ func (d *Downloader) verifyFiles(dir string, release *Release, downloadedFiles [
             logger.Warn("计算哈希失败 %s: %v", name, err)
             continue
         }
-        fmt.Fprintf(f, "%s  %s\n", hash, name)
+        fmt.Fprintf(&sb, "%s  %s\n", hash, name)
+    }
+    if err := os.WriteFile(checksumPath+".tmp", []byte(sb.String()), 0644); err != nil {
+        return nil, err
+    }
+    if err := os.Rename(checksumPath+".tmp", checksumPath); err != nil {
+        return nil, err
     }
 
     logger.Info("📝 本地校验文件已生成: %s", checksumPath)
     logger.Info("您可以通过以下命令手动验证：sha256sum -c %s", checksumPath)
-    return nil
+    return nil, nil
 }
 
 // 辅助函数
@@ -923,13 +1169,12 @@ func extractSHA256(digest string) string {
     return ""
 }
 
-// verifySHA256 验证文件哈希
-func verifySHA256(path, expected string) (bool, error) {
-    actual, err := computeSHA256(path)
-    if err != nil {
-        return false, err
+// officialDigest 返回资产的官方 SHA256 哈希（GitHub 的 digest 字段）
+func officialDigest(asset Asset) Digest {
+    if sum := extractSHA256(asset.Digest); sum != "" {
+        return Digest{Algo: "sha256", Hex: strings.ToLower(sum)}
     }
-    return strings.EqualFold(actual, expected), nil
+    return Digest{}
 }
 
 // computeSHA256 计算文件的 SHA256 哈希
@@ -947,48 +1192,8 @@ func computeSHA256(path string) (string, error) {
     return hex.EncodeToString(h.Sum(nil)), nil
 }
 
-// verifyChecksumFile 执行 sha256sum -c 类似的功能
-func verifyChecksumFile(checksumPath, dir string) error {
-    f, err := os.Open(checksumPath)
-    if err != nil {
-        return err
-    }
-    defer f.Close()
-
-    scanner := bufio.NewScanner(f)
-    lineNum := 0
-    for scanner.Scan() {
-        lineNum++
-        line := strings.TrimSpace(scanner.Text())
-        if line == "" {
-            continue
-        }
-        // 格式：<hash>  <filename> 或 <hash> *<filename>
-        parts := strings.Fields(line)
-        if len(parts) < 2 {
-            continue
-        }
-        expectedHash := parts[0]
-        filename := parts[1]
-        if strings.HasPrefix(filename, "*") {
-            filename = filename[1:]
-        }
-        filename = filepath.Base(filename)
-
-        fullPath := filepath.Join(dir, filename)
-        actualHash, err := computeSHA256(fullPath)
-        if err != nil {
-            return fmt.Errorf("无法计算 %s 的哈希: %w", filename, err)
-        }
-        if !strings.EqualFold(actualHash, expectedHash) {
-            return fmt.Errorf("%s: 哈希不匹配 (期望 %s, 实际 %s)", filename, expectedHash, actualHash)
-        }
-    }
-    return scanner.Err()
-}
-
-// byteCountIEC 将字节数转换为人类可读格式（如 1.2 MiB）
-func byteCountIEC(b int64) string {
+// ByteCountIEC 将字节数转换为人类可读格式（如 1.2 MiB）
+func ByteCountIEC(b int64) string {
     const unit = 1024
     if b < unit {
         return strconv.FormatInt(b, 10) + " B"
@@ -0,0 +1,103 @@
+package downloader
+
+import (
+    "encoding/json"
+    "io"
+    "sync"
+    "time"
+)
+
+// 事件类型
+const (
+    EventRepoStart        = "repo_start"
+    EventReleaseResolved  = "release_resolved"
+    EventAssetStart       = "asset_start"
+    EventAssetProgress    = "asset_progress"
+    EventAssetDone        = "asset_done"
+    EventAssetQuarantined = "asset_quarantined"
+    EventVerifyResult     = "verify_result"
+    EventRepoDone         = "repo_done"
+    EventRunSummary       = "run_summary"
+)
+
+// asset_done 和 verify_result 事件的状态
+const (
+    AssetDownloaded = "downloaded"
+    AssetSkipped    = "skipped"
+    AssetFailed     = "failed"
+    VerifyOK        = "ok"
+    VerifyFailed    = "failed"
+)
+
+// progressInterval 是 asset_progress 事件的最小间隔
+const progressInterval = time.Second
+
+// Event 是下载流程中产生的事件，字段按事件类型选择性填写
+type Event struct {
+    Type       string         `json:"type"`
+    Time       time.Time      `json:"time"`
+    Repo       string         `json:"repo,omitempty"`
+    Tag        string         `json:"tag,omitempty"`
+    Prerelease bool           `json:"prerelease,omitempty"`
+    Assets     int            `json:"assets,omitempty"`   // release_resolved: 选中的资产数
+    Excluded   int            `json:"excluded,omitempty"` // release_resolved: 被筛选规则排除的资产数
+    Asset      string         `json:"asset,omitempty"`
+    Size       int64          `json:"size,omitempty"`  // 资产总大小
+    Bytes      int64          `json:"bytes,omitempty"` // 已下载的字节数
+    Proxy      string         `json:"proxy,omitempty"`
+    Status     string         `json:"status,omitempty"`
+    Error      string         `json:"error,omitempty"`
+    Checks     []FileCheck    `json:"checks,omitempty"`     // verify_result: 合并校验文件中每个文件的结果
+    Signatures []SigCheck     `json:"signatures,omitempty"` // verify_result: 签名校验结果
+    Result     *RepoSummary   `json:"result,omitempty"`  // repo_done
+    Summary    *SummaryReport `json:"summary,omitempty"` // run_summary
+}
+
+// EventHandler 接收下载流程中的事件，可能被多个 goroutine 同时调用
+type EventHandler func(Event)
+
+// SetEventHandler 设置事件处理函数，为 nil 时不产生事件
+func (d *Downloader) SetEventHandler(h EventHandler) {
+    d.events = h
+}
+
+// EmitRunSummary 发送 run_summary 事件
+func (d *Downloader) EmitRunSummary(r SummaryReport) {
+    d.emit(Event{Type: EventRunSummary, Summary: &r})
+}
+
+func (d *Downloader) emit(e Event) {
+    if d.events == nil {
+        return
+    }
+    e.Time = time.Now()
+    d.events(e)
+}
+
+// NewJSONEventWriter 返回将事件以每行一个 JSON 对象的形式写入 w 的处理函数
+func NewJSONEventWriter(w io.Writer) EventHandler {
+    var mu sync.Mutex
+    enc := json.NewEncoder(w)
+    return func(e Event) {
+        mu.Lock()
+        defer mu.Unlock()
+        enc.Encode(e)
+    }
+}
+
+// progressWriter 统计写入的字节数，并按 progressInterval 节流回调
+type progressWriter struct {
+    total    int64
+    written  int64
+    last     time.Time
+    callback func(written, total int64)
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+    p.written += int64(len(b))
+    if now := time.Now(); now.Sub(p.last) >= progressInterval {
+        p.last = now
+        p.callback(p.written, p.total)
+    }
+    return len(b), nil
+}
@@ -0,0 +1,264 @@
+package downloader
+
+import (
+    "archive/tar"
+    "archive/zip"
+    "compress/gzip"
+    "fmt"
+    "io"
+    "os"
+    "path"
+    "path/filepath"
+    "runtime"
+    "strings"
+
+    "github.com/klauspost/compress/zstd"
+    "github.com/ulikunitz/xz"
+)
+
+// 可安装资产的格式
+const (
+    FormatTarGz  = "tar.gz"
+    FormatTarXz  = "tar.xz"
+    FormatTarZst = "tar.zst"
+    FormatZip    = "zip"
+    FormatBinary = "binary" // 未打包的可执行文件
+)
+
+// formatRank 是同一平台有多种格式时的优先顺序
+var formatRank = map[string]int{
+    FormatTarGz:  1,
+    FormatTarXz:  2,
+    FormatTarZst: 3,
+    FormatZip:    4,
+    FormatBinary: 5,
+}
+
+// archiveSuffixes 是归档格式对应的文件名后缀
+var archiveSuffixes = []struct {
+    suffix, format string
+}{
+    {".tar.gz", FormatTarGz},
+    {".tgz", FormatTarGz},
+    {".tar.xz", FormatTarXz},
+    {".txz", FormatTarXz},
+    {".tar.zst", FormatTarZst},
+    {".tzst", FormatTarZst},
+    {".zip", FormatZip},
+}
+
+// installFormat 根据文件名判断资产能否安装及其格式，不能安装时返回空字符串
+// 未打包的可执行文件需要能从文件名识别出平台，且没有扩展名、扩展名为 .exe 或“扩展名”是版本号的一部分
+func installFormat(name string) string {
+    lower := strings.ToLower(name)
+    for _, s := range archiveSuffixes {
+        if strings.HasSuffix(lower, s.suffix) {
+            return s.format
+        }
+    }
+    if goos, goarch := detectPlatform(name); goos == "" && goarch == "" {
+        return ""
+    }
+    ext := strings.TrimPrefix(path.Ext(lower), ".")
+    if ext == "" || ext == "exe" || strings.IndexFunc(ext, func(r rune) bool { return r < 'a' || r > 'z' }) >= 0 {
+        return FormatBinary
+    }
+    return ""
+}
+
+// archiveEntry 是归档中的一个普通文件
+type archiveEntry struct {
+    Name string
+    Mode os.FileMode
+}
+
+// walkArchive 依次访问归档中的普通文件，fn 返回 true 时停止；r 只在 fn 调用期间有效
+func walkArchive(src, format string, fn func(e archiveEntry, r io.Reader) (bool, error)) error {
+    if format == FormatZip {
+        zr, err := zip.OpenReader(src)
+        if err != nil {
+            return err
+        }
+        defer zr.Close()
+        for _, f := range zr.File {
+            if !f.Mode().IsRegular() {
+                continue
+            }
+            rc, err := f.Open()
+            if err != nil {
+                return err
+            }
+            stop, err := fn(archiveEntry{Name: f.Name, Mode: f.Mode()}, rc)
+            rc.Close()
+            if stop || err != nil {
+                return err
+            }
+        }
+        return nil
+    }
+
+    file, err := os.Open(src)
+    if err != nil {
+        return err
+    }
+    defer file.Close()
+    var r io.Reader
+    switch format {
+    case FormatTarGz:
+        gz, err := gzip.NewReader(file)
+        if err != nil {
+            return err
+        }
+        defer gz.Close()
+        r = gz
+    case FormatTarXz:
+        if r, err = xz.NewReader(file); err != nil {
+            return err
+        }
+    case FormatTarZst:
+        zr, err := zstd.NewReader(file)
+        if err != nil {
+            return err
+        }
+        defer zr.Close()
+        r = zr
+    default:
+        return fmt.Errorf("不支持的归档格式: %s", format)
+    }
+
+    tr := tar.NewReader(r)
+    for {
+        hdr, err := tr.Next()
+        if err == io.EOF {
+            return nil
+        }
+        if err != nil {
+            return err
+        }
+        if hdr.Typeflag != tar.TypeReg {
+            continue
+        }
+        stop, err := fn(archiveEntry{Name: hdr.Name, Mode: hdr.FileInfo().Mode()}, tr)
+        if stop || err != nil {
+            return err
+        }
+    }
+}
+
+// findExecutable 在归档中查找要安装的可执行文件
+// pattern 不为空时匹配归档中的路径或文件名（支持通配符）；否则查找名为 name（Windows 上为 name.exe）的文件，
+// 找不到时使用归档中唯一的可执行文件
+func findExecutable(src, format, pattern, name string) (string, error) {
+    var byPattern, byName, executables, all []string
+    err := walkArchive(src, format, func(e archiveEntry, _ io.Reader) (bool, error) {
+        member := strings.TrimPrefix(e.Name, "./")
+        base := path.Base(member)
+        all = append(all, member)
+        if pattern != "" {
+            if ok, _ := path.Match(pattern, member); ok {
+                byPattern = append(byPattern, e.Name)
+            } else if ok, _ := path.Match(pattern, base); ok {
+                byPattern = append(byPattern, e.Name)
+            }
+            return false, nil
+        }
+        if base == name || (runtime.GOOS == "windows" && strings.EqualFold(base, name+".exe")) {
+            byName = append(byName, e.Name)
+        }
+        if e.Mode&0111 != 0 || (runtime.GOOS == "windows" && strings.HasSuffix(strings.ToLower(base), ".exe")) {
+            executables = append(executables, e.Name)
+        }
+        return false, nil
+    })
+    if err != nil {
+        return "", fmt.Errorf("读取归档失败: %w", err)
+    }
+
+    var candidates []string
+    switch {
+    case pattern != "":
+        candidates = byPattern
+    case len(byName) > 0:
+        candidates = byName
+    default:
+        candidates = executables
+    }
+    switch len(candidates) {
+    case 1:
+        return candidates[0], nil
+    case 0:
+        if len(all) > 10 {
+            all = append(all[:10], "...")
+        }
+        return "", fmt.Errorf("归档中没有找到可执行文件 %s（可用 -path 或 bin=<归档中的路径> 指定），归档内容: %s", firstNonEmpty(pattern, name), strings.Join(all, ", "))
+    default:
+        return "", fmt.Errorf("归档中有多个候选文件: %s，请用 -path 或 bin=<归档中的路径> 指定", strings.Join(candidates, ", "))
+    }
+}
+
+// extractMember 将归档中的文件 member 解压到 dst（先写临时文件再重命名），并设置可执行权限
+func extractMember(src, format, member, dst string) error {
+    found := false
+    err := walkArchive(src, format, func(e archiveEntry, r io.Reader) (bool, error) {
+        if e.Name != member {
+            return false, nil
+        }
+        found = true
+        return true, writeExecutable(dst, r)
+    })
+    if err == nil && !found {
+        err = fmt.Errorf("归档中没有 %s", member)
+    }
+    return err
+}
+
+// writeExecutable 将 r 的内容写入可执行文件 dst（先写唯一命名的临时文件再重命名）
+func writeExecutable(dst string, r io.Reader) error {
+    out, err := os.CreateTemp(filepath.Dir(dst), filepath.Base(dst)+".*.tmp")
+    if err != nil {
+        return err
+    }
+    tmp := out.Name()
+    _, err = io.Copy(out, r)
+    if cerr := out.Close(); err == nil {
+        err = cerr
+    }
+    if err == nil {
+        // umask 可能去掉了执行权限
+        err = os.Chmod(tmp, 0755)
+    }
+    if err == nil {
+        err = os.Rename(tmp, dst)
+    }
+    if err != nil {
+        os.Remove(tmp)
+    }
+    return err
+}
+
+// copyExecutable 将未打包的可执行文件复制到 dst
+func copyExecutable(src, dst string) error {
+    in, err := os.Open(src)
+    if err != nil {
+        return err
+    }
+    defer in.Close()
+    return writeExecutable(dst, in)
+}
+
+func firstNonEmpty(values ...string) string {
+    for _, v := range values {
+        if v != "" {
+            return v
+        }
+    }
+    return ""
+}
+
+// exeName 在 Windows 上为可执行文件名加上 .exe 后缀
+func exeName(name string) string {
+    if runtime.GOOS == "windows" && !strings.HasSuffix(strings.ToLower(name), ".exe") {
+        return name + ".exe"
+    }
+    return name
+}
@@ -0,0 +1,164 @@
+package downloader
+
+import (
+    "path"
+    "regexp"
+    "runtime"
+    "strings"
+)
+
+// AssetFilter 资产筛选规则，零值表示选择全部资产
+type AssetFilter struct {
+    Include   []string // 文件名通配符，非空时只选择匹配任一规则的资产
+    Exclude   []string // 文件名通配符，匹配任一规则的资产被排除
+    Platforms []string // 目标平台，如 linux/amd64、darwin、auto；不含平台信息的资产（如校验文件）始终保留
+}
+
+// 平台别名，按优先级排列（x86_64 需要先于 x86 匹配，arm64 需要先于 arm 匹配）
+var (
+    osAliases = []platformAlias{
+        {"linux", `linux`},
+        {"darwin", `darwin|macos|mac|osx|apple`},
+        {"windows", `windows|win64|win32|win`},
+        {"freebsd", `freebsd`},
+    }
+    archAliases = []platformAlias{
+        {"amd64", `amd64|x86_64|x86-64|x64`},
+        {"arm64", `arm64|aarch64|armv8`},
+        {"arm", `armv7l?|armv6l?|armhf|armel|arm`},
+        {"386", `386|i386|i686|x86|32-?bit`},
+        {"universal", `universal|all`},
+    }
+)
+
+type platformAlias struct {
+    name    string
+    pattern string
+}
+
+var (
+    osPatterns   = compileAliases(osAliases)
+    archPatterns = compileAliases(archAliases)
+)
+
+func compileAliases(aliases []platformAlias) []*regexp.Regexp {
+    res := make([]*regexp.Regexp, len(aliases))
+    for i, a := range aliases {
+        res[i] = regexp.MustCompile(`(^|[^a-z0-9])(` + a.pattern + `)([^a-z0-9]|$)`)
+    }
+    return res
+}
+
+// detectPlatform 根据文件名推断资产的操作系统和架构，无法识别的部分返回空字符串
+func detectPlatform(name string) (goos, goarch string) {
+    lower := strings.ToLower(name)
+    for i, re := range osPatterns {
+        if re.MatchString(lower) {
+            goos = osAliases[i].name
+            break
+        }
+    }
+    if goos == "" && strings.HasSuffix(lower, ".exe") {
+        goos = "windows"
+    }
+    for i, re := range archPatterns {
+        if re.MatchString(lower) {
+            goarch = archAliases[i].name
+            break
+        }
+    }
+    return goos, goarch
+}
+
+// IsZero 判断是否未配置任何规则
+func (f AssetFilter) IsZero() bool {
+    return len(f.Include) == 0 && len(f.Exclude) == 0 && len(f.Platforms) == 0
+}
+
+// Match 判断资产是否被选中，未选中时返回原因
+func (f AssetFilter) Match(name string) (bool, string) {
+    lower := strings.ToLower(name)
+    if len(f.Include) > 0 && !matchAny(f.Include, lower) {
+        return false, "不匹配 include"
+    }
+    if matchAny(f.Exclude, lower) {
+        return false, "匹配 exclude"
+    }
+    if len(f.Platforms) > 0 && !f.matchPlatform(name) {
+        return false, "平台不匹配"
+    }
+    return true, ""
+}
+
+// Select 返回被选中的资产；被选中资产的单文件校验文件（如 foo.tar.gz.sha256）和签名（如 foo.tar.gz.asc）也会一并选中
+func (f AssetFilter) Select(assets []Asset) []Asset {
+    if f.IsZero() {
+        return assets
+    }
+    pairs := pairSidecars(assets)
+    chosen := make(map[string]bool, len(assets))
+    for _, a := range assets {
+        if ok, _ := f.Match(a.Name); ok {
+            chosen[a.Name] = true
+            if sidecar, ok := pairs[a.Name]; ok {
+                chosen[sidecar] = true
+            }
+        }
+    }
+    // 被选中资产（包括校验文件）的分离签名
+    for _, a := range assets {
+        if target, _, ok := signatureTarget(a.Name); ok && chosen[target] {
+            chosen[a.Name] = true
+        }
+    }
+    selected := make([]Asset, 0, len(chosen))
+    for _, a := range assets {
+        if chosen[a.Name] {
+            selected = append(selected, a)
+        }
+    }
+    return selected
+}
+
+func (f AssetFilter) matchPlatform(name string) bool {
+    goos, goarch := detectPlatform(name)
+    if goos == "" && goarch == "" {
+        // 与平台无关的资产（源码包、校验文件等）
+        return true
+    }
+    for _, p := range f.Platforms {
+        wantOS, wantArch := splitPlatform(p)
+        if goos != "" && wantOS != "" && goos != wantOS {
+            continue
+        }
+        if goarch == "" || goarch == "universal" || wantArch == "" || goarch == wantArch {
+            return true
+        }
+    }
+    return false
+}
+
+// splitPlatform 解析 "os/arch"、"os" 或 "auto"（当前运行平台）
+func splitPlatform(p string) (goos, goarch string) {
+    p = strings.ToLower(strings.TrimSpace(p))
+    if p == "auto" {
+        return runtime.GOOS, runtime.GOARCH
+    }
+    goos, goarch, _ = strings.Cut(p, "/")
+    if goos == "*" {
+        goos = ""
+    }
+    if goarch == "*" {
+        goarch = ""
+    }
+    return goos, goarch
+}
+
+func matchAny(patterns []string, lowerName string) bool {
+    for _, p := range patterns {
+        if ok, _ := path.Match(strings.ToLower(p), lowerName); ok {
+            return true
+        }
+    }
+    return false
+}
@@ -0,0 +1,186 @@
+package downloader
+
+import (
+    "reflect"
+    "testing"
+)
+
+func TestDetectPlatform(t *testing.T) {
+    tests := []struct {
+        name         string
+        goos, goarch string
+    }{
+        {"ripgrep-14.1.0-x86_64-unknown-linux-musl.tar.gz", "linux", "amd64"},
+        {"ripgrep-14.1.0-x86_64-unknown-linux-gnu.tar.gz", "linux", "amd64"},
+        {"ripgrep-14.1.0-aarch64-unknown-linux-gnu.tar.gz", "linux", "arm64"},
+        {"ripgrep-14.1.0-aarch64-apple-darwin.tar.gz", "darwin", "arm64"},
+        {"ripgrep-14.1.0-x86_64-pc-windows-msvc.zip", "windows", "amd64"},
+        {"ripgrep-14.1.0-i686-pc-windows-msvc.zip", "windows", "386"},
+        {"gh_2.40.0_linux_amd64.deb", "linux", "amd64"},
+        {"gh_2.40.0_linux_arm64.tar.gz", "linux", "arm64"},
+        {"gh_2.40.0_linux_armv6.tar.gz", "linux", "arm"},
+        {"gh_2.40.0_macOS_amd64.zip", "darwin", "amd64"},
+        {"gh_2.40.0_macOS_universal.pkg", "darwin", "universal"},
+        {"gh_2.40.0_windows_386.msi", "windows", "386"},
+        {"hugo_0.121.0_Linux-64bit.tar.gz", "linux", ""},
+        {"node-v20.10.0-linux-x64.tar.xz", "linux", "amd64"},
+        {"node-v20.10.0-darwin-arm64.tar.gz", "darwin", "arm64"},
+        {"node-v20.10.0-win-x64.zip", "windows", "amd64"},
+        {"tool-armhf.deb", "", "arm"},
+        {"tool-freebsd-amd64.tar.gz", "freebsd", "amd64"},
+        {"tool.exe", "windows", ""},
+        {"tool-arm64.exe", "windows", "arm64"},
+        {"tool-1.0.0.tar.gz", "", ""},
+        {"checksums.txt", "", ""},
+        {"SHA256SUMS", "", ""},
+        // 单词的一部分不算平台名
+        {"winget-installer.tar.gz", "", ""},
+        {"darwinian-linux-x86_64.tar.gz", "linux", "amd64"},
+        {"tool_1.0_darwin_all.tar.gz", "darwin", "universal"},
+    }
+    for _, tt := range tests {
+        goos, goarch := detectPlatform(tt.name)
+        if goos != tt.goos || goarch != tt.goarch {
+            t.Errorf("detectPlatform(%q) = %q, %q, want %q, %q", tt.name, goos, goarch, tt.goos, tt.goarch)
+        }
+    }
+}
+
+func TestAssetFilterSelect(t *testing.T) {
+    var assets []Asset
+    for _, name := range []string{
+        "tool-1.0-x86_64-unknown-linux-musl.tar.gz",
+        "tool-1.0-x86_64-unknown-linux-musl.tar.gz.sha256",
+        "tool-1.0-aarch64-unknown-linux-gnu.tar.gz",
+        "tool-1.0-aarch64-unknown-linux-gnu.tar.gz.sha256",
+        "tool-1.0-aarch64-apple-darwin.tar.gz",
+        "tool-1.0-x86_64-apple-darwin.tar.gz",
+        "tool-1.0-universal-macos.zip",
+        "tool-1.0-x86_64-pc-windows-msvc.zip",
+        "tool-1.0-x86_64-pc-windows-msvc.zip.asc",
+        "tool-1.0-windows.exe",
+        "tool-1.0.tar.gz",
+        "checksums.txt",
+        "checksums.txt.sig",
+    } {
+        assets = append(assets, Asset{Name: name})
+    }
+
+    tests := []struct {
+        name   string
+        filter AssetFilter
+        want   []string
+    }{
+        {"没有规则时选择全部", AssetFilter{}, []string{
+            "tool-1.0-x86_64-unknown-linux-musl.tar.gz",
+            "tool-1.0-x86_64-unknown-linux-musl.tar.gz.sha256",
+            "tool-1.0-aarch64-unknown-linux-gnu.tar.gz",
+            "tool-1.0-aarch64-unknown-linux-gnu.tar.gz.sha256",
+            "tool-1.0-aarch64-apple-darwin.tar.gz",
+            "tool-1.0-x86_64-apple-darwin.tar.gz",
+            "tool-1.0-universal-macos.zip",
+            "tool-1.0-x86_64-pc-windows-msvc.zip",
+            "tool-1.0-x86_64-pc-windows-msvc.zip.asc",
+            "tool-1.0-windows.exe",
+            "tool-1.0.tar.gz",
+            "checksums.txt",
+            "checksums.txt.sig",
+        }},
+        // 与平台无关的资产（源码包、校验文件）和选中资产的校验文件、签名一并保留
+        {"linux/amd64", AssetFilter{Platforms: []string{"linux/amd64"}}, []string{
+            "tool-1.0-x86_64-unknown-linux-musl.tar.gz",
+            "tool-1.0-x86_64-unknown-linux-musl.tar.gz.sha256",
+            "tool-1.0.tar.gz",
+            "checksums.txt",
+            "checksums.txt.sig",
+        }},
+        {"darwin/arm64 包含 universal", AssetFilter{Platforms: []string{"darwin/arm64"}}, []string{
+            "tool-1.0-aarch64-apple-darwin.tar.gz",
+            "tool-1.0-universal-macos.zip",
+            "tool-1.0.tar.gz",
+            "checksums.txt",
+            "checksums.txt.sig",
+        }},
+        {"只指定操作系统", AssetFilter{Platforms: []string{"windows"}}, []string{
+            "tool-1.0-x86_64-pc-windows-msvc.zip",
+            "tool-1.0-x86_64-pc-windows-msvc.zip.asc",
+            "tool-1.0-windows.exe",
+            "tool-1.0.tar.gz",
+            "checksums.txt",
+            "checksums.txt.sig",
+        }},
+        {"只指定架构", AssetFilter{Platforms: []string{"*/arm64"}}, []string{
+            "tool-1.0-aarch64-unknown-linux-gnu.tar.gz",
+            "tool-1.0-aarch64-unknown-linux-gnu.tar.gz.sha256",
+            "tool-1.0-aarch64-apple-darwin.tar.gz",
+            "tool-1.0-universal-macos.zip",
+            "tool-1.0-windows.exe",
+            "tool-1.0.tar.gz",
+            "checksums.txt",
+            "checksums.txt.sig",
+        }},
+        {"多个平台", AssetFilter{Platforms: []string{"linux/arm64", "darwin/amd64"}}, []string{
+            "tool-1.0-aarch64-unknown-linux-gnu.tar.gz",
+            "tool-1.0-aarch64-unknown-linux-gnu.tar.gz.sha256",
+            "tool-1.0-x86_64-apple-darwin.tar.gz",
+            "tool-1.0-universal-macos.zip",
+            "tool-1.0.tar.gz",
+            "checksums.txt",
+            "checksums.txt.sig",
+        }},
+        {"include 不区分大小写", AssetFilter{Include: []string{"*LINUX*.tar.gz"}}, []string{
+            "tool-1.0-x86_64-unknown-linux-musl.tar.gz",
+            "tool-1.0-x86_64-unknown-linux-musl.tar.gz.sha256",
+            "tool-1.0-aarch64-unknown-linux-gnu.tar.gz",
+            "tool-1.0-aarch64-unknown-linux-gnu.tar.gz.sha256",
+        }},
+        {"include 和 exclude", AssetFilter{Include: []string{"*linux*", "*.exe"}, Exclude: []string{"*musl*"}}, []string{
+            "tool-1.0-aarch64-unknown-linux-gnu.tar.gz",
+            "tool-1.0-aarch64-unknown-linux-gnu.tar.gz.sha256",
+            "tool-1.0-windows.exe",
+        }},
+        {"include 和平台同时生效", AssetFilter{Include: []string{"*.tar.gz"}, Platforms: []string{"darwin/amd64"}}, []string{
+            "tool-1.0-x86_64-apple-darwin.tar.gz",
+            "tool-1.0.tar.gz",
+        }},
+        {"exclude 校验文件后仍随选中的资产保留", AssetFilter{Exclude: []string{"*.sha256", "*windows*", "*darwin*", "*macos*"}}, []string{
+            "tool-1.0-x86_64-unknown-linux-musl.tar.gz",
+            "tool-1.0-x86_64-unknown-linux-musl.tar.gz.sha256",
+            "tool-1.0-aarch64-unknown-linux-gnu.tar.gz",
+            "tool-1.0-aarch64-unknown-linux-gnu.tar.gz.sha256",
+            "tool-1.0.tar.gz",
+            "checksums.txt",
+            "checksums.txt.sig",
+        }},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            var got []string
+            for _, a := range tt.filter.Select(assets) {
+                got = append(got, a.Name)
+            }
+            if !reflect.DeepEqual(got, tt.want) {
+                t.Errorf("Select() = %q\nwant %q", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestAssetFilterMatchReason(t *testing.T) {
+    f := AssetFilter{Include: []string{"*.tar.gz"}, Exclude: []string{"*debug*"}, Platforms: []string{"linux/amd64"}}
+    tests := []struct {
+        name   string
+        ok     bool
+        reason string
+    }{
+        {"tool-linux-amd64.tar.gz", true, ""},
+        {"tool-linux-amd64.zip", false, "不匹配 include"},
+        {"tool-debug-linux-amd64.tar.gz", false, "匹配 exclude"},
+        {"tool-linux-arm64.tar.gz", false, "平台不匹配"},
+    }
+    for _, tt := range tests {
+        if ok, reason := f.Match(tt.name); ok != tt.ok || reason != tt.reason {
+            t.Errorf("Match(%q) = %v, %q, want %v, %q", tt.name, ok, reason, tt.ok, tt.reason)
+        }
+    }
+}
@@ -0,0 +1,389 @@
+package downloader
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io/fs"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "time"
+
+    "github-downloader/logger"
+)
+
+// InstallsFile 是安装目录中记录已安装命令的文件
+const InstallsFile = ".github-downloader.json"
+
+// InstallOptions 是安装可执行文件的选项
+type InstallOptions struct {
+    BinDir string // 安装目录，例如 ~/.local/bin
+    Name   string // 安装后的命令名，为空时使用仓库名
+    Path   string // 归档中可执行文件的路径或文件名（支持通配符），为空时按命令名查找
+}
+
+// Installation 是一个已安装的命令
+type Installation struct {
+    Name     string             `json:"name"`
+    Provider string             `json:"provider"`
+    Host     string             `json:"host"`
+    Owner    string             `json:"owner"`
+    Repo     string             `json:"repo"`
+    Active   string             `json:"active"`           // 当前使用的版本
+    Copied   bool               `json:"copied,omitempty"` // 不支持符号链接，<命令名> 是当前版本的副本
+    Versions []InstalledVersion `json:"versions"`
+}
+
+// InstalledVersion 是已安装的一个版本
+type InstalledVersion struct {
+    Tag         string    `json:"tag"`
+    File        string    `json:"file"`             // 安装目录中的文件名，例如 fzf-v0.56.3
+    Asset       string    `json:"asset"`            // 来源资产
+    Member      string    `json:"member,omitempty"` // 归档中的路径，未打包的可执行文件为空
+    InstalledAt time.Time `json:"installed_at"`
+}
+
+// Version 返回已安装的版本，不存在时返回 nil
+func (in *Installation) Version(tag string) *InstalledVersion {
+    for i := range in.Versions {
+        if in.Versions[i].Tag == tag {
+            return &in.Versions[i]
+        }
+    }
+    return nil
+}
+
+// Ref 返回命令来源的仓库
+func (in *Installation) Ref() RepoRef {
+    m := Manifest{Provider: in.Provider, Host: in.Host, Owner: in.Owner, Repo: in.Repo}
+    return m.Ref()
+}
+
+// checkLink 检查 <binDir>/<命令名> 是否仍由本工具管理：不存在、是指向已安装版本的符号链接，
+// 或是（不支持符号链接时）与当前版本内容相同的副本；被替换或修改过时返回错误
+func (in *Installation) checkLink(binDir string) error {
+    link := filepath.Join(binDir, exeName(in.Name))
+    info, err := os.Lstat(link)
+    if os.IsNotExist(err) {
+        return nil
+    }
+    if err != nil {
+        return err
+    }
+    if info.Mode()&os.ModeSymlink != 0 {
+        target, err := os.Readlink(link)
+        if err != nil {
+            return err
+        }
+        for _, v := range in.Versions {
+            if target == v.File || target == filepath.Join(binDir, v.File) {
+                return nil
+            }
+        }
+        return fmt.Errorf("%s 指向 %s，不是由本工具安装的版本", link, target)
+    }
+    if in.Copied && info.Mode().IsRegular() {
+        if v := in.Version(in.Active); v != nil && sameContent(link, filepath.Join(binDir, v.File)) {
+            return nil
+        }
+    }
+    return fmt.Errorf("%s 已被替换或修改，不是由本工具管理的", link)
+}
+
+// sameContent 判断两个文件的内容是否相同
+func sameContent(a, b string) bool {
+    sa, err := computeDigests(a, []string{"sha256"})
+    if err != nil {
+        return false
+    }
+    sb, err := computeDigests(b, []string{"sha256"})
+    return err == nil && sa["sha256"] == sb["sha256"]
+}
+
+// LoadInstalls 读取安装目录中的安装记录，不存在时返回空的记录
+func LoadInstalls(binDir string) (map[string]*Installation, error) {
+    installs := make(map[string]*Installation)
+    data, err := os.ReadFile(filepath.Join(binDir, InstallsFile))
+    if os.IsNotExist(err) {
+        return installs, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    if err := json.Unmarshal(data, &installs); err != nil {
+        return nil, fmt.Errorf("解析 %s 失败: %w", InstallsFile, err)
+    }
+    return installs, nil
+}
+
+func saveInstalls(binDir string, installs map[string]*Installation) error {
+    data, err := json.MarshalIndent(installs, "", "  ")
+    if err != nil {
+        return err
+    }
+    return writeFileAtomic(filepath.Join(binDir, InstallsFile), data)
+}
+
+// Install 下载并校验仓库的指定版本（tag 为空时为最新版本），从按 opts 筛选出的资产中
+// 取出可执行文件，安装为 <BinDir>/<命令名>-<版本>，并将 <BinDir>/<命令名> 指向它
+// 资产可以是 tar.gz、tar.xz、tar.zst、zip 归档或未打包的可执行文件，筛选后必须只剩一个
+func (d *Downloader) Install(ctx context.Context, ref RepoRef, tag string, opts RepoOptions, inst InstallOptions) (err error) {
+    rs := d.beginRepo(ref.Owner, ref.Repo)
+    defer func() { d.finishRepo(rs, err) }()
+
+    name := inst.Name
+    if name == "" {
+        name = ref.Repo
+    }
+    installs, err := LoadInstalls(inst.BinDir)
+    if err != nil {
+        return err
+    }
+    current := installs[name]
+    if current != nil && current.Ref().key() != ref.key() {
+        return fmt.Errorf("命令 %s 已由 %s/%s 安装", name, current.Owner, current.Repo)
+    }
+    link := filepath.Join(inst.BinDir, exeName(name))
+    if current == nil {
+        if _, err := os.Lstat(link); err == nil {
+            return fmt.Errorf("%s 已存在且不是由本工具安装的", link)
+        }
+    } else if err := current.checkLink(inst.BinDir); err != nil {
+        return fmt.Errorf("%v，拒绝覆盖", err)
+    }
+
+    release, err := d.findRelease(ctx, ref, tag)
+    if err != nil {
+        return err
+    }
+    asset, format, err := selectInstallable(release.Assets, opts.Filter)
+    if err != nil {
+        return err
+    }
+    logger.Info("安装 %s %s: %s", name, release.TagName, asset.Name)
+
+    // 只下载要安装的资产以及用于校验它的文件
+    dl := opts
+    dl.Filter = onlyAsset(release.Assets, asset.Name)
+    if err := d.processRelease(ctx, ref, release, dl, rs); err != nil {
+        return fmt.Errorf("下载或校验失败: %w", err)
+    }
+
+    if err := os.MkdirAll(inst.BinDir, 0755); err != nil {
+        return err
+    }
+    src := filepath.Join(d.versionDir(ref.Repo, release.TagName), asset.Name)
+    if info, err := os.Stat(src); err != nil || (asset.Size > 0 && info.Size() != asset.Size) {
+        return fmt.Errorf("%s 没有下载完成", asset.Name)
+    }
+    file := exeName(name + "-" + release.TagName)
+    dst := filepath.Join(inst.BinDir, file)
+    var member string
+    if format == FormatBinary {
+        err = copyExecutable(src, dst)
+    } else if member, err = findExecutable(src, format, inst.Path, name); err == nil {
+        logger.Info("归档中的可执行文件: %s", member)
+        err = extractMember(src, format, member, dst)
+    }
+    if err != nil {
+        return err
+    }
+    copied, err := activate(inst.BinDir, name, file)
+    if err != nil {
+        return err
+    }
+
+    if current == nil {
+        m := newManifest(ref, release)
+        current = &Installation{Name: name, Provider: m.Provider, Host: m.Host, Owner: ref.Owner, Repo: ref.Repo}
+        installs[name] = current
+    }
+    current.Copied = copied
+    v := InstalledVersion{Tag: release.TagName, File: file, Asset: asset.Name, Member: member, InstalledAt: time.Now()}
+    if prev := current.Version(release.TagName); prev != nil {
+        *prev = v
+    } else {
+        current.Versions = append(current.Versions, v)
+    }
+    current.Active = release.TagName
+    logger.Info("已安装: %s -> %s", link, file)
+    return saveInstalls(inst.BinDir, installs)
+}
+
+// findRelease 返回仓库的指定版本，tag 为空时返回最新版本
+func (d *Downloader) findRelease(ctx context.Context, ref RepoRef, tag string) (*Release, error) {
+    releases, err := d.FetchReleases(ctx, ref, tag != "")
+    if err != nil {
+        return nil, fmt.Errorf("获取 release 失败: %w", err)
+    }
+    for _, r := range releases {
+        if tag == "" || r.TagName == tag {
+            return r, nil
+        }
+    }
+    if tag == "" {
+        return nil, fmt.Errorf("仓库 %s 没有可用的 Release", ref)
+    }
+    return nil, fmt.Errorf("仓库 %s 没有版本 %s", ref, tag)
+}
+
+// selectInstallable 从筛选后的资产中选出要安装的资产：同一平台有多种格式时按 formatRank 选择
+func selectInstallable(assets []Asset, filter AssetFilter) (Asset, string, error) {
+    var candidates []Asset
+    for _, a := range filter.Select(assets) {
+        if installFormat(a.Name) != "" {
+            candidates = append(candidates, a)
+        }
+    }
+    if len(candidates) == 0 {
+        return Asset{}, "", fmt.Errorf("没有可以安装的资产（支持 tar.gz、tar.xz、tar.zst、zip 和未打包的可执行文件），可用 -platform 或 -include 调整筛选规则")
+    }
+    // 筛选规则匹配多个架构时无法确定要安装哪一个
+    arches := make(map[string]bool)
+    for _, a := range candidates {
+        if _, goarch := detectPlatform(a.Name); goarch != "" && goarch != "universal" {
+            arches[goarch] = true
+        }
+    }
+    if len(arches) > 1 {
+        return Asset{}, "", fmt.Errorf("可以安装的资产属于多个平台: %s，请用 -platform 指定", assetNames(candidates))
+    }
+    sort.SliceStable(candidates, func(i, j int) bool {
+        return formatRank[installFormat(candidates[i].Name)] < formatRank[installFormat(candidates[j].Name)]
+    })
+    best := installFormat(candidates[0].Name)
+    if len(candidates) > 1 && installFormat(candidates[1].Name) == best {
+        return Asset{}, "", fmt.Errorf("有多个可以安装的资产: %s，请用 -include 指定", assetNames(candidates))
+    }
+    return candidates[0], best, nil
+}
+
+func assetNames(assets []Asset) string {
+    names := make([]string, len(assets))
+    for i, a := range assets {
+        names[i] = a.Name
+    }
+    return strings.Join(names, ", ")
+}
+
+// onlyAsset 返回只选择资产 name 及合并校验文件的筛选规则，name 的单文件校验文件和签名也会被选中
+func onlyAsset(assets []Asset, name string) AssetFilter {
+    include := []string{escapeGlob(name)}
+    for _, a := range assets {
+        if isChecksumFile(a.Name) {
+            include = append(include, escapeGlob(a.Name))
+        }
+    }
+    return AssetFilter{Include: include}
+}
+
+// escapeGlob 转义文件名中的通配符，使其只匹配自身
+func escapeGlob(name string) string {
+    var b strings.Builder
+    for _, c := range name {
+        if strings.ContainsRune(`*?[\`, c) {
+            b.WriteByte('\\')
+        }
+        b.WriteRune(c)
+    }
+    return b.String()
+}
+
+// activate 原子地将 <binDir>/<name> 指向安装目录中的 file；不支持符号链接时复制文件，此时 copied 为 true
+func activate(binDir, name, file string) (copied bool, err error) {
+    link := filepath.Join(binDir, exeName(name))
+    // 临时链接使用唯一的名称，避免并发安装时互相覆盖
+    tmp := fmt.Sprintf("%s.%s-%d.tmp", link, file, os.Getpid())
+    if err := os.Remove(tmp); err != nil && !os.IsNotExist(err) {
+        return false, err
+    }
+    if err := os.Symlink(file, tmp); err != nil {
+        if errors.Is(err, fs.ErrExist) {
+            return false, err
+        }
+        logger.Warn("无法创建符号链接（%v），改为复制 %s", err, file)
+        return true, copyExecutable(filepath.Join(binDir, file), link)
+    }
+    if err := os.Rename(tmp, link); err != nil {
+        os.Remove(tmp)
+        return false, err
+    }
+    return false, nil
+}
+
+// Switch 将已安装的命令切换到另一个已安装的版本
+func Switch(binDir, name, tag string) error {
+    installs, err := LoadInstalls(binDir)
+    if err != nil {
+        return err
+    }
+    in := installs[name]
+    if in == nil {
+        return fmt.Errorf("没有安装 %s", name)
+    }
+    v := in.Version(tag)
+    if v == nil {
+        return fmt.Errorf("%s 没有安装版本 %s，可以先运行 install %s/%s@%s", name, tag, in.Owner, in.Repo, tag)
+    }
+    if err := in.checkLink(binDir); err != nil {
+        return fmt.Errorf("%v，拒绝切换", err)
+    }
+    copied, err := activate(binDir, name, v.File)
+    if err != nil {
+        return err
+    }
+    in.Active, in.Copied = tag, copied
+    return saveInstalls(binDir, installs)
+}
+
+// Uninstall 删除已安装命令的一个版本（tag 不为空时）或全部版本
+// 不能单独删除正在使用的版本，除非它是唯一的版本
+func Uninstall(binDir, name, tag string) error {
+    installs, err := LoadInstalls(binDir)
+    if err != nil {
+        return err
+    }
+    in := installs[name]
+    if in == nil {
+        return fmt.Errorf("没有安装 %s", name)
+    }
+    if tag != "" {
+        v := in.Version(tag)
+        if v == nil {
+            return fmt.Errorf("%s 没有安装版本 %s", name, tag)
+        }
+        if tag == in.Active && len(in.Versions) > 1 {
+            return fmt.Errorf("%s 正在使用，请先用 switch 切换到其他版本", tag)
+        }
+    }
+
+    // 删除版本文件之前检查，副本需要与当前版本的文件比较
+    linkErr := in.checkLink(binDir)
+
+    var kept []InstalledVersion
+    for _, v := range in.Versions {
+        if tag != "" && v.Tag != tag {
+            kept = append(kept, v)
+            continue
+        }
+        if err := os.Remove(filepath.Join(binDir, v.File)); err != nil && !os.IsNotExist(err) {
+            return err
+        }
+        logger.Info("已删除: %s", filepath.Join(binDir, v.File))
+    }
+    if len(kept) == 0 {
+        if linkErr != nil {
+            // 不是由本工具管理的文件不删除，只删除安装记录
+            logger.Warn("%v，保留该文件", linkErr)
+        } else if err := os.Remove(filepath.Join(binDir, exeName(name))); err != nil && !os.IsNotExist(err) {
+            return err
+        }
+        delete(installs, name)
+    } else {
+        in.Versions = kept
+    }
+    return saveInstalls(binDir, installs)
+}
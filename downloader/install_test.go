@@ -0,0 +1,389 @@
+package downloader
+
+import (
+    "archive/tar"
+    "archive/zip"
+    "bytes"
+    "compress/gzip"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+
+    "github.com/klauspost/compress/zstd"
+    "github.com/ulikunitz/xz"
+)
+
+func TestSelectInstallable(t *testing.T) {
+    tests := []struct {
+        name    string
+        assets  []string
+        filter  AssetFilter
+        want    string
+        format  string
+        wantErr string
+    }{
+        {"按格式优先顺序选择", []string{"tool-linux-amd64.zip", "tool-linux-amd64.tar.gz", "checksums.txt"}, AssetFilter{}, "tool-linux-amd64.tar.gz", FormatTarGz, ""},
+        {"归档优先于未打包的可执行文件", []string{"tool-linux-amd64", "tool-linux-amd64.tar.zst"}, AssetFilter{}, "tool-linux-amd64.tar.zst", FormatTarZst, ""},
+        {"未打包的可执行文件", []string{"tool_1.0_linux_amd64", "SHA256SUMS"}, AssetFilter{}, "tool_1.0_linux_amd64", FormatBinary, ""},
+        {"按平台筛选", []string{"tool-linux-amd64.tar.gz", "tool-linux-arm64.tar.gz", "tool-darwin-arm64.tar.gz"}, AssetFilter{Platforms: []string{"linux/arm64"}}, "tool-linux-arm64.tar.gz", FormatTarGz, ""},
+        {"多个架构", []string{"tool-linux-amd64.tar.gz", "tool-linux-arm64.tar.gz"}, AssetFilter{}, "", "", "多个平台"},
+        {"同一格式有多个资产", []string{"tool-linux-amd64.tar.gz", "tool-linux-amd64-musl.tar.gz"}, AssetFilter{}, "", "", "多个可以安装的资产"},
+        {"没有可以安装的资产", []string{"checksums.txt", "tool.deb", "README.md"}, AssetFilter{}, "", "", "没有可以安装的资产"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            var assets []Asset
+            for _, name := range tt.assets {
+                assets = append(assets, Asset{Name: name})
+            }
+            got, format, err := selectInstallable(assets, tt.filter)
+            if tt.wantErr != "" {
+                if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+                    t.Fatalf("selectInstallable() error = %v, want 包含 %q", err, tt.wantErr)
+                }
+                return
+            }
+            if err != nil || got.Name != tt.want || format != tt.format {
+                t.Errorf("selectInstallable() = %s, %s, %v, want %s, %s", got.Name, format, err, tt.want, tt.format)
+            }
+        })
+    }
+}
+
+func TestOnlyAsset(t *testing.T) {
+    var assets []Asset
+    for _, name := range []string{"tool[1].tar.gz", "tool1.tar.gz", "tool[1].tar.gz.sha256", "tool1.tar.gz.sha256", "SHA256SUMS", "other.zip"} {
+        assets = append(assets, Asset{Name: name})
+    }
+    // 文件名中的通配符只匹配自身，不会选中 tool1.tar.gz
+    var got []string
+    for _, a := range onlyAsset(assets, "tool[1].tar.gz").Select(assets) {
+        got = append(got, a.Name)
+    }
+    if want := "tool[1].tar.gz,tool[1].tar.gz.sha256,SHA256SUMS"; strings.Join(got, ",") != want {
+        t.Errorf("onlyAsset().Select() = %v, want %s", got, want)
+    }
+}
+
+// archiveFile 是测试归档中的一个文件
+type archiveFile struct {
+    name    string
+    content string
+    mode    int64
+}
+
+var testArchive = []archiveFile{
+    {"tool-1.0/README.md", "readme", 0644},
+    {"tool-1.0/tool", "tool binary", 0755},
+    {"tool-1.0/helper", "helper binary", 0755},
+}
+
+// buildArchive 在 dir 中生成 format 格式的归档，返回归档路径
+func buildArchive(t *testing.T, dir, format string, files []archiveFile) string {
+    t.Helper()
+    var buf bytes.Buffer
+    if format == FormatZip {
+        zw := zip.NewWriter(&buf)
+        for _, f := range files {
+            hdr := &zip.FileHeader{Name: f.name, Method: zip.Deflate}
+            hdr.SetMode(os.FileMode(f.mode))
+            w, err := zw.CreateHeader(hdr)
+            if err != nil {
+                t.Fatal(err)
+            }
+            io.WriteString(w, f.content)
+        }
+        if err := zw.Close(); err != nil {
+            t.Fatal(err)
+        }
+    } else {
+        var w io.WriteCloser
+        var err error
+        switch format {
+        case FormatTarGz:
+            w = gzip.NewWriter(&buf)
+        case FormatTarXz:
+            w, err = xz.NewWriter(&buf)
+        case FormatTarZst:
+            w, err = zstd.NewWriter(&buf)
+        }
+        if err != nil {
+            t.Fatal(err)
+        }
+        tw := tar.NewWriter(w)
+        // 目录项应被忽略
+        tw.WriteHeader(&tar.Header{Name: "tool-1.0/", Typeflag: tar.TypeDir, Mode: 0755})
+        for _, f := range files {
+            if err := tw.WriteHeader(&tar.Header{Name: f.name, Typeflag: tar.TypeReg, Mode: f.mode, Size: int64(len(f.content))}); err != nil {
+                t.Fatal(err)
+            }
+            io.WriteString(tw, f.content)
+        }
+        if err := tw.Close(); err != nil {
+            t.Fatal(err)
+        }
+        if err := w.Close(); err != nil {
+            t.Fatal(err)
+        }
+    }
+    path := filepath.Join(dir, "tool."+format)
+    writeFile(t, path, buf.String())
+    return path
+}
+
+var archiveFormats = []string{FormatTarGz, FormatTarXz, FormatTarZst, FormatZip}
+
+func TestFindExecutable(t *testing.T) {
+    tests := []struct {
+        name    string
+        pattern string
+        command string
+        want    string
+        wantErr string
+    }{
+        {"与命令名同名的文件", "", "tool", "tool-1.0/tool", ""},
+        {"多个可执行文件", "", "other", "", "多个候选文件"},
+        {"按路径匹配", "*/helper", "tool", "tool-1.0/helper", ""},
+        {"按文件名匹配", "READ*", "tool", "tool-1.0/README.md", ""},
+        {"没有匹配的文件", "missing", "tool", "", "没有找到可执行文件"},
+    }
+    for _, format := range archiveFormats {
+        t.Run(format, func(t *testing.T) {
+            src := buildArchive(t, t.TempDir(), format, testArchive)
+            for _, tt := range tests {
+                got, err := findExecutable(src, format, tt.pattern, tt.command)
+                if tt.wantErr != "" {
+                    if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+                        t.Errorf("%s: findExecutable() = %q, %v, want 包含 %q 的错误", tt.name, got, err, tt.wantErr)
+                    }
+                    continue
+                }
+                if err != nil || got != tt.want {
+                    t.Errorf("%s: findExecutable() = %q, %v, want %q", tt.name, got, err, tt.want)
+                }
+            }
+        })
+    }
+
+    // 只有一个可执行文件且名称与命令名不同时使用它
+    src := buildArchive(t, t.TempDir(), FormatTarGz, testArchive[:2])
+    if got, err := findExecutable(src, FormatTarGz, "", "other"); err != nil || got != "tool-1.0/tool" {
+        t.Errorf("唯一的可执行文件: findExecutable() = %q, %v", got, err)
+    }
+}
+
+func TestExtractMember(t *testing.T) {
+    for _, format := range archiveFormats {
+        t.Run(format, func(t *testing.T) {
+            dir := t.TempDir()
+            src := buildArchive(t, dir, format, testArchive)
+            dst := filepath.Join(dir, "tool-v1.0")
+            if err := extractMember(src, format, "tool-1.0/tool", dst); err != nil {
+                t.Fatalf("extractMember() = %v", err)
+            }
+            data, err := os.ReadFile(dst)
+            if err != nil || string(data) != "tool binary" {
+                t.Errorf("解压的内容 = %q, %v", data, err)
+            }
+            if info, err := os.Stat(dst); err != nil || info.Mode()&0111 == 0 {
+                t.Errorf("解压的文件没有执行权限: %v, %v", info.Mode(), err)
+            }
+
+            missing := filepath.Join(dir, "missing")
+            if err := extractMember(src, format, "tool-1.0/missing", missing); err == nil {
+                t.Errorf("extractMember(不存在的文件) 应返回错误")
+            }
+            if _, err := os.Stat(missing); !os.IsNotExist(err) {
+                t.Errorf("不存在的文件不应生成 %s", missing)
+            }
+            // 不应留下临时文件
+            for _, name := range listDir(t, dir) {
+                if strings.HasSuffix(name, ".tmp") {
+                    t.Errorf("残留的临时文件: %s", name)
+                }
+            }
+        })
+    }
+}
+
+// newInstallDir 生成安装了 fzf 的 v1 和 v2 两个版本、当前为 v2 的安装目录
+func newInstallDir(t *testing.T) string {
+    t.Helper()
+    binDir := t.TempDir()
+    in := &Installation{Name: "fzf", Provider: "github", Host: "github.com", Owner: "junegunn", Repo: "fzf", Active: "v2"}
+    for _, tag := range []string{"v1", "v2"} {
+        file := exeName("fzf-" + tag)
+        writeFile(t, filepath.Join(binDir, file), "fzf "+tag)
+        in.Versions = append(in.Versions, InstalledVersion{Tag: tag, File: file})
+    }
+    if err := os.Symlink(exeName("fzf-v2"), filepath.Join(binDir, exeName("fzf"))); err != nil {
+        t.Skipf("不支持符号链接: %v", err)
+    }
+    if err := saveInstalls(binDir, map[string]*Installation{"fzf": in}); err != nil {
+        t.Fatal(err)
+    }
+    return binDir
+}
+
+// readCommand 返回 fzf 的内容，不存在时返回空字符串
+func readCommand(t *testing.T, binDir string) string {
+    t.Helper()
+    data, err := os.ReadFile(filepath.Join(binDir, exeName("fzf")))
+    if os.IsNotExist(err) {
+        return ""
+    }
+    if err != nil {
+        t.Fatal(err)
+    }
+    return string(data)
+}
+
+func TestSwitchAndUninstall(t *testing.T) {
+    binDir := newInstallDir(t)
+    steps := []struct {
+        op      string // switch 或 uninstall
+        tag     string
+        wantErr string
+        want    string // 操作后 fzf 的内容
+        active  string // 操作后的当前版本，空字符串表示已删除安装记录
+    }{
+        {"switch", "v3", "没有安装版本", "fzf v2", "v2"},
+        {"switch", "v1", "", "fzf v1", "v1"},
+        {"uninstall", "v1", "正在使用", "fzf v1", "v1"},
+        {"uninstall", "v3", "没有安装版本", "fzf v1", "v1"},
+        {"uninstall", "v2", "", "fzf v1", "v1"},
+        // 唯一的版本可以删除，同时删除链接和安装记录
+        {"uninstall", "v1", "", "", ""},
+        {"switch", "v1", "没有安装", "", ""},
+    }
+    for i, s := range steps {
+        var err error
+        if s.op == "switch" {
+            err = Switch(binDir, "fzf", s.tag)
+        } else {
+            err = Uninstall(binDir, "fzf", s.tag)
+        }
+        if (err != nil) != (s.wantErr != "") || (err != nil && !strings.Contains(err.Error(), s.wantErr)) {
+            t.Fatalf("步骤 %d: %s %s error = %v, want %q", i, s.op, s.tag, err, s.wantErr)
+        }
+        if got := readCommand(t, binDir); got != s.want {
+            t.Errorf("步骤 %d: fzf = %q, want %q", i, got, s.want)
+        }
+        installs, err := LoadInstalls(binDir)
+        if err != nil {
+            t.Fatal(err)
+        }
+        active := ""
+        if in := installs["fzf"]; in != nil {
+            active = in.Active
+        }
+        if active != s.active {
+            t.Errorf("步骤 %d: 当前版本 = %q, want %q", i, active, s.active)
+        }
+    }
+    if _, err := os.Stat(filepath.Join(binDir, exeName("fzf-v2"))); !os.IsNotExist(err) {
+        t.Errorf("删除的版本文件仍然存在")
+    }
+}
+
+func TestUnmanagedLink(t *testing.T) {
+    tests := []struct {
+        name    string
+        replace func(t *testing.T, binDir, link string)
+        copied  bool
+        managed bool
+    }{
+        {"指向已安装版本的符号链接", func(t *testing.T, binDir, link string) {}, false, true},
+        {"指向其他文件的符号链接", func(t *testing.T, binDir, link string) {
+            os.Remove(link)
+            os.Symlink("/usr/bin/true", link)
+        }, false, false},
+        {"被替换为普通文件", func(t *testing.T, binDir, link string) {
+            os.Remove(link)
+            writeFile(t, link, "fzf v2")
+        }, false, false},
+        {"与当前版本相同的副本", func(t *testing.T, binDir, link string) {
+            os.Remove(link)
+            writeFile(t, link, "fzf v2")
+        }, true, true},
+        {"被修改的副本", func(t *testing.T, binDir, link string) {
+            os.Remove(link)
+            writeFile(t, link, "someone else's fzf")
+        }, true, false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            binDir := newInstallDir(t)
+            link := filepath.Join(binDir, exeName("fzf"))
+            tt.replace(t, binDir, link)
+            installs, _ := LoadInstalls(binDir)
+            installs["fzf"].Copied = tt.copied
+            if err := saveInstalls(binDir, installs); err != nil {
+                t.Fatal(err)
+            }
+            before := readLinkOrFile(t, link)
+
+            err := Switch(binDir, "fzf", "v1")
+            if (err == nil) != tt.managed {
+                t.Fatalf("Switch() = %v, managed %v", err, tt.managed)
+            }
+            if !tt.managed {
+                if got := readLinkOrFile(t, link); got != before {
+                    t.Errorf("Switch 修改了不受管理的 %s: %q -> %q", link, before, got)
+                }
+            }
+
+            // 删除全部版本时不删除不受管理的文件，但删除版本文件和安装记录
+            if err := Uninstall(binDir, "fzf", ""); err != nil {
+                t.Fatalf("Uninstall() = %v", err)
+            }
+            _, statErr := os.Lstat(link)
+            if exists := statErr == nil; exists == tt.managed {
+                t.Errorf("Uninstall 后 %s 存在 = %v, managed %v", link, exists, tt.managed)
+            }
+            if names := strings.Join(listDir(t, binDir), ","); strings.Contains(names, "fzf-v") {
+                t.Errorf("版本文件没有删除: %s", names)
+            }
+            if installs, _ := LoadInstalls(binDir); installs["fzf"] != nil {
+                t.Errorf("安装记录没有删除")
+            }
+        })
+    }
+}
+
+// readLinkOrFile 返回符号链接的目标，普通文件返回其内容
+func readLinkOrFile(t *testing.T, path string) string {
+    t.Helper()
+    if target, err := os.Readlink(path); err == nil {
+        return "-> " + target
+    }
+    data, err := os.ReadFile(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return string(data)
+}
+
+func TestActivate(t *testing.T) {
+    binDir := t.TempDir()
+    writeFile(t, filepath.Join(binDir, "fzf-v1"), "fzf v1")
+    link := filepath.Join(binDir, exeName("fzf"))
+    // 上次中断留下的同名临时链接会先被删除
+    tmp := fmt.Sprintf("%s.%s-%d.tmp", link, "fzf-v1", os.Getpid())
+    if err := os.Symlink("stale", tmp); err != nil {
+        t.Skipf("不支持符号链接: %v", err)
+    }
+    copied, err := activate(binDir, "fzf", "fzf-v1")
+    if err != nil || copied {
+        t.Fatalf("activate() = %v, %v", copied, err)
+    }
+    if target, err := os.Readlink(link); err != nil || target != "fzf-v1" {
+        t.Errorf("fzf -> %q, %v, want fzf-v1", target, err)
+    }
+    if names := strings.Join(listDir(t, binDir), ","); names != "fzf,fzf-v1" {
+        t.Errorf("安装目录 = %s", names)
+    }
+}
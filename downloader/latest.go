@@ -0,0 +1,199 @@
+package downloader
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io/fs"
+    "os"
+    "path/filepath"
+    "strings"
+    "sync"
+    "time"
+
+    "github-downloader/logger"
+)
+
+// 仓库目录中指向最新版本的文件
+const (
+    LatestLink = "latest"      // 指向最新版本目录的符号链接
+    LatestFile = "LATEST"      // 不支持符号链接时改用的文本文件，内容为版本号
+    LatestJSON = "latest.json" // 最新版本及其资产的信息（可选）
+)
+
+// LatestInfo 是 latest.json 的内容
+type LatestInfo struct {
+    Tag         string        `json:"tag"`
+    Dir         string        `json:"dir"` // 相对于仓库目录的版本目录
+    PublishedAt time.Time     `json:"published_at"`
+    UpdatedAt   time.Time     `json:"updated_at"`
+    Assets      []LatestAsset `json:"assets"`
+}
+
+// LatestAsset 是 latest.json 中的单个资产
+type LatestAsset struct {
+    Name   string `json:"name"`
+    Path   string `json:"path"` // 相对于仓库目录的路径
+    Size   int64  `json:"size"`
+    SHA256 string `json:"sha256"`
+    URL    string `json:"url"`
+}
+
+// SetLatestJSON 设置是否在更新最新版本指针时同时写入 latest.json
+func (d *Downloader) SetLatestJSON(enabled bool) {
+    d.latestJSON = enabled
+}
+
+// ReadLatest 返回仓库目录中 latest 符号链接或 LATEST 文件指向的版本，不存在时返回空字符串
+func ReadLatest(repoDir string) string {
+    if target, err := os.Readlink(filepath.Join(repoDir, LatestLink)); err == nil {
+        return filepath.Base(target)
+    }
+    if data, err := os.ReadFile(filepath.Join(repoDir, LatestFile)); err == nil {
+        return strings.TrimSpace(string(data))
+    }
+    return ""
+}
+
+// lockLatest 锁定仓库目录的最新版本指针，返回解锁函数
+// 同一仓库的多个版本并发处理（-all）时，读取、比较和写入指针必须作为一个整体进行
+func (d *Downloader) lockLatest(repoDir string) func() {
+    d.latestMu.Lock()
+    mu := d.latestLocks[repoDir]
+    if mu == nil {
+        if d.latestLocks == nil {
+            d.latestLocks = make(map[string]*sync.Mutex)
+        }
+        mu = &sync.Mutex{}
+        d.latestLocks[repoDir] = mu
+    }
+    d.latestMu.Unlock()
+    mu.Lock()
+    return mu.Unlock
+}
+
+// updateLatest 在版本校验通过后，将仓库的最新版本指针指向该版本
+// 只会向更新的版本移动：预发布版本，以及比当前指向的版本更早发布的版本不会更新指针
+func (d *Downloader) updateLatest(versionDir string, release *Release) {
+    if release.Prerelease {
+        return
+    }
+    repoDir, tag := filepath.Dir(versionDir), filepath.Base(versionDir)
+    defer d.lockLatest(repoDir)()
+    current := ReadLatest(repoDir)
+    if current == tag {
+        // 版本重新下载过时 manifest.json 会比 latest.json 新
+        if d.latestJSON && newerThan(filepath.Join(versionDir, manifestFile), filepath.Join(repoDir, LatestJSON)) {
+            if err := writeLatestJSON(repoDir, versionDir, release); err != nil {
+                logger.Warn("无法写入 %s: %v", LatestJSON, err)
+            }
+        }
+        return
+    }
+    if current != "" {
+        if m, err := ReadManifest(filepath.Join(repoDir, current)); err == nil && m.PublishedAt.After(release.PublishedAt) {
+            return
+        }
+    }
+
+    if err := writeLatestPointer(repoDir, tag); err != nil {
+        logger.Warn("无法更新最新版本指针: %v", err)
+        return
+    }
+    if d.latestJSON {
+        if err := writeLatestJSON(repoDir, versionDir, release); err != nil {
+            logger.Warn("无法写入 %s: %v", LatestJSON, err)
+        }
+    }
+    logger.Info("最新版本指针已更新: %s -> %s", filepath.Join(repoDir, LatestLink), tag)
+}
+
+// newerThan 判断 a 的修改时间晚于 b，b 不存在时返回 true
+func newerThan(a, b string) bool {
+    bi, err := os.Stat(b)
+    if err != nil {
+        return true
+    }
+    ai, err := os.Stat(a)
+    return err == nil && ai.ModTime().After(bi.ModTime())
+}
+
+// writeLatestPointer 原子地将 latest 符号链接指向 tag（先创建临时链接再重命名）
+// 无法创建符号链接时改为写入 LATEST 文件
+func writeLatestPointer(repoDir, tag string) error {
+    link := filepath.Join(repoDir, LatestLink)
+    if info, err := os.Lstat(link); err == nil && info.Mode()&os.ModeSymlink == 0 {
+        // 有版本号恰好为 latest 的版本目录
+        logger.Warn("%s 不是符号链接，改用 %s", link, LatestFile)
+        return writeFileAtomic(filepath.Join(repoDir, LatestFile), []byte(tag+"\n"))
+    }
+    // 临时链接名包含版本号和进程号，其他进程同时更新同一仓库时不会删除或覆盖这里的临时链接
+    tmp := fmt.Sprintf("%s.%s-%d.tmp", link, tag, os.Getpid())
+    os.Remove(tmp)
+    if err := os.Symlink(tag, tmp); err != nil {
+        if errors.Is(err, fs.ErrExist) {
+            return err
+        }
+        // 文件系统不支持符号链接
+        return writeFileAtomic(filepath.Join(repoDir, LatestFile), []byte(tag+"\n"))
+    }
+    if err := os.Rename(tmp, link); err != nil {
+        os.Remove(tmp)
+        return err
+    }
+    // 文件系统支持符号链接后，不再需要 LATEST 文件
+    os.Remove(filepath.Join(repoDir, LatestFile))
+    return nil
+}
+
+// writeLatestJSON 根据版本目录的 manifest.json 写入 latest.json
+func writeLatestJSON(repoDir, versionDir string, release *Release) error {
+    tag := filepath.Base(versionDir)
+    info := LatestInfo{
+        Tag:         tag,
+        Dir:         tag,
+        PublishedAt: release.PublishedAt,
+        UpdatedAt:   time.Now(),
+        Assets:      []LatestAsset{},
+    }
+    if m, err := ReadManifest(versionDir); err == nil {
+        for _, ma := range m.Assets {
+            info.Assets = append(info.Assets, LatestAsset{
+                Name:   ma.Name,
+                Path:   tag + "/" + ma.Name,
+                Size:   ma.Size,
+                SHA256: ma.SHA256,
+                URL:    ma.URL,
+            })
+        }
+    }
+    data, err := json.MarshalIndent(info, "", "  ")
+    if err != nil {
+        return err
+    }
+    return writeFileAtomic(filepath.Join(repoDir, LatestJSON), data)
+}
+
+// writeFileAtomic 先写临时文件再重命名，读取方不会看到不完整的内容
+// 临时文件名是唯一的，同时写入同一文件时不会互相覆盖临时文件
+func writeFileAtomic(path string, data []byte) error {
+    f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+    if err != nil {
+        return err
+    }
+    _, err = f.Write(data)
+    if cerr := f.Close(); err == nil {
+        err = cerr
+    }
+    if err == nil {
+        err = os.Chmod(f.Name(), 0644)
+    }
+    if err == nil {
+        err = os.Rename(f.Name(), path)
+    }
+    if err != nil {
+        os.Remove(f.Name())
+        return err
+    }
+    return nil
+}
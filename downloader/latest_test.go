@@ -0,0 +1,216 @@
+package downloader
+
+import (
+    "encoding/json"
+    "fmt"
+    "os"
+    "path/filepath"
+    "runtime"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+)
+
+// newLatestRepo 在 top/tool 下创建版本目录，按 tags 的顺序发布（后面的更新），返回仓库目录和对应的 Release
+func newLatestRepo(t *testing.T, tags ...string) (string, map[string]*Release) {
+    t.Helper()
+    repoDir := filepath.Join(t.TempDir(), "tool")
+    base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+    releases := make(map[string]*Release)
+    for i, tag := range tags {
+        dir := filepath.Join(repoDir, tag)
+        published := base.AddDate(0, 0, i)
+        writeFile(t, filepath.Join(dir, releaseNotesFile), "")
+        writeFile(t, filepath.Join(dir, "tool.tar.gz"), tag)
+        m := &Manifest{Version: manifestVersion, Owner: "acme", Repo: "tool", Tag: tag, PublishedAt: published,
+            Assets: []ManifestAsset{{Name: "tool.tar.gz", Size: int64(len(tag)), SHA256: "00", URL: "https://example.com/" + tag}}}
+        if err := writeManifest(dir, m); err != nil {
+            t.Fatal(err)
+        }
+        releases[tag] = &Release{TagName: tag, PublishedAt: published}
+    }
+    return repoDir, releases
+}
+
+func TestUpdateLatestConcurrent(t *testing.T) {
+    var tags []string
+    for i := 1; i <= 20; i++ {
+        tags = append(tags, fmt.Sprintf("v1.%d.0", i))
+    }
+    newest := tags[len(tags)-1]
+    // 单核机器上协程几乎不会交错执行，提高并行度才能暴露竞争
+    defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(8))
+    for round := 0; round < 5; round++ {
+        repoDir, releases := newLatestRepo(t, tags...)
+        d := NewDownloader(filepath.Dir(repoDir), nil)
+        d.SetLatestJSON(true)
+
+        // 与 -all 一样，同一仓库的多个版本同时完成校验
+        var wg sync.WaitGroup
+        for _, tag := range tags {
+            wg.Add(1)
+            go func(tag string) {
+                defer wg.Done()
+                d.updateLatest(filepath.Join(repoDir, tag), releases[tag])
+            }(tag)
+        }
+        wg.Wait()
+
+        if got := ReadLatest(repoDir); got != newest {
+            t.Fatalf("第 %d 轮: 指针指向 %s, want %s", round, got, newest)
+        }
+        if _, err := os.Lstat(filepath.Join(repoDir, LatestFile)); !os.IsNotExist(err) {
+            t.Errorf("第 %d 轮: 符号链接可用时不应写入 %s", round, LatestFile)
+        }
+        if info := readLatestInfo(t, repoDir); info.Tag != newest {
+            t.Errorf("第 %d 轮: %s 指向 %s, want %s", round, LatestJSON, info.Tag, newest)
+        }
+        entries, _ := os.ReadDir(repoDir)
+        for _, e := range entries {
+            if strings.HasSuffix(e.Name(), ".tmp") {
+                t.Errorf("第 %d 轮: 残留临时文件 %s", round, e.Name())
+            }
+        }
+    }
+}
+
+func readLatestInfo(t *testing.T, repoDir string) LatestInfo {
+    t.Helper()
+    data, err := os.ReadFile(filepath.Join(repoDir, LatestJSON))
+    if err != nil {
+        t.Fatal(err)
+    }
+    var info LatestInfo
+    if err := json.Unmarshal(data, &info); err != nil {
+        t.Fatal(err)
+    }
+    return info
+}
+
+func TestUpdateLatestSymlink(t *testing.T) {
+    repoDir, releases := newLatestRepo(t, "v1.0.0", "v1.1.0", "v2.0.0-rc1")
+    releases["v2.0.0-rc1"].Prerelease = true
+    d := NewDownloader(filepath.Dir(repoDir), nil)
+    // 之前在不支持符号链接的文件系统上写入的 LATEST 文件
+    writeFile(t, filepath.Join(repoDir, LatestFile), "v0.9.0\n")
+
+    steps := []struct {
+        tag  string
+        want string
+    }{
+        {"v1.0.0", "v1.0.0"},
+        {"v1.1.0", "v1.1.0"},
+        {"v1.0.0", "v1.1.0"},     // 更早发布的版本（例如 -all 中后完成的旧版本）不会让指针后退
+        {"v2.0.0-rc1", "v1.1.0"}, // 预发布版本不更新指针
+    }
+    for _, step := range steps {
+        d.updateLatest(filepath.Join(repoDir, step.tag), releases[step.tag])
+        target, err := os.Readlink(filepath.Join(repoDir, LatestLink))
+        if err != nil {
+            t.Fatalf("更新 %s 后 %s 不是符号链接: %v", step.tag, LatestLink, err)
+        }
+        if target != step.want || ReadLatest(repoDir) != step.want {
+            t.Errorf("更新 %s 后指针指向 %s, want %s", step.tag, target, step.want)
+        }
+    }
+    // 链接是相对路径，仓库目录整体移动后仍然有效
+    if _, err := os.Stat(filepath.Join(repoDir, LatestLink, "tool.tar.gz")); err != nil {
+        t.Errorf("无法通过 %s 访问版本目录: %v", LatestLink, err)
+    }
+    if _, err := os.Stat(filepath.Join(repoDir, LatestFile)); !os.IsNotExist(err) {
+        t.Errorf("写入符号链接后应删除 %s", LatestFile)
+    }
+    if _, err := os.Stat(filepath.Join(repoDir, LatestJSON)); !os.IsNotExist(err) {
+        t.Errorf("未启用时不应写入 %s", LatestJSON)
+    }
+}
+
+func TestUpdateLatestFallbackFile(t *testing.T) {
+    // 有版本号恰好为 latest 的版本目录时，不能创建同名的符号链接
+    repoDir, releases := newLatestRepo(t, LatestLink, "v1.0.0", "v1.1.0")
+    d := NewDownloader(filepath.Dir(repoDir), nil)
+
+    d.updateLatest(filepath.Join(repoDir, "v1.0.0"), releases["v1.0.0"])
+    d.updateLatest(filepath.Join(repoDir, "v1.1.0"), releases["v1.1.0"])
+    d.updateLatest(filepath.Join(repoDir, "v1.0.0"), releases["v1.0.0"])
+
+    data, err := os.ReadFile(filepath.Join(repoDir, LatestFile))
+    if err != nil || string(data) != "v1.1.0\n" {
+        t.Fatalf("%s = %q, %v, want v1.1.0", LatestFile, data, err)
+    }
+    if got := ReadLatest(repoDir); got != "v1.1.0" {
+        t.Errorf("ReadLatest() = %s, want v1.1.0", got)
+    }
+    if info, err := os.Lstat(filepath.Join(repoDir, LatestLink)); err != nil || !info.IsDir() {
+        t.Errorf("版本目录 %s 被改动: %v", LatestLink, err)
+    }
+}
+
+func TestUpdateLatestJSON(t *testing.T) {
+    repoDir, releases := newLatestRepo(t, "v1.0.0", "v1.1.0")
+    d := NewDownloader(filepath.Dir(repoDir), nil)
+    d.SetLatestJSON(true)
+    jsonPath := filepath.Join(repoDir, LatestJSON)
+
+    d.updateLatest(filepath.Join(repoDir, "v1.1.0"), releases["v1.1.0"])
+    info := readLatestInfo(t, repoDir)
+    if info.Tag != "v1.1.0" || info.Dir != "v1.1.0" || !info.PublishedAt.Equal(releases["v1.1.0"].PublishedAt) {
+        t.Errorf("%s = %+v", LatestJSON, info)
+    }
+    if len(info.Assets) != 1 || info.Assets[0].Path != "v1.1.0/tool.tar.gz" || info.Assets[0].URL != "https://example.com/v1.1.0" {
+        t.Errorf("assets = %+v", info.Assets)
+    }
+
+    // 更早发布的版本不改写 latest.json
+    d.updateLatest(filepath.Join(repoDir, "v1.0.0"), releases["v1.0.0"])
+    if info := readLatestInfo(t, repoDir); info.Tag != "v1.1.0" {
+        t.Errorf("更早的版本改写了 %s: %s", LatestJSON, info.Tag)
+    }
+
+    // 指针没有变化、manifest.json 也没有更新时不改写
+    future := time.Now().Add(time.Hour)
+    if err := os.Chtimes(jsonPath, future, future); err != nil {
+        t.Fatal(err)
+    }
+    d.updateLatest(filepath.Join(repoDir, "v1.1.0"), releases["v1.1.0"])
+    if fi, _ := os.Stat(jsonPath); !fi.ModTime().Equal(future) {
+        t.Errorf("manifest.json 没有更新时不应改写 %s", LatestJSON)
+    }
+
+    // 版本重新下载后 manifest.json 比 latest.json 新，改写 latest.json
+    m, err := ReadManifest(filepath.Join(repoDir, "v1.1.0"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    m.Assets = append(m.Assets, ManifestAsset{Name: "tool.zip", Size: 3, SHA256: "11"})
+    if err := writeManifest(filepath.Join(repoDir, "v1.1.0"), m); err != nil {
+        t.Fatal(err)
+    }
+    past := time.Now().Add(-time.Hour)
+    if err := os.Chtimes(jsonPath, past, past); err != nil {
+        t.Fatal(err)
+    }
+    d.updateLatest(filepath.Join(repoDir, "v1.1.0"), releases["v1.1.0"])
+    if info := readLatestInfo(t, repoDir); len(info.Assets) != 2 {
+        t.Errorf("manifest.json 更新后 %s 没有重新生成: %+v", LatestJSON, info.Assets)
+    }
+}
+
+func TestReadLatest(t *testing.T) {
+    repoDir := t.TempDir()
+    if got := ReadLatest(repoDir); got != "" {
+        t.Errorf("没有指针时 ReadLatest() = %q", got)
+    }
+    writeFile(t, filepath.Join(repoDir, LatestFile), "  v1.0.0\n")
+    if got := ReadLatest(repoDir); got != "v1.0.0" {
+        t.Errorf("ReadLatest() = %q, want v1.0.0", got)
+    }
+    // 符号链接优先于 LATEST 文件
+    if err := os.Symlink("v2.0.0", filepath.Join(repoDir, LatestLink)); err != nil {
+        t.Skip(err)
+    }
+    if got := ReadLatest(repoDir); got != "v2.0.0" {
+        t.Errorf("ReadLatest() = %q, want v2.0.0", got)
+    }
+}
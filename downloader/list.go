@@ -0,0 +1,44 @@
+package downloader
+
+import (
+    "context"
+    "fmt"
+)
+
+// RepoRef 标识一个 GitHub 或 GitLab 仓库
+type RepoRef struct {
+    GitLab bool
+    Host   string // GitLab 实例主机名，为空时使用默认实例；GitHub 仓库忽略
+    Owner  string
+    Repo   string
+}
+
+func (r RepoRef) String() string {
+    return fmt.Sprintf("%s/%s", r.Owner, r.Repo)
+}
+
+// FetchReleases 获取仓库的 release 元数据但不下载，all 为 false 时只返回最新版本
+func (d *Downloader) FetchReleases(ctx context.Context, ref RepoRef, all bool) ([]*Release, error) {
+    if ref.GitLab {
+        if all {
+            return d.fetchGitLabAllReleases(ctx, ref.Host, ref.Owner, ref.Repo)
+        }
+        release, err := d.fetchGitLabLatestRelease(ctx, ref.Host, ref.Owner, ref.Repo)
+        if err != nil {
+            return nil, err
+        }
+        return []*Release{release}, nil
+    }
+
+    if all {
+        return d.fetchAllReleases(ctx, ref.Owner, ref.Repo)
+    }
+    release, err := d.fetchLatestRelease(ctx, ref.Owner, ref.Repo)
+    if err != nil {
+        return nil, err
+    }
+    if release.TagName == "" {
+        return nil, nil
+    }
+    return []*Release{release}, nil
+}
@@ -0,0 +1,187 @@
+package downloader
+
+import (
+    "encoding/json"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "time"
+)
+
+// manifestFile 是版本目录中记录来源和下载信息的文件
+const manifestFile = "manifest.json"
+
+// manifestVersion 是 manifest.json 的格式版本，格式不兼容地变化时递增
+const manifestVersion = 1
+
+// 仓库所在的平台
+const (
+    ProviderGitHub = "github"
+    ProviderGitLab = "gitlab"
+)
+
+// githubHost 是 GitHub 仓库在 manifest.json 中记录的主机名
+const githubHost = "github.com"
+
+// Manifest 描述一个版本目录的来源以及其中每个资产的下载信息
+type Manifest struct {
+    Version     int             `json:"version"`
+    Provider    string          `json:"provider"` // github 或 gitlab
+    Host        string          `json:"host"`
+    Owner       string          `json:"owner"`
+    Repo        string          `json:"repo"`
+    Tag         string          `json:"tag"`
+    ReleaseID   int64           `json:"release_id,omitempty"` // GitLab 没有数字 ID
+    PublishedAt time.Time       `json:"published_at"`
+    Prerelease  bool            `json:"prerelease"`
+    UpdatedAt   time.Time       `json:"updated_at"`
+    Assets      []ManifestAsset `json:"assets"`
+}
+
+// ManifestAsset 是单个资产的下载记录
+type ManifestAsset struct {
+    Name           string    `json:"name"`
+    URL            string    `json:"url"`
+    Proxy          string    `json:"proxy,omitempty"` // 直接下载时为空
+    Size           int64     `json:"size"`
+    SHA256         string    `json:"sha256"`
+    UpstreamDigest string    `json:"upstream_digest,omitempty"` // API 返回的官方摘要，如 sha256:...
+    DownloadedAt   time.Time `json:"downloaded_at"`
+    ModTime        time.Time `json:"mtime"` // 记录时文件的修改时间，用于判断文件是否被改动
+}
+
+// newManifest 根据仓库和 release 信息创建不含资产的 manifest
+func newManifest(ref RepoRef, release *Release) *Manifest {
+    m := &Manifest{
+        Version:     manifestVersion,
+        Provider:    ProviderGitHub,
+        Host:        githubHost,
+        Owner:       ref.Owner,
+        Repo:        ref.Repo,
+        Tag:         release.TagName,
+        ReleaseID:   release.ID,
+        PublishedAt: release.PublishedAt,
+        Prerelease:  release.Prerelease,
+    }
+    if ref.GitLab {
+        m.Provider, m.Host = ProviderGitLab, ref.Host
+        if m.Host == "" {
+            m.Host = defaultGitLabHost
+        }
+    }
+    return m
+}
+
+// Ref 返回 manifest 记录的仓库
+func (m *Manifest) Ref() RepoRef {
+    ref := RepoRef{Owner: m.Owner, Repo: m.Repo}
+    if m.Provider == ProviderGitLab {
+        ref.GitLab, ref.Host = true, m.Host
+    }
+    return ref
+}
+
+// Asset 返回资产的下载记录，不存在时返回 nil
+func (m *Manifest) Asset(name string) *ManifestAsset {
+    if m == nil {
+        return nil
+    }
+    for i := range m.Assets {
+        if m.Assets[i].Name == name {
+            return &m.Assets[i]
+        }
+    }
+    return nil
+}
+
+// ReadManifest 读取版本目录中的 manifest.json
+func ReadManifest(dir string) (*Manifest, error) {
+    data, err := os.ReadFile(filepath.Join(dir, manifestFile))
+    if err != nil {
+        return nil, err
+    }
+    var m Manifest
+    if err := json.Unmarshal(data, &m); err != nil {
+        return nil, fmt.Errorf("解析 %s 失败: %w", manifestFile, err)
+    }
+    return &m, nil
+}
+
+// writeManifest 写入 manifest.json（先写临时文件再重命名，避免留下不完整的文件）
+func writeManifest(dir string, m *Manifest) error {
+    sort.Slice(m.Assets, func(i, j int) bool { return m.Assets[i].Name < m.Assets[j].Name })
+    m.UpdatedAt = time.Now()
+    data, err := json.MarshalIndent(m, "", "  ")
+    if err != nil {
+        return err
+    }
+    path := filepath.Join(dir, manifestFile)
+    if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+        return err
+    }
+    return os.Rename(path+".tmp", path)
+}
+
+// unchanged 判断本地文件是否与记录时相同（大小和修改时间一致），且记录的哈希与 expected 相符
+// 用于增量运行时跳过已下载的文件而无需重新计算哈希；无法只凭记录判断时返回 false
+func (ma *ManifestAsset) unchanged(localPath string, asset Asset, expected Digest) bool {
+    if ma == nil || ma.Size != asset.Size || ma.SHA256 == "" {
+        return false
+    }
+    info, err := os.Stat(localPath)
+    if err != nil || info.Size() != ma.Size || !info.ModTime().Equal(ma.ModTime) {
+        return false
+    }
+    switch {
+    case expected.IsZero():
+        return true
+    case expected.Algo == "sha256":
+        return strings.EqualFold(expected.Hex, ma.SHA256)
+    }
+    // 记录中只有 SHA-256，其他算法需要重新计算
+    return false
+}
+
+// record 生成资产的下载记录；prev 为上次的记录，文件未重新下载时沿用其来源和下载时间
+// 已知 SHA-256（官方哈希、上次的记录或状态库）时不重新计算
+func record(localPath string, asset Asset, expected Digest, res *fetchResult, prev *ManifestAsset, st *State) (ManifestAsset, error) {
+    info, err := os.Stat(localPath)
+    if err != nil {
+        return ManifestAsset{}, err
+    }
+    ma := ManifestAsset{
+        Name:           asset.Name,
+        URL:            asset.BrowserDownloadURL,
+        Size:           info.Size(),
+        UpstreamDigest: asset.Digest,
+        DownloadedAt:   time.Now(),
+        ModTime:        info.ModTime(),
+    }
+    if res.skipped {
+        if prev != nil {
+            ma.Proxy, ma.DownloadedAt = prev.Proxy, prev.DownloadedAt
+        } else {
+            // 没有记录的旧文件，以修改时间作为下载时间
+            ma.DownloadedAt = info.ModTime()
+        }
+    } else {
+        ma.Proxy = res.proxy
+    }
+
+    switch {
+    case expected.Algo == "sha256":
+        // 下载或跳过时已验证过 SHA-256
+        ma.SHA256 = strings.ToLower(expected.Hex)
+    case prev.unchanged(localPath, asset, Digest{}):
+        ma.SHA256 = prev.SHA256
+    default:
+        if sums := st.digests(localPath, []string{"sha256"}); sums != nil {
+            ma.SHA256 = sums["sha256"]
+        } else if ma.SHA256, err = computeSHA256(localPath); err != nil {
+            return ManifestAsset{}, err
+        }
+    }
+    return ma, nil
+}
@@ -0,0 +1,101 @@
+package downloader
+
+import (
+    "context"
+    "os"
+    "path/filepath"
+)
+
+// 资产计划动作
+const (
+    PlanDownload = "download" // 本地不存在，需要下载
+    PlanReplace  = "replace"  // 本地文件不完整或哈希不匹配，需要重新下载
+    PlanSkip     = "skip"     // 本地文件完整，无需下载
+)
+
+// AssetPlan 描述单个资产在同步时将执行的动作
+type AssetPlan struct {
+    Name   string
+    Size   int64
+    Action string
+    Reason string // 替换或跳过的原因
+}
+
+// ReleasePlan 描述单个版本在同步时将执行的动作
+type ReleasePlan struct {
+    Repo       RepoRef
+    Tag        string
+    Dir        string
+    NewVersion bool // 版本目录尚不存在
+    Assets     []AssetPlan
+    Excluded   int // 被筛选规则排除的资产数
+}
+
+// Bytes 返回需要传输的字节数
+func (p *ReleasePlan) Bytes() int64 {
+    var total int64
+    for _, a := range p.Assets {
+        if a.Action != PlanSkip {
+            total += a.Size
+        }
+    }
+    return total
+}
+
+// Count 返回指定动作的资产数
+func (p *ReleasePlan) Count(action string) int {
+    n := 0
+    for _, a := range p.Assets {
+        if a.Action == action {
+            n++
+        }
+    }
+    return n
+}
+
+// PlanRepo 获取仓库元数据并与本地文件比较，返回同步计划，不会写入任何文件
+// 判断规则与实际下载时相同：有官方 SHA256 或已下载的单文件校验文件时校验哈希，否则仅比较大小
+func (d *Downloader) PlanRepo(ctx context.Context, ref RepoRef, all bool, opts RepoOptions) ([]*ReleasePlan, error) {
+    releases, err := d.FetchReleases(ctx, ref, all)
+    if err != nil {
+        return nil, err
+    }
+
+    plans := make([]*ReleasePlan, 0, len(releases))
+    for _, release := range releases {
+        if release.TagName == "" {
+            continue
+        }
+        dir := d.versionDir(ref.Repo, release.TagName)
+        plan := &ReleasePlan{Repo: ref, Tag: release.TagName, Dir: dir}
+        if _, err := os.Stat(dir); os.IsNotExist(err) {
+            plan.NewVersion = true
+        }
+
+        selected := opts.Filter.Select(release.Assets)
+        plan.Excluded = len(release.Assets) - len(selected)
+        sidecars := pairSidecars(selected)
+        for _, asset := range selected {
+            ap := AssetPlan{Name: asset.Name, Size: asset.Size, Action: PlanDownload}
+            if !plan.NewVersion {
+                expected := officialDigest(asset)
+                if sidecar, ok := sidecars[asset.Name]; ok && expected.IsZero() {
+                    // 使用已下载的单文件校验文件
+                    expected, _ = readSidecar(filepath.Join(dir, sidecar), asset.Name)
+                }
+                state, reason := checkLocalFile(filepath.Join(dir, asset.Name), asset.Size, expected)
+                switch state {
+                case localComplete:
+                    ap.Action = PlanSkip
+                    ap.Reason = reason
+                case localMismatch:
+                    ap.Action = PlanReplace
+                    ap.Reason = reason
+                }
+            }
+            plan.Assets = append(plan.Assets, ap)
+        }
+        plans = append(plans, plan)
+    }
+    return plans, nil
+}
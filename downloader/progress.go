@@ -0,0 +1,341 @@
+package downloader
+
+import (
+    "fmt"
+    "io"
+    "math"
+    "os"
+    "strings"
+    "sync"
+    "sync/atomic"
+    "time"
+
+    "github.com/rivo/uniseg"
+    "golang.org/x/term"
+
+    "github-downloader/logger"
+)
+
+const (
+    progressRedraw    = 200 * time.Millisecond // 终端中刷新进度的间隔
+    progressReport    = 10 * time.Second       // 输出不是终端时打印文字进度的间隔
+    progressNameWidth = 32                     // 终端中文件名占用的宽度
+    progressBarWidth  = 20
+)
+
+// progressDisplay 显示所有正在进行的下载
+// 输出是终端时，每个下载占一行，同时下载多个文件时最后一行为总体进度，日志输出在进度上方；
+// 输出不是终端时（cron、CI 等）定期打印文字进度
+// 总体进度从上一次所有下载都结束之后开始统计
+type progressDisplay struct {
+    mu        sync.Mutex
+    out       io.Writer
+    fd        int  // out 为终端时的文件描述符
+    tty       bool
+    transfers []*transfer // 按开始的先后排列
+    lines     int         // 终端中当前显示的进度行数
+    count     int         // 本轮开始的下载数
+    doneBytes int64       // 本轮已结束的下载的字节数
+    totalSize int64       // 本轮已知大小的下载的总大小
+    unknown   bool        // 本轮有大小未知的下载
+    rate      float64     // 平滑后的总速度（字节/秒）
+    lastBytes int64
+    lastTime  time.Time
+    stop      chan struct{}
+}
+
+// transfer 是一个正在进行的下载，写入的字节数计入进度
+type transfer struct {
+    p       *progressDisplay
+    name    string
+    size    int64 // 0 表示大小未知
+    offset  int64 // 断点续传时已有的字节数，不计入速度
+    start   time.Time
+    written atomic.Int64
+}
+
+// display 返回下载器的进度显示；第一次调用时接管日志的控制台输出，使日志显示在进度上方
+func (d *Downloader) display() *progressDisplay {
+    d.progressOnce.Do(func() {
+        d.progress = newProgressDisplay(logger.Console())
+        logger.SetConsole(d.progress)
+    })
+    return d.progress
+}
+
+func newProgressDisplay(w io.Writer) *progressDisplay {
+    p := &progressDisplay{out: w}
+    if f, ok := w.(*os.File); ok && term.IsTerminal(int(f.Fd())) && os.Getenv("TERM") != "dumb" {
+        p.fd = int(f.Fd())
+        p.tty = enableVirtualTerminal(f)
+    }
+    return p
+}
+
+// Write 输出一条日志：先清除终端中的进度行，日志之后重新显示进度
+func (p *progressDisplay) Write(b []byte) (int, error) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    if p.lines == 0 {
+        return p.out.Write(b)
+    }
+    var buf strings.Builder
+    p.erase(&buf)
+    buf.Write(b)
+    p.render(&buf)
+    if _, err := io.WriteString(p.out, buf.String()); err != nil {
+        return 0, err
+    }
+    return len(b), nil
+}
+
+// start 开始显示一个下载，size 为 0 表示大小未知，offset 为断点续传时已有的字节数
+func (p *progressDisplay) start(name string, size, offset int64) *transfer {
+    t := &transfer{p: p, name: name, size: size, offset: offset, start: time.Now()}
+    t.written.Store(offset)
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    if len(p.transfers) == 0 {
+        // 开始新的一轮
+        p.count, p.doneBytes, p.totalSize, p.unknown = 0, 0, 0, false
+        p.rate, p.lastBytes, p.lastTime = 0, 0, t.start
+        p.stop = make(chan struct{})
+        go p.run(p.stop)
+    }
+    p.transfers = append(p.transfers, t)
+    p.count++
+    p.lastBytes += offset // 已有的部分不计入速度
+    if size > 0 {
+        p.totalSize += size
+    } else {
+        p.unknown = true
+    }
+    p.redraw()
+    return t
+}
+
+func (t *transfer) Write(b []byte) (int, error) {
+    t.written.Add(int64(len(b)))
+    return len(b), nil
+}
+
+// finish 结束下载；ok 为 false 时（下载失败，稍后可能重试）该下载不计入总体进度
+func (t *transfer) finish(ok bool) {
+    p := t.p
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    for i, x := range p.transfers {
+        if x == t {
+            p.transfers = append(p.transfers[:i], p.transfers[i+1:]...)
+            break
+        }
+    }
+    if ok {
+        p.doneBytes += t.written.Load()
+    } else {
+        p.count--
+        p.totalSize -= t.size
+    }
+    if len(p.transfers) == 0 {
+        close(p.stop)
+    }
+    p.redraw()
+}
+
+// run 定期刷新进度，直到 stop 被关闭
+func (p *progressDisplay) run(stop chan struct{}) {
+    interval := progressReport
+    if p.tty {
+        interval = progressRedraw
+    }
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+    for {
+        select {
+        case <-stop:
+            return
+        case <-ticker.C:
+        }
+        p.mu.Lock()
+        if len(p.transfers) > 0 {
+            p.sample()
+            if p.tty {
+                p.redraw()
+            } else {
+                p.report()
+            }
+        }
+        p.mu.Unlock()
+    }
+}
+
+// written 返回本轮已下载的字节数；调用方需持有锁
+func (p *progressDisplay) written() int64 {
+    n := p.doneBytes
+    for _, t := range p.transfers {
+        n += t.written.Load()
+    }
+    return n
+}
+
+// sample 更新总速度，按约 3 秒的时间常数平滑；调用方需持有锁
+func (p *progressDisplay) sample() {
+    now, n := time.Now(), p.written()
+    dt := now.Sub(p.lastTime).Seconds()
+    if dt <= 0 {
+        return
+    }
+    current := float64(n-p.lastBytes) / dt
+    if current < 0 {
+        // 失败的下载不再计入，重新开始统计
+        current = 0
+    }
+    if p.rate == 0 {
+        p.rate = current
+    } else {
+        alpha := 1 - math.Exp(-dt/3)
+        p.rate += alpha * (current - p.rate)
+    }
+    p.lastBytes, p.lastTime = n, now
+}
+
+// redraw 在终端中重新显示进度；调用方需持有锁
+func (p *progressDisplay) redraw() {
+    if !p.tty {
+        return
+    }
+    var buf strings.Builder
+    p.erase(&buf)
+    p.render(&buf)
+    if buf.Len() > 0 {
+        io.WriteString(p.out, buf.String())
+    }
+}
+
+// erase 清除终端中显示的进度行，光标回到第一行行首
+func (p *progressDisplay) erase(buf *strings.Builder) {
+    if p.lines > 0 {
+        fmt.Fprintf(buf, "\x1b[%dA\x1b[J", p.lines)
+        p.lines = 0
+    }
+}
+
+// render 输出每个下载的进度行以及总体进度行
+func (p *progressDisplay) render(buf *strings.Builder) {
+    if !p.tty || len(p.transfers) == 0 {
+        return
+    }
+    width := 80
+    if w, _, err := term.GetSize(p.fd); err == nil && w > 0 {
+        width = w
+    }
+    now := time.Now()
+    for _, t := range p.transfers {
+        n := t.written.Load()
+        line := fitWidth(t.name, progressNameWidth) + " " + progressLine(n, t.size, speed(n-t.offset, now.Sub(t.start)))
+        buf.WriteString(truncateWidth(line, width-1) + "\n")
+        p.lines++
+    }
+    if p.count > 1 {
+        line := fitWidth(fmt.Sprintf("总计（%d 个下载中）", len(p.transfers)), progressNameWidth) + " " + p.overall()
+        buf.WriteString(truncateWidth(line, width-1) + "\n")
+        p.lines++
+    }
+}
+
+// overall 返回总体进度：进度条、字节数、速度和预计剩余时间
+func (p *progressDisplay) overall() string {
+    n, size := p.written(), p.totalSize
+    if p.unknown {
+        size = 0
+    }
+    s := progressLine(n, size, p.rate)
+    if size > 0 && p.rate > 0 && n < size {
+        eta := time.Duration(float64(size-n)/p.rate) * time.Second
+        s += "  剩余 " + eta.Round(time.Second).String()
+    }
+    return s
+}
+
+// report 打印一行总体进度和每个下载的进度，用于输出不是终端的情况；调用方需持有锁
+func (p *progressDisplay) report() {
+    now := time.Now()
+    var buf strings.Builder
+    prefix := now.Format("2006/01/02 15:04:05") + " [进度] "
+    fmt.Fprintf(&buf, "%s%d 个下载中，%s\n", prefix, len(p.transfers), p.overall())
+    for _, t := range p.transfers {
+        n := t.written.Load()
+        fmt.Fprintf(&buf, "%s  %s: %s\n", prefix, t.name, progressLine(n, t.size, speed(n-t.offset, now.Sub(t.start))))
+    }
+    io.WriteString(p.out, buf.String())
+}
+
+// progressLine 格式化进度：大小已知时为进度条、百分比和字节数，未知时只有字节数；最后是速度
+func progressLine(n, size int64, rate float64) string {
+    rateText := ByteCountIEC(int64(rate)) + "/s"
+    if size <= 0 {
+        return fmt.Sprintf("%s  %s", ByteCountIEC(n), rateText)
+    }
+    ratio := math.Min(float64(n)/float64(size), 1)
+    filled := int(ratio * progressBarWidth)
+    bar := strings.Repeat("=", filled)
+    if filled < progressBarWidth {
+        bar += ">" + strings.Repeat(" ", progressBarWidth-filled-1)
+    }
+    return fmt.Sprintf("[%s] %3.0f%%  %s/%s  %s", bar, ratio*100, ByteCountIEC(n), ByteCountIEC(size), rateText)
+}
+
+func speed(n int64, elapsed time.Duration) float64 {
+    if elapsed <= 0 {
+        return 0
+    }
+    return float64(n) / elapsed.Seconds()
+}
+
+// fitWidth 将 s 调整为占用 width 个字符宽度：较短时补空格，较长时省略中间部分（保留平台和扩展名）
+func fitWidth(s string, width int) string {
+    w := uniseg.StringWidth(s)
+    if w <= width {
+        return s + strings.Repeat(" ", width-w)
+    }
+    runes := []rune(s)
+    head, tail := 0, len(runes)
+    used := 1 // 省略号
+    for used < width {
+        // 交替从两端取字符，尾部多取一些
+        if tail-1 > head && (len(runes)-tail) <= 2*head {
+            if cw := uniseg.StringWidth(string(runes[tail-1])); used+cw <= width {
+                tail--
+                used += cw
+                continue
+            }
+        }
+        if head < tail-1 {
+            if cw := uniseg.StringWidth(string(runes[head])); used+cw <= width {
+                head++
+                used += cw
+                continue
+            }
+        }
+        break
+    }
+    return string(runes[:head]) + "…" + string(runes[tail:]) + strings.Repeat(" ", width-used)
+}
+
+// truncateWidth 截断 s 使其不超过 width 个字符宽度，避免终端自动换行打乱进度行
+func truncateWidth(s string, width int) string {
+    if uniseg.StringWidth(s) <= width {
+        return s
+    }
+    var b strings.Builder
+    used := 0
+    for _, r := range s {
+        cw := uniseg.StringWidth(string(r))
+        if used+cw > width {
+            break
+        }
+        b.WriteRune(r)
+        used += cw
+    }
+    return b.String()
+}
@@ -0,0 +1,52 @@
+package downloader
+
+import (
+    "fmt"
+    "net/http"
+    "strings"
+    "time"
+)
+
+// proxyProbeURL 用于测试代理可用性的小文件
+const proxyProbeURL = "https://github.com/git/git/raw/master/README.md"
+
+// Proxies 返回下载器实际会使用的全局代理列表
+func (d *Downloader) Proxies() []string {
+    if len(d.proxies) == 0 {
+        return []string{defaultProxy}
+    }
+    return d.proxies
+}
+
+// SetProxies 替换全局代理列表，不能与下载任务同时调用
+func (d *Downloader) SetProxies(proxies []string) {
+    d.proxies = proxies
+}
+
+// ProbeProxy 通过代理请求一个小文件，返回响应耗时
+func (d *Downloader) ProbeProxy(proxy string) (time.Duration, error) {
+    req, err := http.NewRequest("HEAD", buildProxyURL(proxyProbeURL, proxy), nil)
+    if err != nil {
+        return 0, err
+    }
+    req.Header.Set("User-Agent", d.userAgent)
+
+    client := &http.Client{Timeout: 15 * time.Second}
+    start := time.Now()
+    resp, err := client.Do(req)
+    if err != nil {
+        return 0, err
+    }
+    resp.Body.Close()
+    elapsed := time.Since(start)
+
+    if resp.StatusCode >= 400 {
+        return elapsed, fmt.Errorf("HTTP 错误: %s", resp.Status)
+    }
+    return elapsed, nil
+}
+
+// buildProxyURL 将 GitHub 链接改写为经过加速代理的链接
+func buildProxyURL(url, proxy string) string {
+    return strings.Replace(url, "https://github.com", fmt.Sprintf("https://%s/github.com", proxy), 1)
+}
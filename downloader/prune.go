@@ -0,0 +1,214 @@
+package downloader
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+    "regexp"
+    "sort"
+    "strings"
+    "time"
+
+    "github-downloader/logger"
+)
+
+// Retention 是版本保留策略，版本满足任一规则即保留；最新版本总是保留
+// 所有规则都为空时不清理任何版本
+type Retention struct {
+    Last  int      // 保留最新的 N 个版本
+    Days  int      // 保留 D 天内发布的版本
+    Major bool     // 保留每个主版本号的最新版本
+    Pin   []string // 总是保留的版本，支持通配符，例如 v1.*
+}
+
+// IsZero 判断是否没有任何保留规则
+func (r Retention) IsZero() bool {
+    return r.Last <= 0 && r.Days <= 0 && !r.Major && len(r.Pin) == 0
+}
+
+// String 返回保留策略的简短描述
+func (r Retention) String() string {
+    var rules []string
+    if r.Last > 0 {
+        rules = append(rules, fmt.Sprintf("最新 %d 个", r.Last))
+    }
+    if r.Days > 0 {
+        rules = append(rules, fmt.Sprintf("%d 天内", r.Days))
+    }
+    if r.Major {
+        rules = append(rules, "每个主版本的最新版本")
+    }
+    if len(r.Pin) > 0 {
+        rules = append(rules, "固定 "+strings.Join(r.Pin, ","))
+    }
+    if len(rules) == 0 {
+        return "无"
+    }
+    return strings.Join(rules, "，")
+}
+
+// PruneVersion 是一个版本目录的清理计划
+type PruneVersion struct {
+    Tag       string    `json:"tag"`
+    Dir       string    `json:"dir"`
+    Published time.Time `json:"published_at"` // 来自 manifest.json，没有时为目录的修改时间
+    Size      int64     `json:"size"`
+    Keep      bool      `json:"keep"`
+    Reason    string    `json:"reason,omitempty"` // 保留的原因
+}
+
+// PrunePlan 是一个仓库目录的清理计划，版本按发布时间从新到旧排列
+type PrunePlan struct {
+    Repo      string         `json:"repo"`
+    Retention Retention      `json:"-"`
+    Versions  []PruneVersion `json:"versions"`
+}
+
+// Removed 返回计划删除的版本
+func (p *PrunePlan) Removed() []PruneVersion {
+    var removed []PruneVersion
+    for _, v := range p.Versions {
+        if !v.Keep {
+            removed = append(removed, v)
+        }
+    }
+    return removed
+}
+
+// PruneResult 是执行清理的结果
+type PruneResult struct {
+    Removed int   `json:"removed"`      // 删除的版本数
+    Freed   int64 `json:"freed_bytes"`  // 释放的空间
+    Shared  int64 `json:"shared_bytes"` // 与内容寻址存储共享的文件大小，不再被引用的内容由 dedupe 清理
+    Errors  int   `json:"errors"`
+}
+
+// majorPattern 匹配版本号中的第一组数字，作为主版本号
+var majorPattern = regexp.MustCompile(`\d+`)
+
+// PlanPrune 按保留策略生成仓库目录（下载目录下的 repo）的清理计划，不修改任何文件
+func (d *Downloader) PlanPrune(repo string, r Retention, now time.Time) (*PrunePlan, error) {
+    plan := &PrunePlan{Repo: repo, Retention: r}
+    repoDir := filepath.Join(d.topDir, repo)
+    entries, err := os.ReadDir(repoDir)
+    if err != nil {
+        return nil, err
+    }
+    for _, entry := range entries {
+        dir := filepath.Join(repoDir, entry.Name())
+        if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || !fileExists(filepath.Join(dir, releaseNotesFile)) {
+            continue
+        }
+        v := PruneVersion{Tag: entry.Name(), Dir: dir, Size: dirSize(dir)}
+        if m, err := ReadManifest(dir); err == nil && !m.PublishedAt.IsZero() {
+            v.Published = m.PublishedAt
+        } else if info, err := entry.Info(); err == nil {
+            v.Published = info.ModTime()
+        }
+        plan.Versions = append(plan.Versions, v)
+    }
+    sort.SliceStable(plan.Versions, func(i, j int) bool {
+        a, b := plan.Versions[i], plan.Versions[j]
+        if !a.Published.Equal(b.Published) {
+            return a.Published.After(b.Published)
+        }
+        return a.Tag > b.Tag
+    })
+
+    if r.IsZero() {
+        for i := range plan.Versions {
+            plan.Versions[i].Keep, plan.Versions[i].Reason = true, "未配置保留策略"
+        }
+        return plan, nil
+    }
+
+    latest := ReadLatest(repoDir)
+    majors := make(map[string]bool)
+    for i := range plan.Versions {
+        v := &plan.Versions[i]
+        major := majorPattern.FindString(v.Tag)
+        switch {
+        case i == 0:
+            v.Reason = "最新版本"
+        case v.Tag == latest:
+            v.Reason = LatestLink + " 指向的版本"
+        case matchAny(r.Pin, strings.ToLower(v.Tag)):
+            v.Reason = "固定版本"
+        case i < r.Last:
+            v.Reason = fmt.Sprintf("最新 %d 个版本", r.Last)
+        case r.Days > 0 && now.Sub(v.Published) < time.Duration(r.Days)*24*time.Hour:
+            v.Reason = fmt.Sprintf("%d 天内发布", r.Days)
+        case r.Major && major != "" && !majors[major]:
+            v.Reason = fmt.Sprintf("主版本 %s 的最新版本", major)
+        }
+        v.Keep = v.Reason != ""
+        if major != "" {
+            // 主版本号的最新版本已经因其他规则保留时，不再额外保留更旧的版本
+            majors[major] = true
+        }
+    }
+    return plan, nil
+}
+
+// dirSize 返回目录中文件的总大小（不跟随符号链接）
+func dirSize(dir string) int64 {
+    var size int64
+    filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+        if err == nil && info.Mode().IsRegular() {
+            size += info.Size()
+        }
+        return nil
+    })
+    return size
+}
+
+// Prune 删除计划中不保留的版本目录，并从状态库中删除它们的记录
+// 与内容寻址存储共享的文件只删除链接，存储中的文件由 dedupe 清理
+func (d *Downloader) Prune(plan *PrunePlan, dryRun bool) PruneResult {
+    var res PruneResult
+    for _, v := range plan.Removed() {
+        freed, shared := d.versionUsage(v.Dir)
+        if !dryRun {
+            m, _ := ReadManifest(v.Dir)
+            if err := os.RemoveAll(v.Dir); err != nil {
+                logger.Warn("删除 %s 失败: %v", v.Dir, err)
+                res.Errors++
+                continue
+            }
+            d.state.forgetDir(v.Dir)
+            if m != nil {
+                d.state.forgetRelease(m.Ref(), v.Tag)
+            }
+            logger.Info("已删除旧版本: %s/%s（%s）", plan.Repo, v.Tag, ByteCountIEC(freed))
+        }
+        res.Removed++
+        res.Freed += freed
+        res.Shared += shared
+    }
+    if !dryRun && res.Removed > 0 {
+        if err := d.state.Save(); err != nil {
+            logger.Warn("无法保存状态库: %v", err)
+        }
+    }
+    return res
+}
+
+// versionUsage 返回删除版本目录后释放的空间，以及与内容寻址存储共享（删除后不会释放）的空间
+func (d *Downloader) versionUsage(dir string) (int64, int64) {
+    m, _ := ReadManifest(dir)
+    var freed, shared int64
+    filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+        if err != nil || !info.Mode().IsRegular() {
+            return nil
+        }
+        if ma := m.Asset(info.Name()); ma != nil && ma.SHA256 != "" {
+            if bi, err := os.Stat(blobPath(d.topDir, ma.SHA256)); err == nil && os.SameFile(info, bi) {
+                shared += info.Size()
+                return nil
+            }
+        }
+        freed += info.Size()
+        return nil
+    })
+    return freed, shared
+}
@@ -0,0 +1,104 @@
+package downloader
+
+import (
+    "path/filepath"
+    "reflect"
+    "testing"
+    "time"
+)
+
+// newPruneRepo 在 top/tool 下创建版本目录，发布时间为 now 之前的天数
+func newPruneRepo(t *testing.T, now time.Time, ages map[string]int) string {
+    t.Helper()
+    top := t.TempDir()
+    for tag, days := range ages {
+        dir := filepath.Join(top, "tool", tag)
+        writeFile(t, filepath.Join(dir, releaseNotesFile), "notes")
+        writeFile(t, filepath.Join(dir, "tool.tar.gz"), "hello")
+        m := &Manifest{Version: manifestVersion, Owner: "acme", Repo: "tool", Tag: tag, PublishedAt: now.AddDate(0, 0, -days)}
+        if err := writeManifest(dir, m); err != nil {
+            t.Fatal(err)
+        }
+    }
+    return top
+}
+
+func keptTags(plan *PrunePlan) []string {
+    var tags []string
+    for _, v := range plan.Versions {
+        if v.Keep {
+            tags = append(tags, v.Tag)
+        }
+    }
+    return tags
+}
+
+func TestPlanPruneRetention(t *testing.T) {
+    now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+    top := newPruneRepo(t, now, map[string]int{
+        "v3.1.0": 1,
+        "v3.0.0": 10,
+        "v2.2.0": 40,
+        "v2.1.0": 50,
+        "v1.5.0": 200,
+        "v1.4.0": 300,
+    })
+    d := NewDownloader(top, nil)
+
+    tests := []struct {
+        name string
+        r    Retention
+        kept []string
+    }{
+        {"未配置保留策略", Retention{}, []string{"v3.1.0", "v3.0.0", "v2.2.0", "v2.1.0", "v1.5.0", "v1.4.0"}},
+        {"keep-last", Retention{Last: 2}, []string{"v3.1.0", "v3.0.0"}},
+        {"keep-days", Retention{Days: 45}, []string{"v3.1.0", "v3.0.0", "v2.2.0"}},
+        {"keep-major", Retention{Major: true}, []string{"v3.1.0", "v2.2.0", "v1.5.0"}},
+        {"keep-last 和 keep-major", Retention{Last: 2, Major: true}, []string{"v3.1.0", "v3.0.0", "v2.2.0", "v1.5.0"}},
+        // v2.2.0 因 keep-days 保留后，主版本 2 不再额外保留 v2.1.0
+        {"keep-days 和 keep-major", Retention{Days: 45, Major: true}, []string{"v3.1.0", "v3.0.0", "v2.2.0", "v1.5.0"}},
+        {"keep-last 和 keep-days", Retention{Last: 1, Days: 15}, []string{"v3.1.0", "v3.0.0"}},
+        {"三者同时使用", Retention{Last: 3, Days: 45, Major: true}, []string{"v3.1.0", "v3.0.0", "v2.2.0", "v1.5.0"}},
+        {"pin", Retention{Last: 1, Pin: []string{"v1.4.*"}}, []string{"v3.1.0", "v1.4.0"}},
+        // 最新版本总是保留
+        {"pin 没有匹配的版本", Retention{Pin: []string{"v9.*"}}, []string{"v3.1.0"}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            plan, err := d.PlanPrune("tool", tt.r, now)
+            if err != nil {
+                t.Fatal(err)
+            }
+            if got := keptTags(plan); !reflect.DeepEqual(got, tt.kept) {
+                t.Errorf("保留 %v, want %v", got, tt.kept)
+            }
+            if len(plan.Removed())+len(tt.kept) != len(plan.Versions) {
+                t.Errorf("Removed() = %d 个版本", len(plan.Removed()))
+            }
+        })
+    }
+}
+
+func TestPlanPruneReasons(t *testing.T) {
+    now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+    top := newPruneRepo(t, now, map[string]int{"v2.0.0": 1, "v1.1.0": 5, "v1.0.0": 100, "v0.9.0": 200})
+    // latest 指针指向的版本即使不满足规则也保留
+    writeFile(t, filepath.Join(top, "tool", LatestFile), "v0.9.0\n")
+    d := NewDownloader(top, nil)
+
+    plan, err := d.PlanPrune("tool", Retention{Days: 30, Major: true}, now)
+    if err != nil {
+        t.Fatal(err)
+    }
+    want := map[string]string{
+        "v2.0.0": "最新版本",
+        "v1.1.0": "30 天内发布",
+        "v1.0.0": "",
+        "v0.9.0": LatestLink + " 指向的版本",
+    }
+    for _, v := range plan.Versions {
+        if v.Reason != want[v.Tag] || v.Keep != (want[v.Tag] != "") {
+            t.Errorf("%s: Keep = %v, Reason = %q, want %q", v.Tag, v.Keep, v.Reason, want[v.Tag])
+        }
+    }
+}
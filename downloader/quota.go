@@ -0,0 +1,220 @@
+package downloader
+
+import (
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+
+    "github-downloader/logger"
+)
+
+// ErrDiskFull 表示磁盘可用空间或下载目录配额不足
+var ErrDiskFull = errors.New("空间不足")
+
+// diskQuota 是下载前的空间检查设置和已预留的空间
+type diskQuota struct {
+    mu      sync.Mutex
+    limit   int64 // 下载目录总大小上限，0 表示不限制
+    reserve int64 // 磁盘至少保留的可用空间
+    used    int64 // 下载目录已用空间，首次检查配额时统计
+    scanned bool
+    pending int64 // 正在下载的版本预留的空间
+
+    // active 是本次运行中排队等待或正在下载的版本目录（按引用计数），腾出空间时不会被删除
+    active map[string]int
+
+    // evict 返回仓库目录的保留策略，空间不足时用于腾出空间；为 nil 时拒绝下载
+    evict func(repo string) Retention
+}
+
+// SetQuota 设置下载目录的总大小上限 limit 和磁盘至少保留的可用空间 reserve，0 表示不限制
+func (d *Downloader) SetQuota(limit, reserve int64) {
+    d.quota.mu.Lock()
+    defer d.quota.mu.Unlock()
+    d.quota.limit, d.quota.reserve = limit, reserve
+}
+
+// SetEviction 设置空间不足时的处理方式：policy 不为 nil 时，按各仓库目录的保留策略
+// 从最旧的版本开始删除不在策略内的版本；为 nil 时拒绝下载
+func (d *Downloader) SetEviction(policy func(repo string) Retention) {
+    d.quota.mu.Lock()
+    defer d.quota.mu.Unlock()
+    d.quota.evict = policy
+}
+
+// spaceNeeded 返回下载 assets 还需要的空间：已存在且大小一致的文件不会重新下载，不计算在内
+func spaceNeeded(versionDir string, assets []Asset) int64 {
+    var need int64
+    for _, a := range assets {
+        if info, err := os.Stat(filepath.Join(versionDir, a.Name)); err == nil && info.Size() == a.Size {
+            continue
+        }
+        need += a.Size
+    }
+    return need
+}
+
+// protect 防止本次运行中将要处理的版本目录在腾出空间时被删除，处理结束后调用返回的函数
+func (q *diskQuota) protect(dirs ...string) func() {
+    q.mu.Lock()
+    defer q.mu.Unlock()
+    q.hold(dirs)
+    return func() {
+        q.mu.Lock()
+        defer q.mu.Unlock()
+        q.unhold(dirs)
+    }
+}
+
+// hold 和 unhold 增减版本目录的引用计数；调用方需持有锁
+func (q *diskQuota) hold(dirs []string) {
+    if q.active == nil {
+        q.active = make(map[string]int)
+    }
+    for _, dir := range dirs {
+        q.active[dir]++
+    }
+}
+
+func (q *diskQuota) unhold(dirs []string) {
+    for _, dir := range dirs {
+        q.active[dir]--
+        if q.active[dir] <= 0 {
+            delete(q.active, dir)
+        }
+    }
+}
+
+// claimSpace 在下载版本前检查磁盘可用空间和配额，并为本次下载预留空间
+// 空间不足时按保留策略删除旧版本腾出空间，仍不足时返回 ErrDiskFull；下载结束后调用返回的函数释放预留，
+// 并按版本目录实际增加的大小（下载失败时可能为 0）更新已用空间
+func (d *Downloader) claimSpace(versionDir string, assets []Asset) (func(), error) {
+    need := spaceNeeded(versionDir, assets)
+    if need == 0 {
+        return func() {}, nil
+    }
+    before := dirSize(versionDir)
+    q := &d.quota
+    q.mu.Lock()
+    defer q.mu.Unlock()
+
+    dirs := []string{versionDir}
+    q.hold(dirs)
+    short, reason := d.shortfall(versionDir, need)
+    if short > 0 && q.evict != nil {
+        logger.Warn("空间不足（%s），按保留策略删除旧版本以腾出 %s", reason, ByteCountIEC(short))
+        d.evictVersions(short)
+        short, reason = d.shortfall(versionDir, need)
+    }
+    if short > 0 {
+        q.unhold(dirs)
+        return nil, fmt.Errorf("%w: %s", ErrDiskFull, reason)
+    }
+
+    q.pending += need
+    return func() {
+        written := dirSize(versionDir) - before
+        q.mu.Lock()
+        defer q.mu.Unlock()
+        q.pending -= need
+        if written > 0 {
+            q.used += written
+        }
+        q.unhold(dirs)
+    }, nil
+}
+
+// shortfall 返回下载 need 字节还缺少的空间及原因，空间足够时返回 0；调用方需持有锁
+func (d *Downloader) shortfall(dir string, need int64) (int64, string) {
+    q := &d.quota
+    var short int64
+    var reason string
+    if free, err := diskFree(dir); err == nil {
+        if s := need + q.pending + q.reserve - free; s > short {
+            short = s
+            reason = fmt.Sprintf("需要 %s，磁盘可用 %s（保留 %s）", ByteCountIEC(need), ByteCountIEC(free), ByteCountIEC(q.reserve))
+        }
+    } else if !errors.Is(err, errors.ErrUnsupported) {
+        logger.Warn("无法获取磁盘可用空间: %v", err)
+    }
+    if q.limit > 0 {
+        if !q.scanned {
+            q.used, q.scanned = diskUsage(d.topDir), true
+        }
+        if s := q.used + q.pending + need - q.limit; s > short {
+            short = s
+            reason = fmt.Sprintf("需要 %s，下载目录已用 %s，配额 %s", ByteCountIEC(need), ByteCountIEC(q.used+q.pending), ByteCountIEC(q.limit))
+        }
+    }
+    return short, reason
+}
+
+// evictVersions 按各仓库的保留策略删除不在策略内的版本，从发布时间最旧的开始，直到释放 need 字节
+// 本次运行中排队等待或正在下载的版本目录不会被删除；调用方需持有锁
+func (d *Downloader) evictVersions(need int64) {
+    q := &d.quota
+    entries, err := os.ReadDir(d.topDir)
+    if err != nil {
+        logger.Warn("无法读取下载目录: %v", err)
+        return
+    }
+    type candidate struct {
+        repo string
+        v    PruneVersion
+    }
+    var candidates []candidate
+    now := time.Now()
+    for _, entry := range entries {
+        if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+            continue
+        }
+        plan, err := d.PlanPrune(entry.Name(), q.evict(entry.Name()), now)
+        if err != nil {
+            continue
+        }
+        for _, v := range plan.Removed() {
+            if q.active[v.Dir] == 0 {
+                candidates = append(candidates, candidate{entry.Name(), v})
+            }
+        }
+    }
+    sort.Slice(candidates, func(i, j int) bool { return candidates[i].v.Published.Before(candidates[j].v.Published) })
+
+    var freed int64
+    for _, c := range candidates {
+        if freed >= need {
+            break
+        }
+        res := d.Prune(&PrunePlan{Repo: c.repo, Versions: []PruneVersion{c.v}}, false)
+        freed += res.Freed
+        q.used -= res.Freed
+    }
+    if len(candidates) == 0 {
+        logger.Warn("没有可以按保留策略删除的旧版本")
+    }
+}
+
+// diskUsage 返回目录中所有文件占用的空间，硬链接的文件只计算一次
+func diskUsage(root string) int64 {
+    var size int64
+    seen := make(map[[2]uint64]bool)
+    filepath.Walk(root, func(_ string, info os.FileInfo, err error) error {
+        if err != nil || !info.Mode().IsRegular() {
+            return nil
+        }
+        if id, ok := fileID(info); ok {
+            if seen[id] {
+                return nil
+            }
+            seen[id] = true
+        }
+        size += info.Size()
+        return nil
+    })
+    return size
+}
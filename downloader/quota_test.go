@@ -0,0 +1,134 @@
+package downloader
+
+import (
+    "errors"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+)
+
+// newQuotaTree 创建 tool 仓库的三个旧版本，每个版本有一个 1000 字节的资产，v1 最旧
+func newQuotaTree(t *testing.T) (*Downloader, []string) {
+    t.Helper()
+    top := t.TempDir()
+    now := time.Now()
+    var dirs []string
+    for i, tag := range []string{"v1", "v2", "v3"} {
+        dir := filepath.Join(top, "tool", tag)
+        writeFile(t, filepath.Join(dir, releaseNotesFile), "")
+        writeFile(t, filepath.Join(dir, "tool.bin"), strings.Repeat("x", 1000))
+        m := &Manifest{Version: manifestVersion, Owner: "acme", Repo: "tool", Tag: tag, PublishedAt: now.AddDate(0, 0, i-10)}
+        if err := writeManifest(dir, m); err != nil {
+            t.Fatal(err)
+        }
+        dirs = append(dirs, dir)
+    }
+    d := NewDownloader(top, nil)
+    // 只保留最新版本，其他版本都可以删除
+    d.SetEviction(func(string) Retention { return Retention{Last: 1} })
+    return d, dirs
+}
+
+func TestClaimSpaceEvictsOldestFirst(t *testing.T) {
+    d, dirs := newQuotaTree(t)
+    d.SetQuota(diskUsage(d.topDir)+500, 0)
+
+    release, err := d.claimSpace(filepath.Join(d.topDir, "tool", "v4"), []Asset{{Name: "tool.bin", Size: 1000}})
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer release()
+    if dirExists(dirs[0]) {
+        t.Errorf("最旧的版本 v1 应被删除")
+    }
+    if !dirExists(dirs[1]) || !dirExists(dirs[2]) {
+        t.Errorf("腾出足够空间后不应继续删除")
+    }
+}
+
+func TestClaimSpaceSkipsActiveVersions(t *testing.T) {
+    d, dirs := newQuotaTree(t)
+    d.SetQuota(diskUsage(d.topDir)+500, 0)
+
+    // v1 在本次运行中排队等待重新下载（例如 -all），v2 有一个缺失的资产正在下载
+    done := d.quota.protect(dirs[0])
+    releaseV2, err := d.claimSpace(dirs[1], []Asset{{Name: "tool.bin", Size: 1000}, {Name: "tool.zip", Size: 100}})
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer releaseV2()
+    if !dirExists(dirs[0]) {
+        t.Fatalf("排队等待下载的 v1 不应被删除")
+    }
+
+    // v1、v2 受保护，v3 是最新版本，没有可以删除的版本
+    _, err = d.claimSpace(filepath.Join(d.topDir, "tool", "v4"), []Asset{{Name: "tool.bin", Size: 1000}})
+    if !errors.Is(err, ErrDiskFull) {
+        t.Fatalf("claimSpace() = %v, want ErrDiskFull", err)
+    }
+    for _, dir := range dirs {
+        if !dirExists(dir) {
+            t.Errorf("%s 受保护或在保留策略内，不应被删除", dir)
+        }
+    }
+
+    // v1 处理结束后不再受保护
+    done()
+    release, err := d.claimSpace(filepath.Join(d.topDir, "tool", "v4"), []Asset{{Name: "tool.bin", Size: 1000}})
+    if err != nil {
+        t.Fatal(err)
+    }
+    release()
+    if dirExists(dirs[0]) {
+        t.Errorf("v1 不再受保护后应被删除")
+    }
+    if len(d.quota.active) != 1 {
+        t.Errorf("active = %v, want 只有 v2", d.quota.active)
+    }
+}
+
+func TestClaimSpaceCountsWrittenBytes(t *testing.T) {
+    top := t.TempDir()
+    d := NewDownloader(top, nil)
+    d.SetQuota(1<<30, 0)
+    dir := filepath.Join(top, "tool", "v1")
+    assets := []Asset{{Name: "a.bin", Size: 1000}, {Name: "b.bin", Size: 1000}}
+
+    // 下载失败，没有写入任何文件
+    release, err := d.claimSpace(dir, assets)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if d.quota.pending != 2000 {
+        t.Errorf("pending = %d, want 2000", d.quota.pending)
+    }
+    release()
+    if d.quota.used != 0 || d.quota.pending != 0 {
+        t.Errorf("下载失败后 used = %d, pending = %d, want 0, 0", d.quota.used, d.quota.pending)
+    }
+
+    // 只下载完成一个资产
+    release, err = d.claimSpace(dir, assets)
+    if err != nil {
+        t.Fatal(err)
+    }
+    writeFile(t, filepath.Join(dir, "a.bin"), strings.Repeat("x", 1000))
+    release()
+    if d.quota.used != 1000 {
+        t.Errorf("used = %d, want 1000", d.quota.used)
+    }
+
+    // 已存在的资产不需要空间
+    release, err = d.claimSpace(dir, assets[:1])
+    if err != nil || d.quota.pending != 0 {
+        t.Fatalf("claimSpace() = %v, pending = %d", err, d.quota.pending)
+    }
+    release()
+}
+
+func dirExists(path string) bool {
+    info, err := os.Stat(path)
+    return err == nil && info.IsDir()
+}
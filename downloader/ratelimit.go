@@ -0,0 +1,246 @@
+package downloader
+
+import (
+    "context"
+    "fmt"
+    "io"
+    "net/url"
+    "strings"
+    "sync"
+    "time"
+)
+
+// AllHosts 是限制所有下载总速度的规则使用的主机名
+const AllHosts = "*"
+
+// rateChunk 是限速时每次读取的最大字节数，较小的值使速度更平稳
+const rateChunk = 16 * 1024
+
+// RateRule 是一条限速规则
+type RateRule struct {
+    Host     string      `json:"host"`               // 主机名（经过代理时为代理的主机名），AllHosts 表示所有下载的总速度
+    Rate     int64       `json:"rate"`               // 字节/秒，0 表示不限速
+    Window   *TimeWindow `json:"window,omitempty"`   // 生效的时间段，为 nil 时全天生效
+    Override bool        `json:"override,omitempty"` // 运行中通过 SetRateLimit 设置，全天生效且优先于时间段规则
+}
+
+// TimeWindow 是每天的一个时间段，To 小于 From 时跨越午夜
+type TimeWindow struct {
+    From, To int // 从 0 点开始的分钟数
+}
+
+// ParseTimeWindow 解析时间段，例如 00:00-07:00、22:00-06:00
+func ParseTimeWindow(s string) (*TimeWindow, error) {
+    from, to, ok := strings.Cut(s, "-")
+    if !ok {
+        return nil, fmt.Errorf("无效的时间段 %q，例如 00:00-07:00", s)
+    }
+    var w TimeWindow
+    var err error
+    if w.From, err = parseClock(from); err == nil {
+        w.To, err = parseClock(to)
+    }
+    if err != nil {
+        return nil, fmt.Errorf("无效的时间段 %q，例如 00:00-07:00", s)
+    }
+    return &w, nil
+}
+
+func parseClock(s string) (int, error) {
+    t, err := time.Parse("15:04", strings.TrimSpace(s))
+    if err != nil {
+        // 允许 24:00 表示一天结束
+        if strings.TrimSpace(s) == "24:00" {
+            return 24 * 60, nil
+        }
+        return 0, err
+    }
+    return t.Hour()*60 + t.Minute(), nil
+}
+
+// Contains 判断 t 的当地时间是否在时间段内；From 与 To 相同时表示全天
+func (w TimeWindow) Contains(t time.Time) bool {
+    m := t.Hour()*60 + t.Minute()
+    switch {
+    case w.From == w.To:
+        return true
+    case w.From < w.To:
+        return m >= w.From && m < w.To
+    default:
+        return m >= w.From || m < w.To
+    }
+}
+
+func (w TimeWindow) String() string {
+    return fmt.Sprintf("%02d:%02d-%02d:%02d", w.From/60, w.From%60, w.To/60, w.To%60)
+}
+
+// MarshalText 使时间段在 JSON 中显示为 00:00-07:00
+func (w TimeWindow) MarshalText() ([]byte, error) {
+    return []byte(w.String()), nil
+}
+
+// rateLimiter 是所有下载共享的令牌桶，分别限制总速度和每个主机的速度
+type rateLimiter struct {
+    mu      sync.Mutex
+    rules   []RateRule
+    buckets map[string]*bucket
+}
+
+// bucket 是一个令牌桶，最多积累 1 秒的令牌；令牌可以透支，透支的部分由等待补足
+type bucket struct {
+    tokens float64
+    last   time.Time
+}
+
+// SetRateLimits 替换全部限速规则，可以在下载过程中调用，正在进行的下载立即使用新的速度
+// 同一主机有多条规则时，匹配当前时间段的规则优先于全天规则，靠后的规则覆盖靠前的；
+// 之前通过 SetRateLimit 设置的速度被一并替换
+func (d *Downloader) SetRateLimits(rules []RateRule) {
+    d.limiter.mu.Lock()
+    defer d.limiter.mu.Unlock()
+    d.limiter.rules = append([]RateRule(nil), rules...)
+}
+
+// SetRateLimit 将主机 host（AllHosts 表示总速度）的速度设为 rate，0 表示不限速
+// 替换该主机的全天规则，并优先于时间段规则，直到下一次 SetRateLimits；时间段规则保留在规则列表中
+func (d *Downloader) SetRateLimit(host string, rate int64) {
+    d.limiter.mu.Lock()
+    defer d.limiter.mu.Unlock()
+    var rules []RateRule
+    for _, r := range d.limiter.rules {
+        if r.Window != nil || !strings.EqualFold(r.Host, host) {
+            rules = append(rules, r)
+        }
+    }
+    d.limiter.rules = append(rules, RateRule{Host: host, Rate: rate, Override: true})
+}
+
+// RateLimits 返回全部限速规则，以及每个主机当前生效的速度（不限速的主机不包括在内）
+func (d *Downloader) RateLimits() ([]RateRule, map[string]int64) {
+    d.limiter.mu.Lock()
+    defer d.limiter.mu.Unlock()
+    now := time.Now()
+    current := make(map[string]int64)
+    for _, r := range d.limiter.rules {
+        host := strings.ToLower(r.Host)
+        if rate := d.limiter.rate(host, now); rate > 0 {
+            current[host] = rate
+        }
+    }
+    return append([]RateRule{}, d.limiter.rules...), current
+}
+
+// rate 返回主机 host 在 now 时的速度，0 表示不限速；调用方需持有锁
+// 优先级：运行中设置的速度 > 匹配当前时间段的规则 > 全天规则，同一级别靠后的规则覆盖靠前的
+func (l *rateLimiter) rate(host string, now time.Time) int64 {
+    var def, scheduled, override int64
+    inWindow, overridden := false, false
+    for _, r := range l.rules {
+        if !strings.EqualFold(r.Host, host) {
+            continue
+        }
+        if r.Override {
+            override, overridden = r.Rate, true
+        } else if r.Window == nil {
+            def = r.Rate
+        } else if r.Window.Contains(now) {
+            scheduled, inWindow = r.Rate, true
+        }
+    }
+    if overridden {
+        return override
+    }
+    if inWindow {
+        return scheduled
+    }
+    return def
+}
+
+// wait 从总速度和主机 host 的令牌桶中取出 n 字节，返回需要等待的时间
+func (l *rateLimiter) wait(host string, n int) time.Duration {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    if len(l.rules) == 0 {
+        return 0
+    }
+    now := time.Now()
+    var longest time.Duration
+    for _, key := range []string{AllHosts, strings.ToLower(host)} {
+        rate := l.rate(key, now)
+        b := l.buckets[key]
+        if rate <= 0 {
+            if b != nil {
+                delete(l.buckets, key)
+            }
+            continue
+        }
+        if b == nil {
+            if l.buckets == nil {
+                l.buckets = make(map[string]*bucket)
+            }
+            b = &bucket{tokens: float64(rate), last: now}
+            l.buckets[key] = b
+        }
+        if d := b.take(n, rate, now); d > longest {
+            longest = d
+        }
+    }
+    return longest
+}
+
+func (b *bucket) take(n int, rate int64, now time.Time) time.Duration {
+    b.tokens += now.Sub(b.last).Seconds() * float64(rate)
+    if burst := float64(rate); b.tokens > burst {
+        b.tokens = burst
+    }
+    b.last = now
+    b.tokens -= float64(n)
+    if b.tokens >= 0 {
+        return 0
+    }
+    return time.Duration(-b.tokens / float64(rate) * float64(time.Second))
+}
+
+// limitedReader 读取响应体时按总速度和主机的速度等待，ctx 被取消时停止等待
+type limitedReader struct {
+    ctx  context.Context
+    r    io.Reader
+    l    *rateLimiter
+    host string
+}
+
+// reader 返回按限速规则读取 r 的 Reader，rawURL 为实际请求的地址
+func (l *rateLimiter) reader(ctx context.Context, r io.Reader, rawURL string) io.Reader {
+    u, err := url.Parse(rawURL)
+    if err != nil {
+        return r
+    }
+    return &limitedReader{ctx: ctx, r: r, l: l, host: u.Hostname()}
+}
+
+func (lr *limitedReader) Read(p []byte) (int, error) {
+    if len(p) > rateChunk {
+        p = p[:rateChunk]
+    }
+    n, err := lr.r.Read(p)
+    if n > 0 && err == nil {
+        err = sleep(lr.ctx, lr.l.wait(lr.host, n))
+    }
+    return n, err
+}
+
+// sleep 等待 d，ctx 被取消时提前返回 ctx 的错误
+func sleep(ctx context.Context, d time.Duration) error {
+    if d <= 0 {
+        return ctx.Err()
+    }
+    t := time.NewTimer(d)
+    defer t.Stop()
+    select {
+    case <-t.C:
+        return nil
+    case <-ctx.Done():
+        return ctx.Err()
+    }
+}
@@ -0,0 +1,162 @@
+package downloader
+
+import (
+    "testing"
+    "time"
+)
+
+func TestParseTimeWindow(t *testing.T) {
+    tests := []struct {
+        in      string
+        want    TimeWindow
+        wantErr bool
+    }{
+        {"00:00-07:00", TimeWindow{0, 7 * 60}, false},
+        {"22:00-06:00", TimeWindow{22 * 60, 6 * 60}, false},
+        {"09:30 - 18:15", TimeWindow{9*60 + 30, 18*60 + 15}, false},
+        {"18:00-24:00", TimeWindow{18 * 60, 24 * 60}, false},
+        {"7:00-8:00", TimeWindow{7 * 60, 8 * 60}, false},
+        {"00:00", TimeWindow{}, true},
+        {"25:00-06:00", TimeWindow{}, true},
+        {"22:60-06:00", TimeWindow{}, true},
+        {"22-06", TimeWindow{}, true},
+        {"", TimeWindow{}, true},
+    }
+    for _, tt := range tests {
+        got, err := ParseTimeWindow(tt.in)
+        if (err != nil) != tt.wantErr || (err == nil && *got != tt.want) {
+            t.Errorf("ParseTimeWindow(%q) = %v, %v, want %v, wantErr %v", tt.in, got, err, tt.want, tt.wantErr)
+        }
+    }
+}
+
+// at 返回当天 hh:mm 的当地时间
+func at(hh, mm int) time.Time {
+    return time.Date(2024, 1, 1, hh, mm, 0, 0, time.Local)
+}
+
+func TestTimeWindowContains(t *testing.T) {
+    tests := []struct {
+        window string
+        now    time.Time
+        want   bool
+    }{
+        {"00:00-07:00", at(0, 0), true},
+        {"00:00-07:00", at(6, 59), true},
+        {"00:00-07:00", at(7, 0), false},
+        {"00:00-07:00", at(23, 59), false},
+        {"09:00-18:00", at(8, 59), false},
+        {"09:00-18:00", at(12, 0), true},
+        // 跨越午夜
+        {"22:00-06:00", at(21, 59), false},
+        {"22:00-06:00", at(22, 0), true},
+        {"22:00-06:00", at(23, 59), true},
+        {"22:00-06:00", at(0, 0), true},
+        {"22:00-06:00", at(5, 59), true},
+        {"22:00-06:00", at(6, 0), false},
+        {"22:00-06:00", at(12, 0), false},
+        // 24:00 表示一天结束
+        {"18:00-24:00", at(23, 59), true},
+        {"18:00-24:00", at(0, 0), false},
+        // 起止相同表示全天
+        {"08:00-08:00", at(3, 0), true},
+        {"00:00-24:00", at(23, 59), true},
+    }
+    for _, tt := range tests {
+        w, err := ParseTimeWindow(tt.window)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if got := w.Contains(tt.now); got != tt.want {
+            t.Errorf("%s Contains(%s) = %v, want %v", tt.window, tt.now.Format("15:04"), got, tt.want)
+        }
+    }
+}
+
+func TestRateRulePrecedence(t *testing.T) {
+    night := &TimeWindow{22 * 60, 6 * 60}
+    work := &TimeWindow{9 * 60, 18 * 60}
+    rules := []RateRule{
+        {Host: AllHosts, Rate: 5 << 20},
+        {Host: AllHosts, Rate: 0, Window: night},
+        {Host: "github.com", Rate: 2 << 20},
+        {Host: "github.com", Rate: 1 << 20, Window: work},
+        {Host: "github.com", Rate: 512 << 10, Window: &TimeWindow{12 * 60, 13 * 60}},
+        {Host: "gh-proxy.com", Rate: 3 << 20, Window: work},
+    }
+    tests := []struct {
+        name string
+        host string
+        now  time.Time
+        want int64
+    }{
+        {"全天规则", AllHosts, at(12, 0), 5 << 20},
+        {"跨越午夜的时间段优先于全天规则", AllHosts, at(23, 0), 0},
+        {"午夜之后仍在时间段内", AllHosts, at(5, 0), 0},
+        {"主机的全天规则", "github.com", at(8, 0), 2 << 20},
+        {"主机的时间段规则", "github.com", at(10, 0), 1 << 20},
+        {"重叠的时间段中靠后的规则覆盖靠前的", "github.com", at(12, 30), 512 << 10},
+        {"主机名不区分大小写", "GitHub.com", at(10, 0), 1 << 20},
+        {"只有时间段规则的主机在时间段外不限速", "gh-proxy.com", at(20, 0), 0},
+        {"只有时间段规则的主机在时间段内", "gh-proxy.com", at(9, 0), 3 << 20},
+        {"没有规则的主机不限速", "example.com", at(12, 0), 0},
+    }
+    l := &rateLimiter{rules: rules}
+    for _, tt := range tests {
+        if got := l.rate(tt.host, tt.now); got != tt.want {
+            t.Errorf("%s: rate(%s, %s) = %d, want %d", tt.name, tt.host, tt.now.Format("15:04"), got, tt.want)
+        }
+    }
+
+    // 靠后的全天规则覆盖靠前的（-limit-rate 追加在文件规则之后）
+    l = &rateLimiter{rules: append(append([]RateRule{}, rules...), RateRule{Host: AllHosts, Rate: 1 << 20})}
+    if got := l.rate(AllHosts, at(12, 0)); got != 1<<20 {
+        t.Errorf("追加的全天规则: rate = %d, want %d", got, 1<<20)
+    }
+    if got := l.rate(AllHosts, at(23, 0)); got != 0 {
+        t.Errorf("追加的全天规则不影响时间段规则: rate = %d, want 0", got)
+    }
+}
+
+func TestSetRateLimit(t *testing.T) {
+    d := &Downloader{}
+    d.SetRateLimits([]RateRule{
+        {Host: AllHosts, Rate: 5 << 20},
+        {Host: AllHosts, Rate: 0, Window: &TimeWindow{22 * 60, 6 * 60}},
+        {Host: "github.com", Rate: 2 << 20},
+    })
+
+    // 运行中设置的速度替换全天规则，并且在时间段内也生效
+    d.SetRateLimit(AllHosts, 1<<20)
+    d.SetRateLimit(AllHosts, 2<<20)
+    for _, now := range []time.Time{at(12, 0), at(23, 0)} {
+        if got := d.limiter.rate(AllHosts, now); got != 2<<20 {
+            t.Errorf("rate(*, %s) = %d, want %d", now.Format("15:04"), got, 2<<20)
+        }
+    }
+    rules, _ := d.RateLimits()
+    var fullDay, windowed int
+    for _, r := range rules {
+        switch {
+        case r.Host != AllHosts:
+        case r.Window != nil:
+            windowed++
+        case r.Override:
+            fullDay++
+        default:
+            t.Errorf("全天规则没有被替换: %+v", r)
+        }
+    }
+    if fullDay != 1 || windowed != 1 {
+        t.Errorf("rules = %+v", rules)
+    }
+    if got := d.limiter.rate("github.com", at(12, 0)); got != 2<<20 {
+        t.Errorf("其他主机的规则受到影响: rate = %d", got)
+    }
+
+    // 重新读取限速配置后恢复文件中的规则
+    d.SetRateLimits([]RateRule{{Host: AllHosts, Rate: 0, Window: &TimeWindow{22 * 60, 6 * 60}}, {Host: AllHosts, Rate: 5 << 20}})
+    if got := d.limiter.rate(AllHosts, at(23, 0)); got != 0 {
+        t.Errorf("SetRateLimits 后 rate = %d, want 0", got)
+    }
+}
@@ -0,0 +1,73 @@
+package downloader
+
+import (
+    "encoding/json"
+    "net/http"
+    "os"
+    "strings"
+)
+
+// resumeInfo 记录临时文件中部分内容的来源，断点续传时通过 If-Range 确认服务器上的文件没有变化，
+// 避免把不同来源或不同版本的内容拼接在一起
+type resumeInfo struct {
+    URL          string `json:"url"`
+    Size         int64  `json:"size"`
+    ETag         string `json:"etag,omitempty"` // 只记录强校验的 ETag，弱 ETag 不能用于 If-Range
+    LastModified string `json:"last_modified,omitempty"`
+}
+
+// resumePath 返回临时文件对应的来源记录，同样以 .tmp 结尾，会被当作临时文件处理
+func resumePath(tmpPath string) string {
+    return strings.TrimSuffix(tmpPath, ".tmp") + ".resume.tmp"
+}
+
+// newResumeInfo 根据完整响应（200）的响应头生成来源记录，没有可用的校验值时返回 nil
+func newResumeInfo(url string, size int64, resp *http.Response) *resumeInfo {
+    ri := &resumeInfo{URL: url, Size: size, LastModified: resp.Header.Get("Last-Modified")}
+    if etag := resp.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+        ri.ETag = etag
+    }
+    if ri.validator() == "" {
+        return nil
+    }
+    return ri
+}
+
+// validator 返回 If-Range 使用的校验值，优先使用 ETag
+func (ri *resumeInfo) validator() string {
+    if ri.ETag != "" {
+        return ri.ETag
+    }
+    return ri.LastModified
+}
+
+// readResumeInfo 读取临时文件的来源记录，不存在或无法解析时返回 nil
+func readResumeInfo(tmpPath string) *resumeInfo {
+    data, err := os.ReadFile(resumePath(tmpPath))
+    if err != nil {
+        return nil
+    }
+    var ri resumeInfo
+    if json.Unmarshal(data, &ri) != nil || ri.validator() == "" {
+        return nil
+    }
+    return &ri
+}
+
+// writeResumeInfo 写入临时文件的来源记录，ri 为 nil 时删除记录（之后不能从断点继续）
+func writeResumeInfo(tmpPath string, ri *resumeInfo) error {
+    if ri == nil {
+        removeResumeInfo(tmpPath)
+        return nil
+    }
+    data, err := json.Marshal(ri)
+    if err != nil {
+        return err
+    }
+    return os.WriteFile(resumePath(tmpPath), data, 0644)
+}
+
+// removeResumeInfo 删除临时文件的来源记录
+func removeResumeInfo(tmpPath string) {
+    os.Remove(resumePath(tmpPath))
+}
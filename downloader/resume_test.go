@@ -0,0 +1,156 @@
+package downloader
+
+import (
+    "bytes"
+    "context"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+)
+
+// rangeServer 支持 Range 和 If-Range 的服务器，记录每个请求的 Range 头
+type rangeServer struct {
+    *httptest.Server
+    mu      sync.Mutex
+    content []byte
+    etag    string
+    ranges  []string
+}
+
+func newRangeServer(t *testing.T, content []byte, etag string) *rangeServer {
+    rs := &rangeServer{content: content, etag: etag}
+    rs.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        rs.mu.Lock()
+        rs.ranges = append(rs.ranges, r.Header.Get("Range"))
+        content, etag := rs.content, rs.etag
+        rs.mu.Unlock()
+        if etag != "" {
+            w.Header().Set("ETag", etag)
+        }
+        http.ServeContent(w, r, "asset.bin", time.Time{}, bytes.NewReader(content))
+    }))
+    t.Cleanup(rs.Close)
+    return rs
+}
+
+func (rs *rangeServer) lastRange() string {
+    rs.mu.Lock()
+    defer rs.mu.Unlock()
+    return rs.ranges[len(rs.ranges)-1]
+}
+
+func TestDownloadResume(t *testing.T) {
+    content := bytes.Repeat([]byte("0123456789"), 1000)
+    half := len(content) / 2
+
+    tests := []struct {
+        name       string
+        serverETag string      // 服务器当前的 ETag
+        partial    []byte      // 上次保留的部分内容
+        info       *resumeInfo // 上次记录的来源，nil 表示没有记录
+        resumable  bool
+        wantRange  bool
+    }{
+        {"ETag 一致时从断点继续", `"v1"`, content[:half], &resumeInfo{Size: int64(len(content)), ETag: `"v1"`}, true, true},
+        {"服务器上的文件已变化时重新下载", `"v2"`, bytes.Repeat([]byte("x"), half), &resumeInfo{Size: int64(len(content)), ETag: `"v1"`}, true, true},
+        {"没有来源记录时重新下载", `"v1"`, bytes.Repeat([]byte("x"), half), nil, true, false},
+        {"没有期望哈希时重新下载", `"v1"`, bytes.Repeat([]byte("x"), half), &resumeInfo{Size: int64(len(content)), ETag: `"v1"`}, false, false},
+        {"记录的大小不同时重新下载", `"v1"`, bytes.Repeat([]byte("x"), half), &resumeInfo{Size: 1, ETag: `"v1"`}, true, false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            srv := newRangeServer(t, content, tt.serverETag)
+            tmpPath := filepath.Join(t.TempDir(), "asset.bin.tmp")
+            writeFile(t, tmpPath, string(tt.partial))
+            if tt.info != nil {
+                tt.info.URL = srv.URL
+                if err := writeResumeInfo(tmpPath, tt.info); err != nil {
+                    t.Fatal(err)
+                }
+            }
+
+            d := NewDownloader(t.TempDir(), nil)
+            if err := d.downloadWithProgress(context.Background(), srv.URL+"/asset.bin", tmpPath, int64(len(content)), tt.resumable, d.timeouts, nil); err != nil {
+                t.Fatal(err)
+            }
+            got, _ := os.ReadFile(tmpPath)
+            if !bytes.Equal(got, content) {
+                t.Errorf("下载的内容不正确（%d 字节），可能拼接了不同的内容", len(got))
+            }
+            if gotRange := srv.lastRange() != ""; gotRange != tt.wantRange {
+                t.Errorf("发送 Range = %v, want %v", gotRange, tt.wantRange)
+            }
+            if _, err := os.Stat(resumePath(tmpPath)); !os.IsNotExist(err) {
+                t.Errorf("下载完成后来源记录没有删除: %v", err)
+            }
+        })
+    }
+}
+
+func TestDownloadStallKeepsResumeInfo(t *testing.T) {
+    content := bytes.Repeat([]byte("a"), 10000)
+    for _, resumable := range []bool{true, false} {
+        // 发送一半内容后停止发送，直到客户端放弃
+        srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            w.Header().Set("ETag", `"v1"`)
+            w.Header().Set("Content-Length", "10000")
+            w.Write(content[:5000])
+            w.(http.Flusher).Flush()
+            <-r.Context().Done()
+        }))
+        tmpPath := filepath.Join(t.TempDir(), "asset.bin.tmp")
+        d := NewDownloader(t.TempDir(), nil)
+        to := d.timeouts
+        to.Idle = 100 * time.Millisecond
+        err := d.downloadWithProgress(context.Background(), srv.URL+"/asset.bin", tmpPath, int64(len(content)), resumable, to, nil)
+        srv.Close()
+        if err == nil || !strings.Contains(err.Error(), errStalled.Error()) {
+            t.Fatalf("resumable=%v: downloadWithProgress() = %v, want 下载停滞", resumable, err)
+        }
+
+        info, _ := os.Stat(tmpPath)
+        ri := readResumeInfo(tmpPath)
+        if resumable {
+            if info == nil || info.Size() != 5000 || ri == nil || ri.ETag != `"v1"` || ri.Size != 10000 {
+                t.Errorf("有期望哈希时应保留部分内容和来源记录: %v, %+v", info, ri)
+            }
+        } else if info != nil || ri != nil {
+            t.Errorf("没有期望哈希时不应保留部分内容: %v, %+v", info, ri)
+        }
+    }
+}
+
+func TestNewResumeInfo(t *testing.T) {
+    tests := []struct {
+        etag, lastModified string
+        want               string // 为空表示没有可用的校验值
+    }{
+        {`"abc"`, "", `"abc"`},
+        {`W/"abc"`, "", ""},
+        {`W/"abc"`, "Wed, 21 Oct 2015 07:28:00 GMT", "Wed, 21 Oct 2015 07:28:00 GMT"},
+        {`"abc"`, "Wed, 21 Oct 2015 07:28:00 GMT", `"abc"`},
+        {"", "", ""},
+    }
+    for _, tt := range tests {
+        resp := &http.Response{Header: http.Header{}}
+        if tt.etag != "" {
+            resp.Header.Set("ETag", tt.etag)
+        }
+        if tt.lastModified != "" {
+            resp.Header.Set("Last-Modified", tt.lastModified)
+        }
+        ri := newResumeInfo("https://example.com/a", 10, resp)
+        got := ""
+        if ri != nil {
+            got = ri.validator()
+        }
+        if got != tt.want {
+            t.Errorf("newResumeInfo(ETag %q, Last-Modified %q) validator = %q, want %q", tt.etag, tt.lastModified, got, tt.want)
+        }
+    }
+}
@@ -0,0 +1,134 @@
+package downloader
+
+import (
+    "context"
+    "net/url"
+    "sync"
+)
+
+// scheduler 在所有仓库和版本的下载之间分配连接：限制总连接数、每个源站主机和每个代理的连接数，
+// 空出的连接优先分给当前占用连接最少的仓库，避免资产多的仓库占满所有连接
+type scheduler struct {
+    mu       sync.Mutex
+    total    int // 总连接数上限，0 表示不限制
+    perHost  int // 直接下载时每个主机的连接数上限，0 表示不限制
+    perProxy int // 每个代理的连接数上限，0 表示不限制
+
+    active  int
+    hosts   map[string]int
+    proxies map[string]int
+    repos   map[string]int
+    waiting []*slotRequest // 按到达顺序排列
+}
+
+// slotRequest 是等待中的连接请求
+type slotRequest struct {
+    repo  string
+    host  string // 直接下载时的主机，经过代理时为空
+    proxy string
+    ready chan struct{}
+}
+
+func newScheduler(total, perHost, perProxy int) *scheduler {
+    return &scheduler{
+        total:    total,
+        perHost:  perHost,
+        perProxy: perProxy,
+        hosts:    make(map[string]int),
+        proxies:  make(map[string]int),
+        repos:    make(map[string]int),
+    }
+}
+
+// SetWorkers 设置同时进行的下载连接数：total 为总数，perHost 为直接下载时每个主机的连接数，
+// perProxy 为每个代理的连接数，0 表示不限制；应在开始下载前调用
+func (d *Downloader) SetWorkers(total, perHost, perProxy int) {
+    d.sched = newScheduler(total, perHost, perProxy)
+}
+
+// acquire 为仓库 repo 的一次下载等待连接，rawURL 为实际请求的地址，proxy 为使用的代理（直接下载时为空）
+// 返回的函数用于在下载结束后释放连接；ctx 在分配到连接之前被取消时返回 ctx 的错误
+func (s *scheduler) acquire(ctx context.Context, repo, rawURL, proxy string) (func(), error) {
+    req := &slotRequest{repo: repo, proxy: proxy, ready: make(chan struct{})}
+    if proxy == "" {
+        if u, err := url.Parse(rawURL); err == nil {
+            req.host = u.Host
+        }
+    }
+
+    var once sync.Once
+    release := func() {
+        once.Do(func() {
+            s.mu.Lock()
+            defer s.mu.Unlock()
+            s.active--
+            s.repos[req.repo]--
+            if req.proxy != "" {
+                s.proxies[req.proxy]--
+            } else {
+                s.hosts[req.host]--
+            }
+            s.dispatch()
+        })
+    }
+
+    s.mu.Lock()
+    s.waiting = append(s.waiting, req)
+    s.dispatch()
+    s.mu.Unlock()
+    select {
+    case <-req.ready:
+        return release, nil
+    case <-ctx.Done():
+    }
+
+    s.mu.Lock()
+    for i, w := range s.waiting {
+        if w == req {
+            s.waiting = append(s.waiting[:i], s.waiting[i+1:]...)
+            s.mu.Unlock()
+            return nil, ctx.Err()
+        }
+    }
+    s.mu.Unlock()
+    // 取消的同时已分配到连接
+    release()
+    return nil, ctx.Err()
+}
+
+// dispatch 将空闲连接分给等待中的请求：在满足主机和代理限制的请求中，
+// 选择所属仓库当前连接最少的，相同时选择最早到达的；调用方需持有锁
+func (s *scheduler) dispatch() {
+    for s.total <= 0 || s.active < s.total {
+        best := -1
+        for i, req := range s.waiting {
+            if !s.allowed(req) {
+                continue
+            }
+            if best < 0 || s.repos[req.repo] < s.repos[s.waiting[best].repo] {
+                best = i
+            }
+        }
+        if best < 0 {
+            return
+        }
+        req := s.waiting[best]
+        s.waiting = append(s.waiting[:best], s.waiting[best+1:]...)
+        s.active++
+        s.repos[req.repo]++
+        if req.proxy != "" {
+            s.proxies[req.proxy]++
+        } else {
+            s.hosts[req.host]++
+        }
+        close(req.ready)
+    }
+}
+
+// allowed 判断请求是否满足主机和代理的连接数限制；调用方需持有锁
+func (s *scheduler) allowed(req *slotRequest) bool {
+    if req.proxy != "" {
+        return s.perProxy <= 0 || s.proxies[req.proxy] < s.perProxy
+    }
+    return s.perHost <= 0 || s.hosts[req.host] < s.perHost
+}
@@ -0,0 +1,179 @@
+package downloader
+
+import (
+    "context"
+    "errors"
+    "testing"
+    "time"
+)
+
+// tryAcquire 在短时间内等待连接，等不到时返回 false
+func tryAcquire(t *testing.T, s *scheduler, repo, rawURL, proxy string) (func(), bool) {
+    t.Helper()
+    ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+    defer cancel()
+    release, err := s.acquire(ctx, repo, rawURL, proxy)
+    if err != nil {
+        if !errors.Is(err, context.DeadlineExceeded) {
+            t.Fatalf("acquire() = %v", err)
+        }
+        return nil, false
+    }
+    return release, true
+}
+
+// mustAcquire 获取连接，等待超过 1 秒视为失败
+func mustAcquire(t *testing.T, s *scheduler, repo, rawURL, proxy string) func() {
+    t.Helper()
+    ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+    defer cancel()
+    release, err := s.acquire(ctx, repo, rawURL, proxy)
+    if err != nil {
+        t.Fatalf("acquire(%s, %s, %q) = %v", repo, rawURL, proxy, err)
+    }
+    return release
+}
+
+// waitQueued 等待直到有 n 个请求在排队
+func waitQueued(t *testing.T, s *scheduler, n int) {
+    t.Helper()
+    deadline := time.Now().Add(time.Second)
+    for time.Now().Before(deadline) {
+        s.mu.Lock()
+        queued := len(s.waiting)
+        s.mu.Unlock()
+        if queued == n {
+            return
+        }
+        time.Sleep(time.Millisecond)
+    }
+    t.Fatalf("排队的请求数没有达到 %d", n)
+}
+
+func TestSchedulerTotalLimit(t *testing.T) {
+    s := newScheduler(2, 0, 0)
+    r1 := mustAcquire(t, s, "a", "https://github.com/a/1", "")
+    mustAcquire(t, s, "b", "https://example.com/b/1", "")
+    if _, ok := tryAcquire(t, s, "c", "https://example.org/c/1", ""); ok {
+        t.Fatalf("超过总连接数时不应分配连接")
+    }
+    r1()
+    r1() // 重复释放不应多释放连接
+    mustAcquire(t, s, "c", "https://example.org/c/1", "")
+    if _, ok := tryAcquire(t, s, "d", "https://example.net/d/1", ""); ok {
+        t.Fatalf("重复释放后分配了过多的连接")
+    }
+}
+
+func TestSchedulerPerHostLimit(t *testing.T) {
+    s := newScheduler(0, 1, 0)
+    release := mustAcquire(t, s, "a", "https://github.com/a/1", "")
+    if _, ok := tryAcquire(t, s, "b", "https://github.com/b/1", ""); ok {
+        t.Fatalf("同一主机超过连接数时不应分配连接")
+    }
+    // 其他主机和经过代理的下载不受该主机的限制
+    mustAcquire(t, s, "b", "https://objects.githubusercontent.com/b/1", "")
+    mustAcquire(t, s, "b", "https://gh-proxy.com/https://github.com/b/1", "gh-proxy.com")
+    release()
+    mustAcquire(t, s, "b", "https://github.com/b/1", "")
+}
+
+func TestSchedulerPerProxyLimit(t *testing.T) {
+    s := newScheduler(0, 0, 2)
+    mustAcquire(t, s, "a", "https://p1.example/x/1", "p1.example")
+    release := mustAcquire(t, s, "a", "https://p1.example/x/2", "p1.example")
+    if _, ok := tryAcquire(t, s, "b", "https://p1.example/x/3", "p1.example"); ok {
+        t.Fatalf("同一代理超过连接数时不应分配连接")
+    }
+    mustAcquire(t, s, "b", "https://p2.example/x/3", "p2.example")
+    // 直接下载时按主机计算，与代理无关
+    mustAcquire(t, s, "b", "https://p1.example/x/4", "")
+    release()
+    mustAcquire(t, s, "b", "https://p1.example/x/3", "p1.example")
+}
+
+func TestSchedulerFairness(t *testing.T) {
+    s := newScheduler(2, 0, 0)
+    a1 := mustAcquire(t, s, "big", "https://github.com/big/1", "")
+    a2 := mustAcquire(t, s, "big", "https://github.com/big/2", "")
+
+    // 资产多的仓库先排队，资产少的仓库后到达
+    granted := make(chan string, 3)
+    releases := make(chan func(), 3)
+    queue := func(repo, name string) {
+        go func() {
+            release, err := s.acquire(context.Background(), repo, "https://github.com/"+name, "")
+            if err != nil {
+                t.Errorf("acquire(%s) = %v", name, err)
+                return
+            }
+            releases <- release
+            granted <- name
+        }()
+    }
+    queue("big", "big/3")
+    waitQueued(t, s, 1)
+    queue("big", "big/4")
+    waitQueued(t, s, 2)
+    queue("small", "small/1")
+    waitQueued(t, s, 3)
+
+    // big 占用 1 个连接，small 占用 0 个：空出的连接分给 small
+    a1()
+    if got := <-granted; got != "small/1" {
+        t.Fatalf("第一个空出的连接分给了 %s, want small/1", got)
+    }
+    // big 不再占用连接，small 占用 1 个：分给 big 中最早到达的请求
+    a2()
+    if got := <-granted; got != "big/3" {
+        t.Fatalf("第二个空出的连接分给了 %s, want big/3", got)
+    }
+    (<-releases)()
+    (<-releases)()
+    if got := <-granted; got != "big/4" {
+        t.Fatalf("第三个空出的连接分给了 %s, want big/4", got)
+    }
+    (<-releases)()
+}
+
+func TestSchedulerCancelWhileWaiting(t *testing.T) {
+    s := newScheduler(1, 0, 0)
+    hold := mustAcquire(t, s, "a", "https://github.com/a/1", "")
+
+    ctx, cancel := context.WithCancel(context.Background())
+    errc := make(chan error, 1)
+    go func() {
+        _, err := s.acquire(ctx, "b", "https://github.com/b/1", "")
+        errc <- err
+    }()
+    waitQueued(t, s, 1)
+    cancel()
+    if err := <-errc; !errors.Is(err, context.Canceled) {
+        t.Fatalf("取消后 acquire() = %v, want context.Canceled", err)
+    }
+    waitQueued(t, s, 0)
+
+    // 取消的请求没有占用连接
+    hold()
+    mustAcquire(t, s, "c", "https://github.com/c/1", "")
+}
+
+func TestSchedulerCancelReleasesGrantedSlot(t *testing.T) {
+    // ctx 已取消时，请求可能在返回前已分配到连接，此时必须释放，不能泄漏
+    s := newScheduler(1, 0, 1)
+    ctx, cancel := context.WithCancel(context.Background())
+    cancel()
+    for i := 0; i < 100; i++ {
+        release, err := s.acquire(ctx, "a", "https://p.example/a/1", "p.example")
+        if err == nil {
+            release()
+        }
+    }
+    s.mu.Lock()
+    active, proxies, repos := s.active, s.proxies["p.example"], s.repos["a"]
+    s.mu.Unlock()
+    if active != 0 || proxies != 0 || repos != 0 {
+        t.Fatalf("取消后连接没有释放: active = %d, proxy = %d, repo = %d", active, proxies, repos)
+    }
+    mustAcquire(t, s, "b", "https://p.example/b/1", "p.example")
+}
@@ -0,0 +1,152 @@
+package downloader
+
+import (
+    "context"
+    "fmt"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "strings"
+    "time"
+
+    "github-downloader/logger"
+)
+
+// SelfUpdateOptions 是更新程序自身的选项
+type SelfUpdateOptions struct {
+    Exe       string // 正在运行的程序路径
+    Current   string // 当前版本
+    Asset     string // 当前平台的资产名，例如 github_download-linux-amd64
+    Force     bool   // 版本相同时也重新安装
+    CheckOnly bool   // 只检查是否有新版本
+}
+
+// SelfUpdate 获取仓库 ref 的最新版本，下载并校验当前平台的资产后替换正在运行的程序
+// 资产下载到下载器的下载目录（调用方通常使用临时目录）；返回最新版本以及是否已更新
+func (d *Downloader) SelfUpdate(ctx context.Context, ref RepoRef, opts RepoOptions, su SelfUpdateOptions) (release *Release, updated bool, err error) {
+    rs := d.beginRepo(ref.Owner, ref.Repo)
+    defer func() { d.finishRepo(rs, err) }()
+
+    release, err = d.findRelease(ctx, ref, "")
+    if err != nil {
+        return nil, false, err
+    }
+    logger.Info("当前版本: %s，最新版本: %s", su.Current, release.TagName)
+    if SameVersion(su.Current, release.TagName) && !su.Force {
+        logger.Info("已是最新版本")
+        return release, false, nil
+    }
+    var asset *Asset
+    for i := range release.Assets {
+        if release.Assets[i].Name == su.Asset {
+            asset = &release.Assets[i]
+        }
+    }
+    if asset == nil {
+        return release, false, fmt.Errorf("版本 %s 没有当前平台的资产 %s，可用的资产: %s", release.TagName, su.Asset, assetNames(release.Assets))
+    }
+    if su.CheckOnly {
+        return release, false, nil
+    }
+
+    dl := opts
+    dl.Filter = onlyAsset(release.Assets, asset.Name)
+    if err := d.processRelease(ctx, ref, release, dl, rs); err != nil {
+        return release, false, fmt.Errorf("下载或校验失败: %w", err)
+    }
+    versionDir := d.versionDir(ref.Repo, release.TagName)
+    src := filepath.Join(versionDir, asset.Name)
+    if info, err := os.Stat(src); err != nil || info.Size() != asset.Size {
+        return release, false, fmt.Errorf("%s 没有下载完成", asset.Name)
+    }
+    // 没有任何期望哈希时 verifyFiles 只会生成本地校验文件，不足以替换程序自身
+    if !hasExpectedDigest(versionDir, release.Assets, *asset) {
+        return release, false, fmt.Errorf("%s 没有官方哈希或校验文件，无法确认文件完整，拒绝更新", asset.Name)
+    }
+
+    if err := replaceExecutable(su.Exe, src); err != nil {
+        return release, false, err
+    }
+    logger.Info("已更新: %s -> %s", su.Exe, release.TagName)
+    return release, true, nil
+}
+
+// SameVersion 比较版本号，忽略前缀 v
+func SameVersion(a, b string) bool {
+    return strings.TrimPrefix(a, "v") == strings.TrimPrefix(b, "v")
+}
+
+// hasExpectedDigest 判断资产是否有来自 Release 的期望哈希：官方摘要、单文件校验文件或合并校验文件中的记录
+func hasExpectedDigest(dir string, assets []Asset, asset Asset) bool {
+    if !officialDigest(asset).IsZero() {
+        return true
+    }
+    if _, ok := pairSidecars(assets)[asset.Name]; ok {
+        return true
+    }
+    cs := newChecksumSet()
+    for _, a := range assets {
+        if isChecksumFile(a.Name) && cs.addFile(filepath.Join(dir, a.Name)) == nil && cs.hasDigest(asset.Name) {
+            return true
+        }
+    }
+    return false
+}
+
+// replaceExecutable 用 src 替换正在运行的程序 exe
+// 新程序先复制到同一目录并试运行，再将 exe 重命名为 .old、新程序重命名为 exe；
+// 任何一步失败或替换后无法运行时恢复旧程序
+func replaceExecutable(exe, src string) error {
+    ext := filepath.Ext(exe)
+    base := strings.TrimSuffix(exe, ext)
+    newPath, oldPath := base+".new"+ext, base+".old"+ext
+    // Windows 上无法删除正在运行的程序，上次更新留下的旧程序在这里删除
+    os.Remove(oldPath)
+
+    if err := copyExecutable(src, newPath); err != nil {
+        return fmt.Errorf("无法写入 %s: %w", newPath, err)
+    }
+    if err := checkExecutable(newPath); err != nil {
+        os.Remove(newPath)
+        return fmt.Errorf("新版本无法运行: %w", err)
+    }
+    if err := os.Rename(exe, oldPath); err != nil {
+        os.Remove(newPath)
+        return fmt.Errorf("无法替换 %s: %w", exe, err)
+    }
+    rollback := func(cause error) error {
+        os.Remove(exe)
+        if err := os.Rename(oldPath, exe); err != nil {
+            return fmt.Errorf("%v，且无法恢复旧版本（位于 %s）: %v", cause, oldPath, err)
+        }
+        logger.Warn("已恢复旧版本")
+        return cause
+    }
+    if err := os.Rename(newPath, exe); err != nil {
+        os.Remove(newPath)
+        return rollback(fmt.Errorf("无法替换 %s: %w", exe, err))
+    }
+    if err := checkExecutable(exe); err != nil {
+        return rollback(fmt.Errorf("替换后无法运行: %w", err))
+    }
+    if err := os.Remove(oldPath); err != nil {
+        logger.Info("旧版本将在下次更新时删除: %s", oldPath)
+    }
+    return nil
+}
+
+// checkExecutable 运行 path -version，确认文件可以在当前平台执行
+func checkExecutable(path string) error {
+    ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+    defer cancel()
+    out, err := exec.CommandContext(ctx, path, "-version").CombinedOutput()
+    msg := strings.TrimSpace(string(out))
+    if err != nil && msg != "" {
+        return fmt.Errorf("%v: %s", err, msg)
+    }
+    if err != nil {
+        return err
+    }
+    logger.Info("试运行: %s", msg)
+    return nil
+}
@@ -0,0 +1,269 @@
+package downloader
+
+import (
+    "bytes"
+    "crypto/ecdsa"
+    "crypto/ed25519"
+    "crypto/elliptic"
+    "crypto/rand"
+    "crypto/sha256"
+    "crypto/x509"
+    "encoding/base64"
+    "encoding/pem"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+
+    "github.com/ProtonMail/go-crypto/openpgp"
+    "golang.org/x/crypto/blake2b"
+)
+
+// testSigner 在测试中生成的一组 GPG、minisign 和 cosign 密钥
+type testSigner struct {
+    gpg       *openpgp.Entity
+    minisign  ed25519.PrivateKey
+    minisigID [8]byte
+    cosign    *ecdsa.PrivateKey
+}
+
+func newTestSigner(t *testing.T, name string) *testSigner {
+    t.Helper()
+    entity, err := openpgp.NewEntity(name, "", name+"@example.com", nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    _, minisignKey, err := ed25519.GenerateKey(rand.Reader)
+    if err != nil {
+        t.Fatal(err)
+    }
+    cosignKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+    if err != nil {
+        t.Fatal(err)
+    }
+    s := &testSigner{gpg: entity, minisign: minisignKey, cosign: cosignKey}
+    rand.Read(s.minisigID[:])
+    return s
+}
+
+// keys 将公钥写入 dir，返回只包含 kinds 中类型的受信任公钥配置
+func (s *testSigner) keys(t *testing.T, dir string, kinds ...string) TrustedKeys {
+    t.Helper()
+    keys := TrustedKeys{Dir: dir}
+    for _, kind := range kinds {
+        switch kind {
+        case SigGPG:
+            var buf bytes.Buffer
+            if err := s.gpg.Serialize(&buf); err != nil {
+                t.Fatal(err)
+            }
+            writeFile(t, filepath.Join(dir, "gpg.pub"), buf.String())
+            keys.GPG = []string{"gpg.pub"}
+        case SigMinisign:
+            raw := append([]byte("Ed"), s.minisigID[:]...)
+            raw = append(raw, s.minisign.Public().(ed25519.PublicKey)...)
+            keys.Minisign = []string{base64.StdEncoding.EncodeToString(raw)}
+        case SigCosign:
+            der, err := x509.MarshalPKIXPublicKey(&s.cosign.PublicKey)
+            if err != nil {
+                t.Fatal(err)
+            }
+            writeFile(t, filepath.Join(dir, "cosign.pub"), string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})))
+            keys.Cosign = []string{"cosign.pub"}
+        }
+    }
+    return keys
+}
+
+// sign 为 dir 中的 name 生成分离签名，返回签名文件名
+func (s *testSigner) sign(t *testing.T, dir, name, kind string) string {
+    t.Helper()
+    content, err := os.ReadFile(filepath.Join(dir, name))
+    if err != nil {
+        t.Fatal(err)
+    }
+    var sigName, sig string
+    switch kind {
+    case SigGPG:
+        var buf bytes.Buffer
+        if err := openpgp.ArmoredDetachSign(&buf, s.gpg, bytes.NewReader(content), nil); err != nil {
+            t.Fatal(err)
+        }
+        sigName, sig = name+".asc", buf.String()
+    case SigMinisign:
+        // 预哈希（ED）格式，minisign 0.8 之后的默认格式
+        digest := blake2b.Sum512(content)
+        signature := ed25519.Sign(s.minisign, digest[:])
+        raw := append([]byte("ED"), s.minisigID[:]...)
+        raw = append(raw, signature...)
+        trusted := "timestamp:1700000000\tfile:" + name
+        global := ed25519.Sign(s.minisign, append(append([]byte{}, signature...), trusted...))
+        sigName = name + ".minisig"
+        sig = "untrusted comment: signature from minisign secret key\n" +
+            base64.StdEncoding.EncodeToString(raw) + "\n" +
+            "trusted comment: " + trusted + "\n" +
+            base64.StdEncoding.EncodeToString(global) + "\n"
+    case SigCosign:
+        digest := sha256.Sum256(content)
+        signature, err := ecdsa.SignASN1(rand.Reader, s.cosign, digest[:])
+        if err != nil {
+            t.Fatal(err)
+        }
+        sigName, sig = name+".sig", base64.StdEncoding.EncodeToString(signature)
+    }
+    writeFile(t, filepath.Join(dir, sigName), sig)
+    return sigName
+}
+
+func listDir(t *testing.T, dir string) []string {
+    t.Helper()
+    entries, err := os.ReadDir(dir)
+    if err != nil {
+        t.Fatal(err)
+    }
+    var names []string
+    for _, e := range entries {
+        names = append(names, e.Name())
+    }
+    return names
+}
+
+func sigStatus(results []SigCheck, target string) string {
+    for _, sc := range results {
+        if sc.Target == target {
+            return sc.Status
+        }
+    }
+    return ""
+}
+
+func TestCheckSignatures(t *testing.T) {
+    signer := newTestSigner(t, "release")
+    other := newTestSigner(t, "other")
+
+    for _, kind := range []string{SigGPG, SigMinisign, SigCosign} {
+        t.Run(kind, func(t *testing.T) {
+            tests := []struct {
+                name       string
+                keys       func(keyDir string) TrustedKeys
+                tamper     bool
+                wantStatus string
+                wantErr    bool
+            }{
+                {"签名有效", func(d string) TrustedKeys { return signer.keys(t, d, kind) }, false, SigOK, false},
+                {"文件被篡改", func(d string) TrustedKeys { return signer.keys(t, d, kind) }, true, SigBad, true},
+                {"不是受信任的公钥签署", func(d string) TrustedKeys { return other.keys(t, d, kind) }, false, SigBad, true},
+                {"未配置该类型的公钥", func(d string) TrustedKeys {
+                    for _, k := range []string{SigGPG, SigMinisign, SigCosign} {
+                        if k != kind {
+                            return signer.keys(t, d, k)
+                        }
+                    }
+                    return TrustedKeys{}
+                }, false, SigNoKey, true},
+            }
+            for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                    dir := t.TempDir()
+                    writeFile(t, filepath.Join(dir, "tool.tar.gz"), "release content")
+                    sigName := signer.sign(t, dir, "tool.tar.gz", kind)
+                    if tt.tamper {
+                        writeFile(t, filepath.Join(dir, "tool.tar.gz"), "tampered content")
+                    }
+
+                    results, err := checkSignatures(dir, listDir(t, dir), tt.keys(t.TempDir()), nil)
+                    if (err != nil) != tt.wantErr {
+                        t.Fatalf("checkSignatures() error = %v, wantErr %v", err, tt.wantErr)
+                    }
+                    if len(results) == 0 || results[0].Signature != sigName || results[0].Type != kind || results[0].Status != tt.wantStatus {
+                        t.Fatalf("results = %+v, want %s %s %s", results, sigName, kind, tt.wantStatus)
+                    }
+                    // 签名无效时资产没有被覆盖
+                    if wantUnsigned := tt.wantStatus != SigOK; (sigStatus(results[1:], "tool.tar.gz") == SigUnsigned) != wantUnsigned {
+                        t.Errorf("results = %+v, 资产 unsigned = %v", results, !wantUnsigned)
+                    }
+                })
+            }
+        })
+    }
+}
+
+func TestCheckSignaturesChecksumFile(t *testing.T) {
+    signer := newTestSigner(t, "release")
+    dir := t.TempDir()
+    writeFile(t, filepath.Join(dir, "tool-linux.tar.gz"), "hello")
+    writeFile(t, filepath.Join(dir, "tool-darwin.tar.gz"), "hello")
+    writeFile(t, filepath.Join(dir, "SHA256SUMS"), sha256Hello+"  tool-linux.tar.gz\n"+sha256Hello+"  tool-darwin.tar.gz\n")
+    signer.sign(t, dir, "SHA256SUMS", SigMinisign)
+    keys := signer.keys(t, t.TempDir(), SigMinisign)
+
+    results, err := checkSignatures(dir, listDir(t, dir), keys, nil)
+    if err != nil {
+        t.Fatalf("签名有效的校验文件应覆盖其中列出的资产: %v, %+v", err, results)
+    }
+    if len(results) != 1 || results[0].Target != "SHA256SUMS" || results[0].Status != SigOK {
+        t.Errorf("results = %+v", results)
+    }
+
+    // 资产被篡改后校验文件中的哈希不匹配，不再被覆盖
+    writeFile(t, filepath.Join(dir, "tool-darwin.tar.gz"), "tampered")
+    results, err = checkSignatures(dir, listDir(t, dir), keys, nil)
+    if err == nil || !strings.Contains(err.Error(), "tool-darwin.tar.gz") {
+        t.Fatalf("checkSignatures() = %v, want 列出 tool-darwin.tar.gz 的错误", err)
+    }
+    if sigStatus(results, "tool-darwin.tar.gz") != SigUnsigned || sigStatus(results, "tool-linux.tar.gz") != "" {
+        t.Errorf("results = %+v", results)
+    }
+}
+
+func TestCheckSignaturesUncoveredAsset(t *testing.T) {
+    signer := newTestSigner(t, "release")
+    dir := t.TempDir()
+    writeFile(t, filepath.Join(dir, "tool.tar.gz"), "signed")
+    writeFile(t, filepath.Join(dir, "extra.zip"), "not signed")
+    writeFile(t, filepath.Join(dir, "extra.zip.sha256"), "abc")
+    writeFile(t, filepath.Join(dir, "checksums.txt"), sha256Hello+"  other.txt\n")
+    writeFile(t, filepath.Join(dir, releaseNotesFile), "notes")
+    signer.sign(t, dir, "tool.tar.gz", SigGPG)
+    keys := signer.keys(t, t.TempDir(), SigGPG)
+
+    results, err := checkSignatures(dir, listDir(t, dir), keys, nil)
+    if err == nil || !strings.Contains(err.Error(), "extra.zip") {
+        t.Fatalf("checkSignatures() = %v, want 列出 extra.zip 的错误", err)
+    }
+    // 校验文件、单文件校验文件、签名和元数据不需要签名
+    var unsigned []string
+    for _, sc := range results {
+        if sc.Status == SigUnsigned {
+            unsigned = append(unsigned, sc.Target)
+        }
+    }
+    if strings.Join(unsigned, ",") != "extra.zip" {
+        t.Errorf("unsigned = %v, want [extra.zip]", unsigned)
+    }
+
+    // 不在 names 中的文件（未选中的资产）不检查
+    if _, err := checkSignatures(dir, []string{"tool.tar.gz", "tool.tar.gz.asc"}, keys, nil); err != nil {
+        t.Errorf("checkSignatures(只有已签名的资产) = %v", err)
+    }
+    // 没有配置公钥时不验证签名
+    if results, err := checkSignatures(dir, listDir(t, dir), TrustedKeys{}, nil); err != nil || results != nil {
+        t.Errorf("未配置公钥时 checkSignatures() = %+v, %v", results, err)
+    }
+}
+
+func TestDetectSigType(t *testing.T) {
+    tests := []struct {
+        sig  []byte
+        want string
+    }{
+        {[]byte{0x89, 0x01, 0x33}, SigGPG},
+        {[]byte("-----BEGIN PGP SIGNATURE-----\n"), SigGPG},
+        {[]byte("MEUCIQDx..."), SigCosign},
+    }
+    for _, tt := range tests {
+        if got := detectSigType(tt.sig); got != tt.want {
+            t.Errorf("detectSigType(%q) = %s, want %s", tt.sig, got, tt.want)
+        }
+    }
+}
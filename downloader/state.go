@@ -0,0 +1,368 @@
+package downloader
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+
+    "github-downloader/logger"
+)
+
+// StateFile 是下载目录中的本地状态库，记录仓库和文件的状态，用于增量同步时快速跳过未变化的内容
+const StateFile = ".state.json"
+
+// stateVersion 是状态库的格式版本，格式不兼容地变化时递增（旧版本的状态库会被丢弃）
+const stateVersion = 1
+
+// State 是本地状态库，可并发使用；nil 表示不使用状态库
+type State struct {
+    mu    sync.Mutex
+    root  string // 下载目录，文件按相对于它的路径记录
+    dirty bool
+
+    Version   int                   `json:"version"`
+    UpdatedAt time.Time             `json:"updated_at"`
+    Repos     map[string]*RepoState `json:"repos"` // 键为 主机名/所有者/仓库名
+    Files     map[string]*FileState `json:"files"` // 键为相对于下载目录的路径
+}
+
+// RepoState 是单个仓库的状态
+type RepoState struct {
+    LastTag       string            `json:"last_tag"`
+    LastPublished time.Time         `json:"last_published"`
+    CheckedAt     time.Time         `json:"checked_at"`
+    Releases      map[string]string `json:"releases,omitempty"` // 版本 -> 上次成功处理时的资产指纹
+}
+
+// FileState 是单个文件的状态，大小或修改时间变化后记录的哈希不再可信
+type FileState struct {
+    Size    int64             `json:"size"`
+    ModTime time.Time         `json:"mtime"`
+    Digests map[string]string `json:"digests"` // 算法 -> 十六进制哈希
+}
+
+// NewState 创建空的状态库
+func NewState(root string) *State {
+    return &State{
+        root:    root,
+        Version: stateVersion,
+        Repos:   make(map[string]*RepoState),
+        Files:   make(map[string]*FileState),
+    }
+}
+
+// LoadState 读取下载目录中的状态库，不存在时返回空的状态库
+func LoadState(root string) (*State, error) {
+    s := NewState(root)
+    data, err := os.ReadFile(filepath.Join(root, StateFile))
+    if os.IsNotExist(err) {
+        return s, nil
+    }
+    if err != nil {
+        return s, err
+    }
+    var loaded State
+    if err := json.Unmarshal(data, &loaded); err != nil {
+        return s, fmt.Errorf("解析 %s 失败: %w", StateFile, err)
+    }
+    if loaded.Version != stateVersion {
+        return s, fmt.Errorf("%s 的格式版本 %d 不受支持", StateFile, loaded.Version)
+    }
+    if loaded.Repos != nil {
+        s.Repos = loaded.Repos
+    }
+    if loaded.Files != nil {
+        s.Files = loaded.Files
+    }
+    s.UpdatedAt = loaded.UpdatedAt
+    return s, nil
+}
+
+// Save 在有变化时写入状态库（先写临时文件再重命名）
+func (s *State) Save() error {
+    if s == nil {
+        return nil
+    }
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if !s.dirty {
+        return nil
+    }
+    if err := os.MkdirAll(s.root, 0755); err != nil {
+        return err
+    }
+    s.UpdatedAt = time.Now()
+    data, err := json.Marshal(s)
+    if err != nil {
+        return err
+    }
+    path := filepath.Join(s.root, StateFile)
+    if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+        return err
+    }
+    if err := os.Rename(path+".tmp", path); err != nil {
+        return err
+    }
+    s.dirty = false
+    return nil
+}
+
+// key 返回文件在状态库中的键，不在下载目录中时返回空字符串
+func (s *State) key(path string) string {
+    rel, err := filepath.Rel(s.root, path)
+    if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+        return ""
+    }
+    return filepath.ToSlash(rel)
+}
+
+// lookup 返回文件仍然有效的记录：大小和修改时间与当前文件一致，否则返回 nil
+// 调用方需持有锁
+func (s *State) lookup(path string) *FileState {
+    k := s.key(path)
+    fs := s.Files[k]
+    if k == "" || fs == nil {
+        return nil
+    }
+    info, err := os.Stat(path)
+    if err != nil || info.Size() != fs.Size || !info.ModTime().Equal(fs.ModTime) {
+        return nil
+    }
+    return fs
+}
+
+// unchanged 判断文件与记录一致且大小为 size；expected 不为空时还要求记录中有相同的哈希
+func (s *State) unchanged(path string, size int64, expected Digest) bool {
+    if s == nil {
+        return false
+    }
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    fs := s.lookup(path)
+    if fs == nil || fs.Size != size {
+        return false
+    }
+    return expected.IsZero() || strings.EqualFold(fs.Digests[expected.Algo], expected.Hex)
+}
+
+// digests 返回记录中 algos 对应的哈希，任一算法没有记录或文件已变化时返回 nil
+func (s *State) digests(path string, algos []string) map[string]string {
+    if s == nil {
+        return nil
+    }
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    fs := s.lookup(path)
+    if fs == nil {
+        return nil
+    }
+    sums := make(map[string]string, len(algos))
+    for _, algo := range algos {
+        sum, ok := fs.Digests[algo]
+        if !ok {
+            return nil
+        }
+        sums[algo] = sum
+    }
+    return sums
+}
+
+// recordFile 记录文件当前的大小、修改时间和已知的哈希
+// 文件未变化时合并到已有记录，否则替换
+func (s *State) recordFile(path string, sums map[string]string) {
+    if s == nil || len(sums) == 0 {
+        return
+    }
+    info, err := os.Stat(path)
+    if err != nil {
+        return
+    }
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    k := s.key(path)
+    if k == "" {
+        return
+    }
+    fs := s.lookup(path)
+    if fs == nil {
+        fs = &FileState{Size: info.Size(), ModTime: info.ModTime(), Digests: make(map[string]string)}
+        s.Files[k] = fs
+    }
+    for algo, sum := range sums {
+        fs.Digests[algo] = strings.ToLower(sum)
+    }
+    s.dirty = true
+}
+
+// forgetFile 删除文件的记录
+func (s *State) forgetFile(path string) {
+    if s == nil {
+        return
+    }
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if k := s.key(path); s.Files[k] != nil {
+        delete(s.Files, k)
+        s.dirty = true
+    }
+}
+
+// forgetDir 删除目录中所有文件的记录
+func (s *State) forgetDir(dir string) {
+    if s == nil {
+        return
+    }
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    prefix := s.key(dir)
+    if prefix == "" {
+        return
+    }
+    for k := range s.Files {
+        if strings.HasPrefix(k, prefix+"/") {
+            delete(s.Files, k)
+            s.dirty = true
+        }
+    }
+}
+
+// forgetRelease 删除版本的资产指纹，版本目录被删除后再次下载时会重新处理
+func (s *State) forgetRelease(ref RepoRef, tag string) {
+    if s == nil {
+        return
+    }
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if rs := s.Repos[ref.key()]; rs != nil && rs.Releases[tag] != "" {
+        delete(rs.Releases, tag)
+        s.dirty = true
+    }
+}
+
+// repo 返回仓库的状态，不存在时创建；调用方需持有锁
+func (s *State) repo(ref RepoRef) *RepoState {
+    k := ref.key()
+    rs := s.Repos[k]
+    if rs == nil {
+        rs = &RepoState{}
+        s.Repos[k] = rs
+    }
+    return rs
+}
+
+// releaseUnchanged 判断版本上次已成功处理、资产指纹相同，且所有资产文件都未变化
+// 只检查文件的大小和修改时间，不读取文件内容
+func (s *State) releaseUnchanged(ref RepoRef, dir, tag, fingerprint string, assets []Asset) bool {
+    if s == nil {
+        return false
+    }
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    rs := s.Repos[ref.key()]
+    if rs == nil || rs.Releases[tag] != fingerprint {
+        return false
+    }
+    for _, a := range assets {
+        if fs := s.lookup(filepath.Join(dir, a.Name)); fs == nil || fs.Size != a.Size {
+            return false
+        }
+    }
+    return true
+}
+
+// recordRelease 记录版本的处理结果；ok 为 false 时清除指纹，下次重新处理
+func (s *State) recordRelease(ref RepoRef, release *Release, fingerprint string, ok bool) {
+    if s == nil {
+        return
+    }
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    rs := s.repo(ref)
+    rs.CheckedAt = time.Now()
+    if rs.LastTag == "" || !release.PublishedAt.Before(rs.LastPublished) {
+        rs.LastTag, rs.LastPublished = release.TagName, release.PublishedAt
+    }
+    if rs.Releases == nil {
+        rs.Releases = make(map[string]string)
+    }
+    if ok {
+        rs.Releases[release.TagName] = fingerprint
+    } else {
+        delete(rs.Releases, release.TagName)
+    }
+    s.dirty = true
+}
+
+// key 返回仓库在状态库中的键：主机名/所有者/仓库名
+func (r RepoRef) key() string {
+    host := githubHost
+    if r.GitLab {
+        host = r.Host
+        if host == "" {
+            host = defaultGitLabHost
+        }
+    }
+    return host + "/" + r.Owner + "/" + r.Repo
+}
+
+// releaseFingerprint 根据选中的资产和影响校验结果的选项计算指纹，任一项变化都会导致版本重新处理
+func releaseFingerprint(assets []Asset, opts RepoOptions) string {
+    sorted := append([]Asset(nil), assets...)
+    sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+    h := sha256.New()
+    for _, a := range sorted {
+        fmt.Fprintf(h, "%s\x00%d\x00%s\x00%s\n", a.Name, a.Size, a.Digest, a.BrowserDownloadURL)
+    }
+    fmt.Fprintf(h, "verify=%s crosscheck=%s keys=%v", opts.Verify, opts.CrossCheck, opts.Keys)
+    return hex.EncodeToString(h.Sum(nil))
+}
+
+// SetState 设置下载器使用的状态库，为 nil 时不使用
+func (d *Downloader) SetState(s *State) {
+    d.state = s
+}
+
+// RebuildState 重新计算下载目录中所有版本目录内文件的 SHA-256，生成新的状态库
+// 仓库的最新版本来自各版本目录的 manifest.json；版本的资产指纹无法重建，下次同步时会重新校验
+func RebuildState(root string) (*State, error) {
+    dirs, err := FindVersionDirs(root)
+    if err != nil {
+        return nil, err
+    }
+    s := NewState(root)
+    s.dirty = true
+    for _, dir := range dirs {
+        entries, err := os.ReadDir(dir)
+        if err != nil {
+            return nil, err
+        }
+        for _, entry := range entries {
+            if !(entry.Type().IsRegular() || entry.Type()&os.ModeSymlink != 0) || strings.HasSuffix(entry.Name(), ".tmp") {
+                continue
+            }
+            path := filepath.Join(dir, entry.Name())
+            sum, err := computeSHA256(path)
+            if err != nil {
+                logger.Warn("计算哈希失败 %s: %v", path, err)
+                continue
+            }
+            s.recordFile(path, map[string]string{"sha256": sum})
+        }
+
+        if m, err := ReadManifest(dir); err == nil {
+            rs := s.repo(m.Ref())
+            if rs.LastTag == "" || !m.PublishedAt.Before(rs.LastPublished) {
+                rs.LastTag, rs.LastPublished = m.Tag, m.PublishedAt
+            }
+        }
+        logger.Info("已索引: %s", dir)
+    }
+    return s, nil
+}
@@ -0,0 +1,242 @@
+package downloader
+
+import (
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestStateUnchangedInvalidation(t *testing.T) {
+    root := t.TempDir()
+    path := filepath.Join(root, "tool", "v1.0.0", "tool.tar.gz")
+    sha := Digest{Algo: "sha256", Hex: sha256Hello}
+
+    tests := []struct {
+        name     string
+        modify   func(t *testing.T)
+        size     int64
+        expected Digest
+        want     bool
+    }{
+        {"未变化", nil, 5, sha, true},
+        {"不要求哈希", nil, 5, Digest{}, true},
+        {"期望大小不同", nil, 6, sha, false},
+        {"期望哈希不同", nil, 5, Digest{Algo: "sha256", Hex: md5Hello}, false},
+        {"没有记录的算法", nil, 5, Digest{Algo: "sha512", Hex: sha512Hello}, false},
+        {"哈希大小写不同", nil, 5, Digest{Algo: "sha256", Hex: "2CF24DBA5FB0A30E26E83B2AC5B9E29E1B161E5C1FA7425E73043362938B9824"}, true},
+        {"大小变化", func(t *testing.T) {
+            info, _ := os.Stat(path)
+            writeFile(t, path, "hello!")
+            os.Chtimes(path, info.ModTime(), info.ModTime())
+        }, 5, sha, false},
+        {"修改时间变化", func(t *testing.T) {
+            later := time.Now().Add(time.Hour)
+            os.Chtimes(path, later, later)
+        }, 5, sha, false},
+        {"文件被删除", func(t *testing.T) { os.Remove(path) }, 5, sha, false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            writeFile(t, path, "hello")
+            s := NewState(root)
+            s.recordFile(path, map[string]string{"sha256": sha256Hello})
+            if tt.modify != nil {
+                tt.modify(t)
+            }
+            if got := s.unchanged(path, tt.size, tt.expected); got != tt.want {
+                t.Errorf("unchanged() = %v, want %v", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestStateDigests(t *testing.T) {
+    root := t.TempDir()
+    path := filepath.Join(root, "tool", "v1.0.0", "tool.tar.gz")
+    writeFile(t, path, "hello")
+    s := NewState(root)
+    s.recordFile(path, map[string]string{"sha256": sha256Hello})
+    s.recordFile(path, map[string]string{"md5": md5Hello}) // 文件未变化，合并到已有记录
+
+    if sums := s.digests(path, []string{"sha256", "md5"}); sums["sha256"] != sha256Hello || sums["md5"] != md5Hello {
+        t.Errorf("digests() = %v", sums)
+    }
+    if sums := s.digests(path, []string{"sha256", "sha512"}); sums != nil {
+        t.Errorf("缺少算法时 digests() = %v, want nil", sums)
+    }
+
+    // 文件变化后重新记录会替换旧记录，旧算法的哈希不再保留
+    later := time.Now().Add(time.Hour)
+    writeFile(t, path, "world")
+    os.Chtimes(path, later, later)
+    s.recordFile(path, map[string]string{"sha256": "abc"})
+    if sums := s.digests(path, []string{"md5"}); sums != nil {
+        t.Errorf("文件变化后 digests() = %v, want nil", sums)
+    }
+
+    // 不在下载目录中的文件不记录
+    outside := filepath.Join(t.TempDir(), "x")
+    writeFile(t, outside, "hello")
+    s.recordFile(outside, map[string]string{"sha256": sha256Hello})
+    if len(s.Files) != 1 {
+        t.Errorf("Files = %v, want 只有 1 条记录", s.Files)
+    }
+}
+
+func TestStateReleaseUnchanged(t *testing.T) {
+    root := t.TempDir()
+    ref := RepoRef{Owner: "acme", Repo: "tool"}
+    dir := filepath.Join(root, "tool", "v1.0.0")
+    assets := []Asset{{Name: "a.tar.gz", Size: 5}, {Name: "b.zip", Size: 5}}
+    release := &Release{TagName: "v1.0.0", PublishedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+    fp := releaseFingerprint(assets, RepoOptions{})
+
+    setup := func(t *testing.T) *State {
+        s := NewState(root)
+        for _, a := range assets {
+            path := filepath.Join(dir, a.Name)
+            writeFile(t, path, "hello")
+            s.recordFile(path, map[string]string{"sha256": sha256Hello})
+        }
+        s.recordRelease(ref, release, fp, true)
+        return s
+    }
+
+    t.Run("未变化", func(t *testing.T) {
+        s := setup(t)
+        if !s.releaseUnchanged(ref, dir, "v1.0.0", fp, assets) {
+            t.Errorf("releaseUnchanged() = false, want true")
+        }
+    })
+    t.Run("指纹变化", func(t *testing.T) {
+        s := setup(t)
+        other := releaseFingerprint(assets, RepoOptions{Verify: VerifyStrict})
+        if other == fp {
+            t.Fatalf("选项变化时指纹应不同")
+        }
+        if s.releaseUnchanged(ref, dir, "v1.0.0", other, assets) {
+            t.Errorf("指纹变化时 releaseUnchanged() = true")
+        }
+    })
+    t.Run("资产大小变化", func(t *testing.T) {
+        s := setup(t)
+        changed := []Asset{{Name: "a.tar.gz", Size: 6}, {Name: "b.zip", Size: 5}}
+        if s.releaseUnchanged(ref, dir, "v1.0.0", fp, changed) {
+            t.Errorf("资产大小变化时 releaseUnchanged() = true")
+        }
+    })
+    t.Run("文件被改动", func(t *testing.T) {
+        s := setup(t)
+        later := time.Now().Add(time.Hour)
+        os.Chtimes(filepath.Join(dir, "b.zip"), later, later)
+        if s.releaseUnchanged(ref, dir, "v1.0.0", fp, assets) {
+            t.Errorf("文件修改时间变化时 releaseUnchanged() = true")
+        }
+    })
+    t.Run("处理失败清除指纹", func(t *testing.T) {
+        s := setup(t)
+        s.recordRelease(ref, release, fp, false)
+        if s.releaseUnchanged(ref, dir, "v1.0.0", fp, assets) {
+            t.Errorf("处理失败后 releaseUnchanged() = true")
+        }
+    })
+    t.Run("版本被删除", func(t *testing.T) {
+        s := setup(t)
+        s.forgetRelease(ref, "v1.0.0")
+        if s.releaseUnchanged(ref, dir, "v1.0.0", fp, assets) {
+            t.Errorf("forgetRelease 后 releaseUnchanged() = true")
+        }
+    })
+    t.Run("nil 状态库", func(t *testing.T) {
+        var s *State
+        if s.releaseUnchanged(ref, dir, "v1.0.0", fp, assets) || s.unchanged(filepath.Join(dir, "a.tar.gz"), 5, Digest{}) {
+            t.Errorf("nil 状态库不应跳过任何内容")
+        }
+    })
+}
+
+func TestStateSaveLoad(t *testing.T) {
+    root := t.TempDir()
+    path := filepath.Join(root, "tool", "v1.0.0", "tool.tar.gz")
+    writeFile(t, path, "hello")
+    ref := RepoRef{Owner: "acme", Repo: "tool"}
+    s := NewState(root)
+    s.recordFile(path, map[string]string{"sha256": sha256Hello})
+    s.recordRelease(ref, &Release{TagName: "v1.0.0"}, "fp", true)
+    if err := s.Save(); err != nil {
+        t.Fatal(err)
+    }
+
+    loaded, err := LoadState(root)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !loaded.unchanged(path, 5, Digest{Algo: "sha256", Hex: sha256Hello}) {
+        t.Errorf("重新加载后文件记录丢失: %+v", loaded.Files)
+    }
+    if rs := loaded.Repos[ref.key()]; rs == nil || rs.LastTag != "v1.0.0" || rs.Releases["v1.0.0"] != "fp" {
+        t.Errorf("重新加载后仓库记录 = %+v", rs)
+    }
+
+    writeFile(t, filepath.Join(root, StateFile), `{"version":99}`)
+    if s, err := LoadState(root); err == nil || len(s.Files) != 0 {
+        t.Errorf("不支持的格式版本应返回错误和空的状态库: %v", err)
+    }
+}
+
+func TestRebuildState(t *testing.T) {
+    root := t.TempDir()
+    writeManifestDir := func(tag string, published time.Time, files map[string]string) string {
+        dir := filepath.Join(root, "tool", tag)
+        writeFile(t, filepath.Join(dir, releaseNotesFile), "notes")
+        for name, content := range files {
+            writeFile(t, filepath.Join(dir, name), content)
+        }
+        m := &Manifest{Version: manifestVersion, Provider: ProviderGitHub, Host: githubHost, Owner: "acme", Repo: "tool", Tag: tag, PublishedAt: published}
+        if err := writeManifest(dir, m); err != nil {
+            t.Fatal(err)
+        }
+        return dir
+    }
+    newer := writeManifestDir("v2.0.0", time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), map[string]string{"tool.tar.gz": "hello", "tool.tar.gz.tmp": "hel"})
+    writeManifestDir("v1.0.0", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), map[string]string{"tool.tar.gz": "hello"})
+    // 隐藏目录（如内容寻址存储）不被索引
+    writeFile(t, filepath.Join(root, ".cas", "sha256", "ab", releaseNotesFile), "x")
+
+    s, err := RebuildState(root)
+    if err != nil {
+        t.Fatal(err)
+    }
+    asset := filepath.Join(newer, "tool.tar.gz")
+    if sums := s.digests(asset, []string{"sha256"}); sums["sha256"] != sha256Hello {
+        t.Errorf("digests(%s) = %v", asset, sums)
+    }
+    if s.digests(filepath.Join(newer, "tool.tar.gz.tmp"), []string{"sha256"}) != nil {
+        t.Errorf("临时文件不应被索引")
+    }
+    for k := range s.Files {
+        if strings.HasPrefix(k, ".") {
+            t.Errorf("隐藏目录中的文件被索引: %s", k)
+        }
+    }
+
+    ref := RepoRef{Owner: "acme", Repo: "tool"}
+    rs := s.Repos[ref.key()]
+    if rs == nil || rs.LastTag != "v2.0.0" {
+        t.Fatalf("LastTag = %+v, want v2.0.0", rs)
+    }
+    // 资产指纹无法重建，版本下次同步时会重新校验
+    assets := []Asset{{Name: "tool.tar.gz", Size: 5}}
+    if s.releaseUnchanged(ref, newer, "v2.0.0", releaseFingerprint(assets, RepoOptions{}), assets) {
+        t.Errorf("重建后的状态库不应跳过版本")
+    }
+
+    // 重建后文件被改动，记录失效
+    later := time.Now().Add(time.Hour)
+    os.Chtimes(asset, later, later)
+    if s.unchanged(asset, 5, Digest{}) {
+        t.Errorf("重建后文件被改动，unchanged() = true")
+    }
+}
@@ -0,0 +1,191 @@
+package downloader
+
+import (
+    "sort"
+    "sync"
+    "time"
+
+    "github-downloader/logger"
+)
+
+// 仓库处理状态
+const (
+    RepoOK       = "ok"         // 有新文件下载且全部成功
+    RepoUpToDate = "up_to_date" // 没有需要下载的文件
+    RepoFailed   = "failed"     // 获取元数据、下载或校验失败
+)
+
+// directProxy 是汇总中不经过代理直接下载时使用的名称
+const directProxy = "direct"
+
+// RepoSummary 是单个仓库的处理结果
+type RepoSummary struct {
+    Repo       string    `json:"repo"`
+    Status     string    `json:"status"`
+    Error      string    `json:"error,omitempty"`
+    Releases   int       `json:"releases"`
+    Downloaded int       `json:"assets_downloaded"`
+    Skipped    int       `json:"assets_skipped"`
+    Failed     int       `json:"assets_failed"`
+    FailedRels int       `json:"releases_failed"`              // 创建目录或校验失败的版本数
+    Quarantined int      `json:"assets_quarantined,omitempty"` // 交叉校验不一致被隔离的资产数（同时计入 Failed）
+    Bytes      int64     `json:"bytes"`
+    Proxies    map[string]int `json:"proxies,omitempty"`
+    Start      time.Time `json:"start"`
+    Duration   float64   `json:"duration_seconds"`
+
+    mu sync.Mutex // 同一仓库的多个版本和资产并发处理
+}
+
+// Summary 汇总一次运行中所有 Process* 调用的结果，可并发使用
+type Summary struct {
+    mu    sync.Mutex
+    start time.Time
+    repos []*RepoSummary
+}
+
+// SummaryReport 是 Summary 的快照，用于输出和序列化
+type SummaryReport struct {
+    Start      time.Time      `json:"start"`
+    End        time.Time      `json:"end"`
+    Duration   float64        `json:"duration_seconds"`
+    ReposOK    int            `json:"repos_ok"`
+    ReposFail  int            `json:"repos_failed"`
+    ReposSame  int            `json:"repos_up_to_date"`
+    Downloaded int            `json:"assets_downloaded"`
+    Skipped    int            `json:"assets_skipped"`
+    Failed     int            `json:"assets_failed"`
+    Quarantined int           `json:"assets_quarantined,omitempty"`
+    Bytes      int64          `json:"bytes"`
+    Proxies    map[string]int `json:"proxies"` // 代理 -> 通过它下载的文件数
+    Repos      []*RepoSummary `json:"repos"`
+}
+
+func newSummary() *Summary {
+    return &Summary{start: time.Now()}
+}
+
+// Reset 清空已记录的结果，开始新一轮统计（守护进程每轮同步前调用）
+func (s *Summary) Reset() {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.start = time.Now()
+    s.repos = nil
+}
+
+// Report 返回当前的汇总快照
+func (s *Summary) Report() SummaryReport {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    end := time.Now()
+    r := SummaryReport{
+        Start:    s.start,
+        End:      end,
+        Duration: end.Sub(s.start).Seconds(),
+        Proxies:  make(map[string]int),
+        Repos:    append([]*RepoSummary(nil), s.repos...),
+    }
+    for _, rs := range s.repos {
+        switch rs.Status {
+        case RepoOK:
+            r.ReposOK++
+        case RepoUpToDate:
+            r.ReposSame++
+        default:
+            r.ReposFail++
+        }
+        r.Downloaded += rs.Downloaded
+        r.Skipped += rs.Skipped
+        r.Failed += rs.Failed
+        r.Quarantined += rs.Quarantined
+        r.Bytes += rs.Bytes
+        for p, n := range rs.Proxies {
+            r.Proxies[p] += n
+        }
+    }
+    sort.Slice(r.Repos, func(i, j int) bool { return r.Repos[i].Repo < r.Repos[j].Repo })
+    return r
+}
+
+// Summary 返回下载器的运行汇总
+func (d *Downloader) Summary() *Summary {
+    return d.summary
+}
+
+// beginRepo 开始记录一个仓库的处理结果
+func (d *Downloader) beginRepo(owner, repo string) *RepoSummary {
+    rs := &RepoSummary{Repo: owner + "/" + repo, Start: time.Now()}
+    d.emit(Event{Type: EventRepoStart, Repo: rs.Repo})
+    return rs
+}
+
+// finishRepo 根据处理过程中的统计和返回的错误确定仓库状态，并加入汇总
+func (d *Downloader) finishRepo(rs *RepoSummary, err error) {
+    rs.Duration = time.Since(rs.Start).Seconds()
+    switch {
+    case err != nil:
+        rs.Status = RepoFailed
+        rs.Error = err.Error()
+    case rs.Failed > 0 || rs.FailedRels > 0:
+        rs.Status = RepoFailed
+    case rs.Downloaded > 0:
+        rs.Status = RepoOK
+    default:
+        rs.Status = RepoUpToDate
+    }
+
+    d.summary.mu.Lock()
+    d.summary.repos = append(d.summary.repos, rs)
+    d.summary.mu.Unlock()
+    // 每个仓库处理完都保存状态库，中途退出时已完成的部分不会丢失
+    if err := d.state.Save(); err != nil {
+        logger.Warn("无法保存状态库: %v", err)
+    }
+    d.emit(Event{Type: EventRepoDone, Repo: rs.Repo, Status: rs.Status, Error: rs.Error, Result: rs})
+}
+
+// addRelease 记录开始处理一个版本
+func (rs *RepoSummary) addRelease() {
+    rs.mu.Lock()
+    defer rs.mu.Unlock()
+    rs.Releases++
+}
+
+// failRelease 记录一个版本处理失败
+func (rs *RepoSummary) failRelease() {
+    rs.mu.Lock()
+    defer rs.mu.Unlock()
+    rs.FailedRels++
+}
+
+// addQuarantined 记录一个被隔离的资产
+func (rs *RepoSummary) addQuarantined() {
+    rs.mu.Lock()
+    defer rs.mu.Unlock()
+    rs.Quarantined++
+}
+
+// recordAsset 记录单个资产的下载结果
+func (rs *RepoSummary) recordAsset(res *fetchResult, err error) {
+    rs.mu.Lock()
+    defer rs.mu.Unlock()
+    if err != nil {
+        rs.Failed++
+        return
+    }
+    if res.skipped {
+        rs.Skipped++
+        return
+    }
+    rs.Downloaded++
+    rs.Bytes += res.bytes
+    proxy := res.proxy
+    if proxy == "" {
+        proxy = directProxy
+    }
+    if rs.Proxies == nil {
+        rs.Proxies = make(map[string]int)
+    }
+    rs.Proxies[proxy]++
+}
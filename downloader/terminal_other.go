@@ -0,0 +1,10 @@
+//go:build !windows
+
+package downloader
+
+import "os"
+
+// enableVirtualTerminal 在 Windows 以外的平台上无需设置，终端总是支持控制序列
+func enableVirtualTerminal(f *os.File) bool {
+    return true
+}
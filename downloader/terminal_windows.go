@@ -0,0 +1,24 @@
+package downloader
+
+import (
+    "os"
+    "syscall"
+)
+
+var procSetConsoleMode = syscall.NewLazyDLL("kernel32.dll").NewProc("SetConsoleMode")
+
+// enableVirtualTerminal 为控制台开启控制序列支持（ENABLE_VIRTUAL_TERMINAL_PROCESSING），
+// 旧版本的 Windows 不支持时返回 false
+func enableVirtualTerminal(f *os.File) bool {
+    const enableVirtualTerminalProcessing = 0x0004
+    h := syscall.Handle(f.Fd())
+    var mode uint32
+    if err := syscall.GetConsoleMode(h, &mode); err != nil {
+        return false
+    }
+    if mode&enableVirtualTerminalProcessing != 0 {
+        return true
+    }
+    r, _, _ := procSetConsoleMode.Call(uintptr(h), uintptr(mode|enableVirtualTerminalProcessing))
+    return r != 0
+}
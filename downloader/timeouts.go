@@ -0,0 +1,138 @@
+package downloader
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "io"
+    "net"
+    "net/http"
+    "time"
+)
+
+// apiTimeout 是获取 Release 元数据等 API 请求的总超时
+const apiTimeout = 60 * time.Second
+
+// Timeouts 是下载请求各阶段的超时
+type Timeouts struct {
+    Connect time.Duration // 建立 TCP 连接
+    TLS     time.Duration // TLS 握手
+    Header  time.Duration // 发送请求后等待响应头
+    Idle    time.Duration // 下载过程中连续没有收到数据的最长时间，超过即视为停滞
+    Total   time.Duration // 单次下载一个文件的最长时间，0 表示不限制
+}
+
+// DefaultTimeouts 是未设置时使用的超时，默认不限制单次下载的总时间
+var DefaultTimeouts = Timeouts{
+    Connect: 30 * time.Second,
+    TLS:     30 * time.Second,
+    Header:  60 * time.Second,
+    Idle:    60 * time.Second,
+}
+
+// Merge 返回用 o 中不为 0 的值覆盖 t 后的超时
+func (t Timeouts) Merge(o Timeouts) Timeouts {
+    if o.Connect > 0 {
+        t.Connect = o.Connect
+    }
+    if o.TLS > 0 {
+        t.TLS = o.TLS
+    }
+    if o.Header > 0 {
+        t.Header = o.Header
+    }
+    if o.Idle > 0 {
+        t.Idle = o.Idle
+    }
+    if o.Total > 0 {
+        t.Total = o.Total
+    }
+    return t
+}
+
+// errStalled 表示下载在 Idle 时间内没有收到任何数据，此时更换代理
+var errStalled = errors.New("下载停滞")
+
+// errTotalTimeout 表示单次下载超过了 Total
+var errTotalTimeout = errors.New("下载超时")
+
+// transportKey 是决定连接建立方式的超时，相同的超时共用一个 http.Client 以复用连接
+type transportKey struct {
+    connect, tls, header time.Duration
+}
+
+// SetTimeouts 设置全局超时，t 中为 0 的值使用 DefaultTimeouts；仓库选项中的超时覆盖全局超时
+func (d *Downloader) SetTimeouts(t Timeouts) {
+    d.timeouts = DefaultTimeouts.Merge(t)
+    d.client = d.clientFor(d.timeouts)
+}
+
+// clientFor 返回按 t 的连接、TLS 握手和响应头超时建立连接的 http.Client
+// 读取响应体的超时由 watch 处理，http.Client 本身不限制总时间
+func (d *Downloader) clientFor(t Timeouts) *http.Client {
+    key := transportKey{t.Connect, t.TLS, t.Header}
+    d.clientsMu.Lock()
+    defer d.clientsMu.Unlock()
+    if c, ok := d.clients[key]; ok {
+        return c
+    }
+    tr := http.DefaultTransport.(*http.Transport).Clone()
+    tr.DialContext = (&net.Dialer{Timeout: t.Connect, KeepAlive: 30 * time.Second}).DialContext
+    tr.TLSHandshakeTimeout = t.TLS
+    tr.ResponseHeaderTimeout = t.Header
+    c := &http.Client{Transport: tr}
+    if d.clients == nil {
+        d.clients = make(map[transportKey]*http.Client)
+    }
+    d.clients[key] = c
+    return c
+}
+
+// watch 返回单次下载使用的 context 以及包装响应体的函数：
+// 超过 t.Total 时取消请求（原因为 errTotalTimeout），读取响应体时超过 t.Idle 没有数据也取消请求（原因为 errStalled）
+// 调用方结束时需要调用返回的 cancel
+func watch(ctx context.Context, t Timeouts) (context.Context, func(io.Reader) io.Reader, context.CancelFunc) {
+    ctx, cancelCause := context.WithCancelCause(ctx)
+    stop := func() {}
+    if t.Total > 0 {
+        timer := time.AfterFunc(t.Total, func() { cancelCause(errTotalTimeout) })
+        stop = func() { timer.Stop() }
+    }
+    wrap := func(r io.Reader) io.Reader {
+        if t.Idle <= 0 {
+            return r
+        }
+        timer := time.AfterFunc(t.Idle, func() { cancelCause(errStalled) })
+        timer.Stop()
+        return &idleReader{r: r, idle: t.Idle, timer: timer}
+    }
+    return ctx, wrap, func() {
+        stop()
+        cancelCause(nil)
+    }
+}
+
+// timeoutError 将因 watch 的超时而失败的请求错误转换为说明原因的错误，其他错误原样返回
+func timeoutError(ctx context.Context, t Timeouts, err error) error {
+    switch cause := context.Cause(ctx); {
+    case errors.Is(cause, errStalled):
+        return fmt.Errorf("%w: %s 内没有收到数据", errStalled, t.Idle)
+    case errors.Is(cause, errTotalTimeout):
+        return fmt.Errorf("%w: 超过 %s", errTotalTimeout, t.Total)
+    }
+    return err
+}
+
+// idleReader 只计算等待网络数据的时间，限速等待和写入磁盘的时间不计入
+type idleReader struct {
+    r     io.Reader
+    idle  time.Duration
+    timer *time.Timer
+}
+
+func (r *idleReader) Read(p []byte) (int, error) {
+    r.timer.Reset(r.idle)
+    n, err := r.r.Read(p)
+    r.timer.Stop()
+    return n, err
+}
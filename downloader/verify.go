@@ -0,0 +1,318 @@
+package downloader
+
+import (
+    "bufio"
+    "bytes"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "text/tabwriter"
+)
+
+// 单个文件的校验状态
+const (
+    CheckOK           = "ok"             // 所有哈希均匹配
+    CheckMismatch     = "mismatch"       // 至少一个哈希不匹配
+    CheckMissing      = "missing"        // 应下载的资产不存在（如下载失败）
+    CheckNotSelected  = "not_selected"   // 资产被筛选规则排除，未下载
+    CheckNotInRelease = "not_in_release" // 校验文件中列出的文件不是该版本的资产
+    CheckError        = "error"          // 无法读取文件或无法识别哈希算法
+    CheckUnsupported  = "unsupported"    // 校验文件中只有不支持的算法（如 sha3-256）的哈希
+)
+
+// FileCheck 是合并校验文件中单个文件的校验结果
+type FileCheck struct {
+    Name    string   `json:"name"`
+    Algos   []string `json:"algos,omitempty"`
+    Sources []string `json:"sources,omitempty"` // 提供哈希的校验文件
+    Status  string   `json:"status"`
+    Error   string   `json:"error,omitempty"`
+}
+
+// 合并校验文件的严格程度
+const (
+    VerifyStrict  = "strict"  // 校验文件中的任何文件未通过校验（包括未下载的）均视为失败
+    VerifyNormal  = "normal"  // 哈希不匹配、读取错误或应下载的资产缺失视为失败（默认）
+    VerifyLenient = "lenient" // 仅哈希不匹配或读取错误视为失败，不支持的算法跳过并警告
+)
+
+// ParseVerifyMode 检查严格程度名称，空字符串表示默认的 normal
+func ParseVerifyMode(s string) (string, error) {
+    switch s {
+    case "":
+        return VerifyNormal, nil
+    case VerifyStrict, VerifyNormal, VerifyLenient:
+        return s, nil
+    }
+    return "", fmt.Errorf("无效的校验模式 %q（可选 strict、normal、lenient）", s)
+}
+
+// checkFailed 按严格程度判断单个文件的结果是否导致版本校验失败
+func checkFailed(status, mode string) bool {
+    switch status {
+    case CheckOK:
+        return false
+    case CheckMismatch, CheckError:
+        return true
+    case CheckMissing, CheckUnsupported:
+        return mode != VerifyLenient
+    }
+    return mode == VerifyStrict
+}
+
+// checksumSumsNames 是常见的合并校验文件名（小写）
+var checksumSumsNames = map[string]bool{
+    "sha256sums": true, "sha512sums": true, "sha1sums": true, "md5sums": true,
+    "b2sums": true, "blake3sums": true, "checksums": true,
+    "sha256sums.txt": true, "sha512sums.txt": true, "b2sums.txt": true,
+    "sha256sum.txt": true, "sha512sum.txt": true, "sha1sum.txt": true, "md5sum.txt": true,
+}
+
+// isChecksumFile 判断文件名是否为合并校验文件，
+// 如 SHA256SUMS、checksums.txt、foo_1.0.0_checksums.txt
+func isChecksumFile(name string) bool {
+    lower := strings.ToLower(name)
+    return checksumSumsNames[lower] || strings.HasSuffix(lower, "checksums.txt")
+}
+
+// findChecksumFiles 返回目录中的合并校验文件
+func findChecksumFiles(dir string) ([]string, error) {
+    entries, err := os.ReadDir(dir)
+    if err != nil {
+        return nil, err
+    }
+    var paths []string
+    for _, entry := range entries {
+        if !entry.IsDir() && isChecksumFile(entry.Name()) {
+            paths = append(paths, filepath.Join(dir, entry.Name()))
+        }
+    }
+    return paths, nil
+}
+
+// verifyChecksumFiles 按一个或多个合并校验文件校验 dir 中的文件
+// 同一文件在多个校验文件中或以多种算法出现时，只读取一次文件计算所有哈希；
+// st 不为 nil 时使用其中未变化文件的哈希，并记录新计算的哈希；
+// 不会在第一个失败处停止，而是返回每个文件的结果（按文件名排序）
+func verifyChecksumFiles(dir string, paths []string, st *State) ([]FileCheck, error) {
+    cs := newChecksumSet()
+    cs.state = st
+    for _, path := range paths {
+        if err := cs.addFile(path); err != nil {
+            return nil, err
+        }
+    }
+    return cs.verify(dir), nil
+}
+
+// checksumSet 汇总来自多个来源（合并校验文件、单文件校验文件等）的期望哈希
+type checksumSet struct {
+    wants map[string]*expectation
+    state *State // 为 nil 时总是重新计算哈希
+}
+
+type expectation struct {
+    digests     []Digest
+    sources     []string
+    invalid     bool     // 存在无法识别算法的记录
+    unsupported []string // 记录中显式声明的不支持的算法
+}
+
+func newChecksumSet() *checksumSet {
+    return &checksumSet{wants: make(map[string]*expectation)}
+}
+
+// add 记录 name 的一个期望哈希，dg 为零值表示无法识别算法的记录
+func (cs *checksumSet) add(name string, dg Digest, source string) {
+    w := cs.expect(name, source)
+    if dg.Algo == "" {
+        w.invalid = true
+        return
+    }
+    if !containsDigest(w.digests, dg) {
+        w.digests = append(w.digests, dg)
+    }
+}
+
+// addUnsupported 记录 name 的一个使用不支持算法的期望哈希
+func (cs *checksumSet) addUnsupported(name, algo, source string) {
+    w := cs.expect(name, source)
+    if !containsString(w.unsupported, algo) {
+        w.unsupported = append(w.unsupported, algo)
+    }
+}
+
+func (cs *checksumSet) expect(name, source string) *expectation {
+    w := cs.wants[name]
+    if w == nil {
+        w = &expectation{}
+        cs.wants[name] = w
+    }
+    if !containsString(w.sources, source) {
+        w.sources = append(w.sources, source)
+    }
+    return w
+}
+
+// has 判断校验文件中是否列出了 name
+func (cs *checksumSet) has(name string) bool {
+    return cs.wants[name] != nil
+}
+
+// hasDigest 判断是否有 name 的可用期望哈希（不包括无法识别或不支持的算法）
+func (cs *checksumSet) hasDigest(name string) bool {
+    w := cs.wants[name]
+    return w != nil && len(w.digests) > 0
+}
+
+// addFile 读取合并校验文件中的所有记录
+func (cs *checksumSet) addFile(path string) error {
+    source := filepath.Base(path)
+    fromName := algoFromFileName(source)
+    f, err := os.Open(path)
+    if err != nil {
+        return err
+    }
+    defer f.Close()
+
+    scanner := bufio.NewScanner(f)
+    for scanner.Scan() {
+        name, explicit, sum, ok := parseChecksumLine(scanner.Text())
+        if !ok || name == "" {
+            continue
+        }
+        if explicit != "" && !supportedAlgo(explicit) {
+            cs.addUnsupported(filepath.Base(name), explicit, source)
+            continue
+        }
+        algo := resolveAlgo(explicit, fromName, len(sum))
+        cs.add(filepath.Base(name), Digest{Algo: algo, Hex: sum}, source)
+    }
+    if err := scanner.Err(); err != nil {
+        return fmt.Errorf("读取 %s 失败: %w", source, err)
+    }
+    return nil
+}
+
+// verify 校验 dir 中的文件，返回每个文件的结果（按文件名排序）
+func (cs *checksumSet) verify(dir string) []FileCheck {
+    names := make([]string, 0, len(cs.wants))
+    for name := range cs.wants {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+
+    results := make([]FileCheck, 0, len(names))
+    for _, name := range names {
+        w := cs.wants[name]
+        fc := FileCheck{Name: name, Sources: w.sources}
+        for _, dg := range w.digests {
+            if !containsString(fc.Algos, dg.Algo) {
+                fc.Algos = append(fc.Algos, dg.Algo)
+            }
+        }
+        fc.Status, fc.Error = checkFile(filepath.Join(dir, name), fc.Algos, w, cs.state)
+        results = append(results, fc)
+    }
+    return results
+}
+
+// checkFile 计算文件哈希并与所有期望值比较，st 中有未变化文件的哈希时不读取文件
+func checkFile(path string, algos []string, w *expectation, st *State) (string, string) {
+    digests := w.digests
+    if len(digests) == 0 && w.invalid {
+        return CheckError, "无法识别哈希算法"
+    }
+    if _, err := os.Stat(path); os.IsNotExist(err) {
+        return CheckMissing, "文件不存在"
+    }
+    if len(digests) == 0 {
+        return CheckUnsupported, "不支持的哈希算法: " + strings.Join(w.unsupported, ", ")
+    }
+    sums := st.digests(path, algos)
+    if sums == nil {
+        var err error
+        if sums, err = computeDigests(path, algos); err != nil {
+            return CheckError, err.Error()
+        }
+        st.recordFile(path, sums)
+    }
+    var mismatches []string
+    for _, dg := range digests {
+        if actual := sums[dg.Algo]; !strings.EqualFold(actual, dg.Hex) {
+            mismatches = append(mismatches, fmt.Sprintf("%s 不匹配 (期望 %s, 实际 %s)", strings.ToUpper(dg.Algo), dg.Hex, actual))
+        }
+    }
+    if len(mismatches) > 0 {
+        return CheckMismatch, strings.Join(mismatches, "; ")
+    }
+    return CheckOK, ""
+}
+
+// classifyMissing 区分文件缺失的原因：应下载但缺失、被筛选规则排除，或不属于该版本
+func classifyMissing(results []FileCheck, all, selected []Asset) {
+    inRelease := make(map[string]bool, len(all))
+    for _, a := range all {
+        inRelease[a.Name] = true
+    }
+    isSelected := make(map[string]bool, len(selected))
+    for _, a := range selected {
+        isSelected[a.Name] = true
+    }
+    for i := range results {
+        fc := &results[i]
+        if fc.Status != CheckMissing {
+            continue
+        }
+        switch {
+        case isSelected[fc.Name]:
+            fc.Error = "应下载但文件不存在"
+        case inRelease[fc.Name]:
+            fc.Status, fc.Error = CheckNotSelected, "被筛选规则排除，未下载"
+        default:
+            fc.Status, fc.Error = CheckNotInRelease, "不是该版本的资产"
+        }
+    }
+}
+
+// formatChecks 将校验结果格式化为表格，每个元素为一行
+func formatChecks(results []FileCheck, mode string) []string {
+    var buf bytes.Buffer
+    tw := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+    for _, fc := range results {
+        mark := "✓"
+        switch {
+        case checkFailed(fc.Status, mode):
+            mark = "✗"
+        case fc.Status != CheckOK:
+            mark = "-"
+        }
+        algos := strings.ToUpper(strings.Join(fc.Algos, ","))
+        if algos == "" {
+            algos = "-"
+        }
+        fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%s\n", mark, fc.Name, fc.Status, algos, fc.Error)
+    }
+    tw.Flush()
+    return strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+}
+
+func containsString(list []string, s string) bool {
+    for _, v := range list {
+        if v == s {
+            return true
+        }
+    }
+    return false
+}
+
+func containsDigest(list []Digest, dg Digest) bool {
+    for _, v := range list {
+        if v == dg {
+            return true
+        }
+    }
+    return false
+}
@@ -2,11 +2,18 @@ module github-downloader
 
 go 1.22
 
-require github.com/schollz/progressbar/v3 v3.19.0
+require (
+	github.com/ProtonMail/go-crypto v1.1.6
+	github.com/klauspost/compress v1.17.11
+	github.com/rivo/uniseg v0.4.7
+	github.com/ulikunitz/xz v0.5.12
+	golang.org/x/crypto v0.32.0
+	golang.org/x/term v0.28.0
+	lukechampine.com/blake3 v1.3.0
+)
 
 require (
-	github.com/mitchellh/colorstring v0.0.0-20190213212951-d06e56a500db // indirect
-	github.com/rivo/uniseg v0.4.7 // indirect
+	github.com/cloudflare/circl v1.3.7 // indirect
+	github.com/klauspost/cpuid/v2 v2.0.9 // indirect
 	golang.org/x/sys v0.29.0 // indirect
-	golang.org/x/term v0.28.0 // indirect
 )
@@ -0,0 +1,41 @@
+package main
+
+import (
+    "context"
+    "os"
+    "os/signal"
+    "syscall"
+
+    "github-downloader/logger"
+)
+
+// interruptContext 返回收到 SIGINT 或 SIGTERM 时取消的 context
+// 第一次收到信号时停止开始新的下载，正在进行的下载保留已下载的部分以便下次继续，然后照常写入汇总；
+// 再次收到信号时立即退出。调用方结束时应调用返回的函数，恢复信号的默认处理
+func interruptContext() (context.Context, func()) {
+    ctx, cancel := context.WithCancel(context.Background())
+    c := make(chan os.Signal, 2)
+    signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+    done := make(chan struct{})
+    go func() {
+        select {
+        case sig := <-c:
+            logger.Warn("收到 %v，停止下载并保存进度，再次按 Ctrl-C 立即退出", sig)
+            cancel()
+        case <-done:
+            return
+        }
+        select {
+        case sig := <-c:
+            logger.Error("再次收到 %v，立即退出", sig)
+            logger.Close()
+            os.Exit(exitInterrupted)
+        case <-done:
+        }
+    }()
+    return ctx, func() {
+        signal.Stop(c)
+        close(done)
+        cancel()
+    }
+}
@@ -6,16 +6,43 @@ import (
     "log"
     "os"
     "path/filepath"
+    "sync"
     "time"
 )
 
 var (
     logFile   *os.File
-    logger    *log.Logger
+    logger    = log.New(consoleWriter{}, "", log.LstdFlags) // Init 之前仅输出到控制台
+    consoleMu sync.Mutex
+    console   io.Writer = os.Stdout
     logDir    string
     logPrefix string
 )
 
+// consoleWriter 将日志写入当前的控制台输出目标，使 SetConsole 在 Init 之后也能生效
+type consoleWriter struct{}
+
+func (consoleWriter) Write(p []byte) (int, error) {
+    consoleMu.Lock()
+    defer consoleMu.Unlock()
+    return console.Write(p)
+}
+
+// SetConsole 设置日志在控制台的输出目标（默认 os.Stdout）
+// 当标准输出用于输出数据（例如 JSON）时，可将日志改为输出到 os.Stderr
+func SetConsole(w io.Writer) {
+    consoleMu.Lock()
+    defer consoleMu.Unlock()
+    console = w
+}
+
+// Console 返回日志在控制台的输出目标
+func Console() io.Writer {
+    consoleMu.Lock()
+    defer consoleMu.Unlock()
+    return console
+}
+
 // Init 初始化日志系统
 func Init(dir string, prefix string) error {
     logDir = dir
@@ -35,7 +62,7 @@ func Init(dir string, prefix string) error {
     logFile = f
 
     // 同时输出到文件和控制台
-    multiWriter := io.MultiWriter(os.Stdout, logFile)
+    multiWriter := io.MultiWriter(consoleWriter{}, logFile)
     logger = log.New(multiWriter, "", log.LstdFlags)
 
     return nil
@@ -5,7 +5,7 @@ import (
     "fmt"
     "os"
     "path/filepath"
-    "sync"
+    "runtime"
 
     "github-downloader/config"
     "github-downloader/downloader"
@@ -13,210 +13,239 @@ import (
 )
 
 const (
+    appName          = "github_download" // 与 Makefile 中的 APP_NAME 一致，发布的资产名为 <appName>-<os>-<arch>
     logRetentionDays = 7
     defaultLogPrefix = "download"
 )
 
+// 构建信息，发布时通过 -ldflags "-X main.version=..." 写入（见 Makefile）
+var (
+    version  = "dev"
+    selfRepo = "zhangxuan1340/git-downloader" // self-update 使用的仓库
+)
+
+// 退出码
+const (
+    exitOK          = 0   // 全部成功
+    exitError       = 1   // 全部失败或运行错误
+    exitUsage       = 2   // 命令行参数错误
+    exitPartial     = 3   // 部分仓库失败
+    exitInterrupted = 130 // 被 SIGINT 或 SIGTERM 中断
+)
+
+// command 表示一个子命令
+type command struct {
+    name    string
+    summary string
+    run     func(app *app, args []string) int
+}
+
+// commands 按帮助信息中的显示顺序排列
+var commands = []*command{
+    {name: "download", summary: "下载单个仓库的 Release", run: runDownload},
+    {name: "sync", summary: "按配置文件批量下载", run: runSync},
+    {name: "list", summary: "查看仓库的 Release 和资产（不下载）", run: runList},
+    {name: "verify", summary: "校验已下载的文件", run: runVerify},
+    {name: "prune", summary: "按保留策略清理旧版本", run: runPrune},
+    {name: "state", summary: "查看或重建本地状态库", run: runState},
+    {name: "dedupe", summary: "合并下载目录中内容相同的文件", run: runDedupe},
+    {name: "install", summary: "下载并安装仓库的可执行文件", run: runInstall},
+    {name: "uninstall", summary: "删除已安装的可执行文件", run: runUninstall},
+    {name: "switch", summary: "切换已安装可执行文件的版本", run: runSwitch},
+    {name: "proxies", summary: "查看或测试加速代理", run: runProxies},
+    {name: "config", summary: "查看、检查或初始化配置文件", run: runConfig},
+    {name: "serve", summary: "以守护进程方式定时同步", run: runServe},
+    {name: "self-update", summary: "更新本程序到最新版本", run: runSelfUpdate},
+}
+
+// app 保存各子命令共享的默认路径
+type app struct {
+    execDir        string
+    defaultTopDir  string
+    defaultConfig  string
+    defaultProxies string
+    defaultLimits  string
+    defaultLogDir  string
+}
+
 func main() {
     // 获取程序所在目录
     execDir, err := getExecutableDir()
     if err != nil {
         fmt.Fprintf(os.Stderr, "无法获取程序目录: %v\n", err)
-        os.Exit(1)
+        os.Exit(exitError)
     }
 
     // 设置默认路径（相对于程序目录）
-    defaultTopDir    := filepath.Join(execDir, "downloads")
-    defaultConfig    := filepath.Join(execDir, "conf", "repos.conf")
-    defaultProxies   := filepath.Join(execDir, "conf", "proxies.txt")
-    defaultLogDir    := filepath.Join(execDir, "logs")
-
-    // 自定义帮助信息
-    flag.Usage = func() {
-        fmt.Fprintf(os.Stderr, "GitHub/GitLab Release 下载器\n\n")
-        fmt.Fprintf(os.Stderr, "用法:\n  %s [选项]\n  或\n  %s <所有者> <仓库名> [all] [选项]\n  或\n  %s gitlab <所有者> <仓库名> [all] [选项]\n\n", os.Args[0], os.Args[0], os.Args[0])
-        fmt.Fprintf(os.Stderr, "选项:\n")
-        fmt.Fprintf(os.Stderr, "  -top string\n        下载根目录 (默认 \"%s\")\n", defaultTopDir)
-        fmt.Fprintf(os.Stderr, "  -conf string\n        配置文件路径 (默认 \"%s\")\n", defaultConfig)
-        fmt.Fprintf(os.Stderr, "  -proxies string\n        代理列表文件路径 (默认 \"%s\")\n", defaultProxies)
-        fmt.Fprintf(os.Stderr, "  -log string\n        日志目录 (默认 \"%s\")\n", defaultLogDir)
-        fmt.Fprintf(os.Stderr, "  -j int\n        并发数（同时处理的仓库数） (默认 1)\n")
-        fmt.Fprintf(os.Stderr, "  -h\t显示此帮助信息\n\n")
-        fmt.Fprintf(os.Stderr, "示例:\n")
-        fmt.Fprintf(os.Stderr, "  1. 使用默认配置:\n     %s\n", os.Args[0])
-        fmt.Fprintf(os.Stderr, "  2. 指定自定义配置文件:\n     %s -conf /path/to/repos.conf\n", os.Args[0])
-        fmt.Fprintf(os.Stderr, "  3. 指定代理列表文件:\n     %s -proxies /path/to/proxies.txt\n", os.Args[0])
-        fmt.Fprintf(os.Stderr, "  4. 设置并发数为 3:\n     %s -j 3\n", os.Args[0])
-        fmt.Fprintf(os.Stderr, "  5. 查看帮助:\n     %s -h\n", os.Args[0])
-        fmt.Fprintf(os.Stderr, "  6. 下载单个 GitHub 仓库的最新 Release:\n     %s nginx nginx\n", os.Args[0])
-        fmt.Fprintf(os.Stderr, "  7. 下载单个 GitHub 仓库的所有 Release:\n     %s nginx nginx all\n", os.Args[0])
-        fmt.Fprintf(os.Stderr, "  8. 下载单个 GitLab 仓库的最新 Release:\n     %s gitlab ryubing canary\n", os.Args[0])
-        fmt.Fprintf(os.Stderr, "  9. 下载单个 GitLab 仓库的所有 Release:\n     %s gitlab ryubing canary all\n", os.Args[0])
+    a := &app{
+        execDir:        execDir,
+        defaultTopDir:  filepath.Join(execDir, "downloads"),
+        defaultConfig:  filepath.Join(execDir, "conf", "repos.conf"),
+        defaultProxies: filepath.Join(execDir, "conf", "proxies.txt"),
+        defaultLimits:  filepath.Join(execDir, "conf", "limits.conf"),
+        defaultLogDir:  filepath.Join(execDir, "logs"),
     }
 
-    // 命令行参数
-    topDir    := flag.String("top", defaultTopDir, "下载根目录")
-    configFile := flag.String("conf", defaultConfig, "配置文件路径")
-    proxiesFile := flag.String("proxies", defaultProxies, "代理列表文件路径")
-    logDir    := flag.String("log", defaultLogDir, "日志目录")
-    concurrent := flag.Int("j", 1, "并发数（同时处理的仓库数）")
-    help := flag.Bool("h", false, "显示帮助信息")
-    flag.Parse()
-
-    if *help {
-        flag.Usage()
-        os.Exit(0)
-    }
+    os.Exit(a.dispatch(os.Args[1:]))
+}
 
-    // 初始化日志
-    if err := logger.Init(*logDir, defaultLogPrefix); err != nil {
-        fmt.Fprintf(os.Stderr, "初始化日志失败: %v\n", err)
-        os.Exit(1)
+// dispatch 根据第一个参数选择子命令，无法识别时交给旧版命令格式兼容层
+func (a *app) dispatch(args []string) int {
+    if len(args) > 0 {
+        switch args[0] {
+        case "-version", "--version", "version":
+            printVersion()
+            return exitOK
+        case "help", "-h", "-help", "--help":
+            if len(args) > 1 {
+                if cmd := findCommand(args[1]); cmd != nil {
+                    return cmd.run(a, []string{"-h"})
+                }
+            }
+            printUsage()
+            return exitOK
+        }
+        if cmd := findCommand(args[0]); cmd != nil {
+            return cmd.run(a, args[1:])
+        }
     }
-    defer logger.Close()
+    return a.runLegacy(args)
+}
 
-    // 在程序目录下生成示例配置文件（如果不存在）
-    generateExampleConfigs(execDir)
-
-    // 加载代理列表
-    var proxies []string
-    if _, err := os.Stat(*proxiesFile); err == nil {
-        proxies, err = config.LoadProxies(*proxiesFile)
-        if err != nil {
-            logger.Warn("加载代理列表失败: %v，将使用内置默认代理", err)
-        } else {
-            logger.Info("成功加载 %d 个代理", len(proxies))
+func findCommand(name string) *command {
+    for _, cmd := range commands {
+        if cmd.name == name {
+            return cmd
         }
-    } else {
-        logger.Warn("代理列表文件 %s 不存在，将使用内置默认代理", *proxiesFile)
     }
+    return nil
+}
 
-    // 创建下载器（传入代理列表）
-    d := downloader.NewDownloader(*topDir, proxies)
-
-    // 检查是否有位置参数（非标志参数）
-    args := flag.Args()
-    if len(args) >= 2 {
-        // 检查是否是 GitLab 仓库
-        isGitLab := false
-        var owner, repo, gitLabHost string
-        var downloadAll bool
-
-        if args[0] == "gitlab" {
-            // GitLab 仓库格式:
-            // 格式1: gitlab <主机名> <所有者> <仓库名> [all]
-            // 格式2: gitlab <所有者> <仓库名> [all] (默认 git.ryujinx.app)
-            if len(args) >= 4 {
-                // 格式1: 带主机名
-                isGitLab = true
-                gitLabHost = args[1]
-                owner = args[2]
-                repo = args[3]
-                downloadAll = len(args) >= 5 && args[4] == "all"
-            } else if len(args) >= 3 {
-                // 格式2: 无主机名，使用默认值
-                isGitLab = true
-                gitLabHost = "" // 空值，会使用默认值
-                owner = args[1]
-                repo = args[2]
-                downloadAll = len(args) >= 4 && args[3] == "all"
-            }
-        } else {
-            // GitHub 仓库格式: <所有者> <仓库名> [all]
-            owner = args[0]
-            repo = args[1]
-            downloadAll = len(args) >= 3 && args[2] == "all"
-        }
+func printVersion() {
+    fmt.Printf("%s %s (%s %s/%s)\n", appName, version, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+}
 
-        logger.Info("======== 开始下载指定仓库 ========")
-        logger.Info("下载目录: %s", *topDir)
-        logger.Info("代理列表: %s", *proxiesFile)
-        logger.Info("日志目录: %s", *logDir)
-        logger.Info("指定仓库: %s/%s", owner, repo)
-        logger.Info("仓库类型: %s", map[bool]string{true: "GitLab", false: "GitHub"}[isGitLab])
-        logger.Info("下载模式: %s", map[bool]string{true: "所有 Release", false: "最新 Release"}[downloadAll])
-
-        if isGitLab {
-            if downloadAll {
-                if err := d.ProcessGitLabRepoAll(gitLabHost, owner, repo, ""); err != nil {
-                    logger.Error("处理仓库 %s/%s 失败: %v", owner, repo, err)
-                }
-            } else {
-                if err := d.ProcessGitLabRepo(gitLabHost, owner, repo, ""); err != nil {
-                    logger.Error("处理仓库 %s/%s 失败: %v", owner, repo, err)
-                }
-            }
-        } else {
-            if downloadAll {
-                if err := d.ProcessRepoAll(owner, repo, ""); err != nil {
-                    logger.Error("处理仓库 %s/%s 失败: %v", owner, repo, err)
-                }
-            } else {
-                if err := d.ProcessRepo(owner, repo, ""); err != nil {
-                    logger.Error("处理仓库 %s/%s 失败: %v", owner, repo, err)
-                }
+func printUsage() {
+    prog := filepath.Base(os.Args[0])
+    fmt.Fprintf(os.Stderr, "GitHub/GitLab Release 下载器\n\n")
+    fmt.Fprintf(os.Stderr, "用法:\n  %s <命令> [选项] [参数]\n\n", prog)
+    fmt.Fprintf(os.Stderr, "命令:\n")
+    for _, cmd := range commands {
+        fmt.Fprintf(os.Stderr, "  %-12s %s\n", cmd.name, cmd.summary)
+    }
+    fmt.Fprintf(os.Stderr, "\n使用 \"%s help <命令>\" 或 \"%s <命令> -h\" 查看命令的详细选项，\"%s -version\" 查看版本。\n", prog, prog, prog)
+    fmt.Fprintf(os.Stderr, "\n退出码:\n  0 全部成功  1 全部失败或运行错误  2 参数错误  3 部分仓库失败  130 被中断\n")
+    fmt.Fprintf(os.Stderr, "\n旧版格式仍然可用:\n  %s [选项]                         等同于 sync\n  %s [选项] <所有者> <仓库名> [all]  等同于 download\n", prog, prog)
+}
+
+// newFlagSet 创建子命令的参数集，并设置统一格式的帮助信息
+func newFlagSet(name, usage string, examples ...string) *flag.FlagSet {
+    fs := flag.NewFlagSet(name, flag.ContinueOnError)
+    fs.Usage = func() {
+        prog := filepath.Base(os.Args[0])
+        fmt.Fprintf(fs.Output(), "用法:\n  %s %s %s\n\n选项:\n", prog, name, usage)
+        fs.PrintDefaults()
+        if len(examples) > 0 {
+            fmt.Fprintf(fs.Output(), "\n示例:\n")
+            for _, ex := range examples {
+                fmt.Fprintf(fs.Output(), "  %s %s\n", prog, ex)
             }
         }
+    }
+    return fs
+}
 
-        logger.Info("======== 仓库处理完成 ========")
-    } else {
-        // 使用配置文件模式
-        logger.Info("======== 开始批量下载 ========")
-        logger.Info("下载目录: %s", *topDir)
-        logger.Info("配置文件: %s", *configFile)
-        logger.Info("代理列表: %s", *proxiesFile)
-        logger.Info("日志目录: %s", *logDir)
-
-        // 加载仓库配置
-        repos, err := config.LoadRepos(*configFile)
-        if err != nil {
-            if os.IsNotExist(err) {
-                logger.Error("配置文件 %s 不存在", *configFile)
-                logger.Info("请参考程序目录下的 conf/repos.conf.example 文件创建配置文件")
-            } else {
-                logger.Error("加载配置文件失败: %v", err)
+// parseFlags 解析子命令参数，允许选项出现在位置参数之后
+// 第二个返回值为 false 时，调用方应以第一个返回值作为退出码结束
+func parseFlags(fs *flag.FlagSet, args []string) (int, bool) {
+    var positional []string
+    for {
+        if err := fs.Parse(args); err != nil {
+            if err == flag.ErrHelp {
+                return exitOK, false
             }
-            os.Exit(1)
+            return exitUsage, false
         }
-
-        if len(repos) == 0 {
-            logger.Warn("配置文件中没有有效的仓库")
-            return
+        args = fs.Args()
+        if len(args) == 0 {
+            break
         }
+        positional = append(positional, args[0])
+        args = args[1:]
+    }
+    // 以 "--" 开头重新解析，使 fs.Args() 返回收集到的位置参数
+    fs.Parse(append([]string{"--"}, positional...))
+    return exitOK, true
+}
 
-        // 使用并发处理
-        var wg sync.WaitGroup
-        sem := make(chan struct{}, *concurrent)
-
-        for _, repo := range repos {
-            wg.Add(1)
-            sem <- struct{}{} // 占用一个槽位
-            go func(r config.RepoConfig) {
-                defer wg.Done()
-                defer func() { <-sem }() // 释放槽位
-
-                if r.Type == "gitlab" {
-                    if err := d.ProcessGitLabRepo(r.GitLabHost, r.Owner, r.Repo, r.Proxy); err != nil {
-                        logger.Error("处理 GitLab 仓库 %s/%s 失败: %v", r.Owner, r.Repo, err)
-                    }
-                } else {
-                    if err := d.ProcessRepo(r.Owner, r.Repo, r.Proxy); err != nil {
-                        logger.Error("处理 GitHub 仓库 %s/%s 失败: %v", r.Owner, r.Repo, err)
-                    }
-                }
-            }(repo)
-        }
+// usageError 输出参数错误和子命令的帮助信息，返回 exitUsage
+func usageError(fs *flag.FlagSet, err error) int {
+    fmt.Fprintf(os.Stderr, "%v\n\n", err)
+    fs.Usage()
+    return exitUsage
+}
 
-        wg.Wait()
+// commonFlags 是多个子命令共用的路径选项
+type commonFlags struct {
+    topDir      *string
+    configFile  *string
+    proxiesFile *string
+    logDir      *string
+}
 
-        // 清理旧日志
-        logger.Info("======== 清理旧日志 ========")
-        if err := logger.CleanOldLogs(logRetentionDays); err != nil {
-            logger.Error("清理旧日志失败: %v", err)
-        }
+func (a *app) registerCommon(fs *flag.FlagSet) *commonFlags {
+    return &commonFlags{
+        topDir:      fs.String("top", a.defaultTopDir, "下载根目录"),
+        configFile:  fs.String("conf", a.defaultConfig, "配置文件路径"),
+        proxiesFile: fs.String("proxies", a.defaultProxies, "代理列表文件路径"),
+        logDir:      fs.String("log", a.defaultLogDir, "日志目录"),
+    }
+}
+
+// setup 初始化日志、示例配置和代理列表，返回下载器
+// 调用方需要在结束时执行 logger.Close()
+func (a *app) setup(c *commonFlags) (*downloader.Downloader, error) {
+    if err := logger.Init(*c.logDir, defaultLogPrefix); err != nil {
+        return nil, fmt.Errorf("初始化日志失败: %w", err)
+    }
+
+    // 在程序目录下生成示例配置文件（如果不存在）
+    generateExampleConfigs(a.execDir)
+
+    // 创建下载器（传入代理列表）
+    d := downloader.NewDownloader(*c.topDir, loadProxies(*c.proxiesFile))
+
+    // 加载本地状态库，无法读取时从空的状态库开始（可运行 state rebuild 重建）
+    st, err := downloader.LoadState(*c.topDir)
+    if err != nil {
+        logger.Warn("无法读取状态库: %v，将重新建立", err)
+    }
+    d.SetState(st)
+    // 缓存 API 响应，未变化的仓库通过 ETag 条件请求获取（不消耗 GitHub 速率限制）
+    d.SetCacheDir(filepath.Join(*c.topDir, downloader.CacheDir))
+    return d, nil
+}
 
-        logger.Info("======== 所有仓库处理完成 ========")
+// setupConsole 用于不产生任何文件的命令（如 -dry-run）：
+// 不创建日志文件、不生成示例配置，日志输出到标准错误
+func (a *app) setupConsole(c *commonFlags) *downloader.Downloader {
+    logger.SetConsole(os.Stderr)
+    return downloader.NewDownloader(*c.topDir, loadProxies(*c.proxiesFile))
+}
+
+// loadProxies 加载代理列表，失败时返回 nil（使用内置默认代理）
+func loadProxies(path string) []string {
+    if _, err := os.Stat(path); err != nil {
+        logger.Warn("代理列表文件 %s 不存在，将使用内置默认代理", path)
+        return nil
+    }
+    proxies, err := config.LoadProxies(path)
+    if err != nil {
+        logger.Warn("加载代理列表失败: %v，将使用内置默认代理", err)
+        return nil
     }
+    logger.Info("成功加载 %d 个代理", len(proxies))
+    return proxies
 }
 
 // getExecutableDir 返回可执行文件所在的目录
@@ -246,9 +275,19 @@ func generateExampleConfigs(execDir string) {
 #   gitlab <主机名> 所有者 仓库名 [代理]  # GitLab 仓库（使用自定义实例）
 #   所有者 仓库名 [代理]          # 默认 GitHub 仓库
 # 代理是可选的，如果不指定则使用全局代理列表（见 proxies.txt）
+# 行尾可附加 key=value 选项，例如:
+#   include=*.tar.gz,*.zip            只下载匹配的资产
+#   exclude=*.deb                     不下载匹配的资产
+#   platform=linux/amd64,darwin/arm64 只下载指定平台的资产（auto 表示当前平台）
+#   verify=strict                     合并校验文件的严格程度：strict、normal（默认）、lenient
+#   crosscheck=auto                   与源站或另一个代理交叉校验经过代理下载的资产
+#   gpg-key=keys/fzf.asc              用受信任的公钥验证签名（另有 minisign-key、cosign-key）
+#   keep=5                            清理旧版本时保留最新的 5 个（另有 keep-days、keep-major、pin）
+#   bin=*/rg                          install 时归档中可执行文件的路径或文件名
+#   idle-timeout=2m                   超过 2 分钟没有收到数据时更换代理（另有 connect-timeout、tls-timeout、header-timeout、timeout）
 # 示例:
 # # GitHub 仓库示例
-# junegunn fzf
+# junegunn fzf platform=linux/amd64
 # cli cli gh-proxy.com
 # starship starship
 # 
@@ -306,4 +345,32 @@ git.yzuu.com
             logger.Info("已生成示例代理列表: %s（仅供参考）", proxiesExample)
         }
     }
+
+    // 生成 limits.conf.example（复制为 limits.conf 后生效）
+    limitsExample := filepath.Join(confDir, "limits.conf.example")
+    if _, err := os.Stat(limitsExample); os.IsNotExist(err) {
+        content := `# 限速配置文件示例
+# 复制为 limits.conf 后生效，download、sync、serve 启动时读取，
+# 运行中修改后发送 SIGHUP（kill -HUP <pid>）重新读取
+# 格式:
+#   <主机名|*> <速度> [时间段]
+# * 表示所有下载的总速度，主机名为下载地址的主机（经过代理时为代理的域名）
+# 速度单位按 1024 进制，例如 512K、5MiB/s，0 表示不限速
+# 时间段为每天的当地时间，例如 00:00-07:00，可以跨越午夜（22:00-06:00）
+# 同一主机有多条规则时，匹配当前时间段的规则优先于全天规则
+
+# 白天总速度不超过 5MiB/s，夜间不限速
+*                                 5MiB/s
+*                                 0       00:00-07:00
+
+# 单个主机或代理
+objects.githubusercontent.com     2MiB/s
+gh-proxy.com                      1MiB/s  09:00-18:00
+`
+        if err := os.WriteFile(limitsExample, []byte(content), 0644); err != nil {
+            logger.Warn("无法生成示例限速配置文件: %v", err)
+        } else {
+            logger.Info("已生成示例限速配置文件: %s", limitsExample)
+        }
+    }
 }
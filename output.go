@@ -0,0 +1,65 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+    "io"
+    "os"
+
+    "github-downloader/downloader"
+    "github-downloader/logger"
+)
+
+// outputFlags 控制机器可读的事件输出
+type outputFlags struct {
+    format *string
+    file   *string
+    closer io.Closer
+}
+
+func registerOutput(fs *flag.FlagSet) *outputFlags {
+    return &outputFlags{
+        format: fs.String("output", "text", "输出格式: text 或 json（每行一个 JSON 事件，日志改为输出到标准错误）"),
+        file:   fs.String("output-file", "", "JSON 事件写入该文件而不是标准输出"),
+    }
+}
+
+// validate 检查选项取值，需要在解析参数后调用
+func (o *outputFlags) validate() error {
+    if *o.format != "text" && *o.format != "json" {
+        return fmt.Errorf("不支持的输出格式: %s", *o.format)
+    }
+    if *o.file != "" && *o.format != "json" {
+        return fmt.Errorf("-output-file 需要与 -output json 一起使用")
+    }
+    if *o.format == "json" && *o.file == "" {
+        // 标准输出只用于事件流，需要在初始化日志之前切换
+        logger.SetConsole(os.Stderr)
+    }
+    return nil
+}
+
+// attach 为下载器设置事件输出
+func (o *outputFlags) attach(d *downloader.Downloader) error {
+    if *o.format != "json" {
+        return nil
+    }
+    var w io.Writer = os.Stdout
+    if *o.file != "" {
+        f, err := os.OpenFile(*o.file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+        if err != nil {
+            return fmt.Errorf("无法打开事件输出文件: %w", err)
+        }
+        o.closer = f
+        w = f
+    }
+    d.SetEventHandler(downloader.NewJSONEventWriter(w))
+    return nil
+}
+
+// close 关闭事件输出文件
+func (o *outputFlags) close() {
+    if o.closer != nil {
+        o.closer.Close()
+    }
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "sync"
+
+    "github-downloader/config"
+    "github-downloader/downloader"
+)
+
+// planRepos 并发解析每个仓库的同步计划并输出，不下载也不写入任何文件
+func planRepos(d *downloader.Downloader, repos []config.RepoConfig, downloadAll bool, concurrent int) int {
+    if concurrent < 1 {
+        concurrent = 1
+    }
+
+    type result struct {
+        plans []*downloader.ReleasePlan
+        err   error
+    }
+    results := make([]result, len(repos))
+
+    var wg sync.WaitGroup
+    sem := make(chan struct{}, concurrent)
+    for i, repo := range repos {
+        wg.Add(1)
+        sem <- struct{}{}
+        go func(i int, r config.RepoConfig) {
+            defer wg.Done()
+            defer func() { <-sem }()
+            plans, err := d.PlanRepo(context.Background(), repoRef(r), downloadAll, repoOptions(r))
+            results[i] = result{plans, err}
+        }(i, repo)
+    }
+    wg.Wait()
+
+    // 按配置文件顺序输出
+    var newVersions, downloads, replaces, failed int
+    var bytes int64
+    fmt.Println("======== 同步计划（未执行） ========")
+    for i, r := range repos {
+        res := results[i]
+        if res.err != nil {
+            failed++
+            fmt.Printf("! %s/%s: 获取 Release 失败: %v\n", r.Owner, r.Repo, res.err)
+            continue
+        }
+        if len(res.plans) == 0 {
+            fmt.Printf("  %s/%s: 没有可用的 Release\n", r.Owner, r.Repo)
+            continue
+        }
+        for _, p := range res.plans {
+            printReleasePlan(p)
+            if p.NewVersion {
+                newVersions++
+            }
+            downloads += p.Count(downloader.PlanDownload)
+            replaces += p.Count(downloader.PlanReplace)
+            bytes += p.Bytes()
+        }
+    }
+    fmt.Println("========================================")
+    fmt.Printf("新版本: %d 个\n", newVersions)
+    fmt.Printf("待下载资产: %d 个\n", downloads)
+    fmt.Printf("将被替换的文件: %d 个\n", replaces)
+    fmt.Printf("需传输: %s\n", downloader.ByteCountIEC(bytes))
+    if failed > 0 {
+        fmt.Printf("无法解析的仓库: %d 个\n", failed)
+        return exitError
+    }
+    return exitOK
+}
+
+func printReleasePlan(p *downloader.ReleasePlan) {
+    state := "[已有版本]"
+    if p.NewVersion {
+        state = "[新版本]"
+    }
+    fmt.Printf("%s  %s  %s  -> %s\n", p.Repo, p.Tag, state, p.Dir)
+    for _, a := range p.Assets {
+        switch a.Action {
+        case downloader.PlanDownload:
+            fmt.Printf("  + %s (%s)\n", a.Name, downloader.ByteCountIEC(a.Size))
+        case downloader.PlanReplace:
+            fmt.Printf("  ~ %s (%s) 替换: %s\n", a.Name, downloader.ByteCountIEC(a.Size), a.Reason)
+        }
+    }
+    if n := p.Count(downloader.PlanSkip); n > 0 {
+        fmt.Printf("  = %d 个资产已是最新\n", n)
+    }
+    if p.Excluded > 0 {
+        fmt.Printf("  - %d 个资产被筛选规则排除\n", p.Excluded)
+    }
+}
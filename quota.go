@@ -0,0 +1,60 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+    "math"
+    "regexp"
+    "strconv"
+    "strings"
+)
+
+// quotaFlags 是下载前空间检查的命令行选项
+type quotaFlags struct {
+    quota   *string
+    reserve *string
+}
+
+func registerQuota(fs *flag.FlagSet) *quotaFlags {
+    return &quotaFlags{
+        quota:   fs.String("quota", "", "下载目录总大小上限，例如 50G；超出时拒绝下载"),
+        reserve: fs.String("reserve", "256M", "磁盘至少保留的可用空间，下载前检查"),
+    }
+}
+
+// parse 返回配额和保留空间的字节数
+func (f *quotaFlags) parse() (int64, int64, error) {
+    limit, err := parseSize(*f.quota)
+    if err != nil {
+        return 0, 0, fmt.Errorf("-quota: %v", err)
+    }
+    reserve, err := parseSize(*f.reserve)
+    if err != nil {
+        return 0, 0, fmt.Errorf("-reserve: %v", err)
+    }
+    return limit, reserve, nil
+}
+
+// sizePattern 匹配大小：数字，然后是可选的单位 K、M、G、T、P，可选的 i，可选的 B（不区分大小写）
+var sizePattern = regexp.MustCompile(`(?i)^([0-9]+(?:\.[0-9]*)?|\.[0-9]+) *(?:([KMGTP])(I?))?(B?)$`)
+
+// parseSize 解析带单位的大小，例如 512M、1.5G、2TiB、100B；单位按 1024 进制，省略时为字节，空字符串为 0
+func parseSize(s string) (int64, error) {
+    s = strings.TrimSpace(s)
+    if s == "" {
+        return 0, nil
+    }
+    m := sizePattern.FindStringSubmatch(s)
+    if m == nil {
+        return 0, fmt.Errorf("无效的大小 %q，例如 512M、50G", s)
+    }
+    unit := int64(1)
+    if m[2] != "" {
+        unit <<= 10 * (strings.IndexByte("KMGTP", strings.ToUpper(m[2])[0]) + 1)
+    }
+    v, err := strconv.ParseFloat(m[1], 64)
+    if err != nil || v*float64(unit) >= math.MaxInt64 {
+        return 0, fmt.Errorf("无效的大小 %q，例如 512M、50G", s)
+    }
+    return int64(v * float64(unit)), nil
+}
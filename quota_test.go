@@ -0,0 +1,45 @@
+package main
+
+import "testing"
+
+func TestParseSize(t *testing.T) {
+    tests := []struct {
+        in      string
+        want    int64
+        wantErr bool
+    }{
+        {"", 0, false},
+        {"0", 0, false},
+        {"100", 100, false},
+        {"100B", 100, false},
+        {"512K", 512 << 10, false},
+        {"512k", 512 << 10, false},
+        {"512KB", 512 << 10, false},
+        {"512KiB", 512 << 10, false},
+        {"512Ki", 512 << 10, false},
+        {"1.5G", 3 << 29, false},
+        {"2TiB", 2 << 40, false},
+        {"1P", 1 << 50, false},
+        {"10 MiB", 10 << 20, false},
+        {" 10M ", 10 << 20, false},
+        {".5M", 1 << 19, false},
+        {"10BI", 0, true},
+        {"5GIIB", 0, true},
+        {"7BBB", 0, true},
+        {"10iB", 0, true},
+        {"10I", 0, true},
+        {"10MB/s", 0, true},
+        {"10X", 0, true},
+        {"-1G", 0, true},
+        {"1e3", 0, true},
+        {"Inf", 0, true},
+        {"G", 0, true},
+        {"9999999P", 0, true},
+    }
+    for _, tt := range tests {
+        got, err := parseSize(tt.in)
+        if (err != nil) != tt.wantErr || got != tt.want {
+            t.Errorf("parseSize(%q) = %d, %v, want %d, wantErr %v", tt.in, got, err, tt.want, tt.wantErr)
+        }
+    }
+}
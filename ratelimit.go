@@ -0,0 +1,109 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+    "os"
+    "os/signal"
+    "strings"
+    "syscall"
+
+    "github-downloader/config"
+    "github-downloader/downloader"
+    "github-downloader/logger"
+)
+
+// rateFlags 是限速的命令行选项
+type rateFlags struct {
+    limitRate  *string
+    limitsFile *string
+}
+
+func (a *app) registerRateLimit(fs *flag.FlagSet) *rateFlags {
+    return &rateFlags{
+        limitRate:  fs.String("limit-rate", "", "所有下载的总速度上限，例如 5MiB/s，0 表示不限速（覆盖限速配置文件中 * 的全天速度）"),
+        limitsFile: fs.String("limits", a.defaultLimits, "限速配置文件路径，按主机和时间段限速，文件不存在时忽略"),
+    }
+}
+
+// load 读取限速配置文件，-limit-rate 指定的总速度放在最后，优先于文件中的全天规则
+func (f *rateFlags) load() ([]downloader.RateRule, error) {
+    lines, err := config.LoadRateLimits(*f.limitsFile)
+    if err != nil && !os.IsNotExist(err) {
+        return nil, err
+    }
+    var rules []downloader.RateRule
+    for _, l := range lines {
+        rule := downloader.RateRule{Host: l.Host}
+        if rule.Rate, err = parseRate(l.Rate); err == nil && l.Window != "" {
+            rule.Window, err = downloader.ParseTimeWindow(l.Window)
+        }
+        if err != nil {
+            return nil, fmt.Errorf("%s 第 %d 行: %v", *f.limitsFile, l.Line, err)
+        }
+        rules = append(rules, rule)
+    }
+    if *f.limitRate != "" {
+        rate, err := parseRate(*f.limitRate)
+        if err != nil {
+            return nil, fmt.Errorf("-limit-rate: %v", err)
+        }
+        rules = append(rules, downloader.RateRule{Host: downloader.AllHosts, Rate: rate})
+    }
+    return rules, nil
+}
+
+// apply 设置下载器的限速规则；收到 SIGHUP 时重新读取限速配置文件，无需重启即可调整速度
+func (f *rateFlags) apply(d *downloader.Downloader, rules []downloader.RateRule) {
+    d.SetRateLimits(rules)
+    logRateRules(rules)
+
+    c := make(chan os.Signal, 1)
+    signal.Notify(c, syscall.SIGHUP)
+    go func() {
+        for range c {
+            rules, err := f.load()
+            if err != nil {
+                logger.Error("重新读取限速配置失败: %v", err)
+                continue
+            }
+            d.SetRateLimits(rules)
+            logger.Info("收到 SIGHUP，已重新读取限速配置")
+            logRateRules(rules)
+        }
+    }()
+}
+
+func logRateRules(rules []downloader.RateRule) {
+    for _, r := range rules {
+        host := r.Host
+        if host == downloader.AllHosts {
+            host = "总速度"
+        }
+        when := "全天"
+        if r.Window != nil {
+            when = r.Window.String()
+        }
+        logger.Info("限速: %s %s（%s）", host, formatRate(r.Rate), when)
+    }
+}
+
+// parseRate 解析速度，例如 5MiB/s、512K、0（不限速）；单位按 1024 进制
+func parseRate(s string) (int64, error) {
+    v := strings.TrimSpace(s)
+    if n := len(v); n >= 2 && strings.EqualFold(v[n-2:], "/s") {
+        v = v[:n-2]
+    }
+    rate, err := parseSize(v)
+    if err != nil || v == "" {
+        return 0, fmt.Errorf("无效的速度 %q，例如 5MiB/s、512K", s)
+    }
+    return rate, nil
+}
+
+func formatRate(rate int64) string {
+    if rate <= 0 {
+        return "不限速"
+    }
+    return downloader.ByteCountIEC(rate) + "/s"
+}
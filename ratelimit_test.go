@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+func TestParseRate(t *testing.T) {
+    tests := []struct {
+        in      string
+        want    int64
+        wantErr bool
+    }{
+        {"0", 0, false},
+        {"1024", 1024, false},
+        {"512K", 512 << 10, false},
+        {"512K/s", 512 << 10, false},
+        {"5MiB/s", 5 << 20, false},
+        {"5mib/S", 5 << 20, false},
+        {"1.5MB/s", 3 << 19, false},
+        {" 2G/s ", 2 << 30, false},
+        {"", 0, true},
+        {"/s", 0, true},
+        {"  ", 0, true},
+        {"5MiB/min", 0, true},
+        {"5MiB/s/s", 0, true},
+        {"-1M/s", 0, true},
+        {"fast", 0, true},
+    }
+    for _, tt := range tests {
+        got, err := parseRate(tt.in)
+        if (err != nil) != tt.wantErr || got != tt.want {
+            t.Errorf("parseRate(%q) = %d, %v, want %d, wantErr %v", tt.in, got, err, tt.want, tt.wantErr)
+        }
+    }
+}
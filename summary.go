@@ -0,0 +1,86 @@
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "os"
+    "sort"
+
+    "github-downloader/downloader"
+    "github-downloader/logger"
+)
+
+// finishRun 输出运行汇总，按需写入 JSON 文件，并返回对应的退出码：
+//   0 所有仓库成功或已是最新
+//   3 部分仓库失败
+//   1 所有仓库均失败
+//   130 ctx 已被取消（收到中断信号）
+func finishRun(ctx context.Context, d *downloader.Downloader, summaryFile string) int {
+    r := d.Summary().Report()
+    printSummary(r)
+    d.EmitRunSummary(r)
+    if summaryFile != "" {
+        if err := writeSummary(summaryFile, r); err != nil {
+            logger.Error("写入运行汇总失败: %v", err)
+        } else {
+            logger.Info("运行汇总已写入: %s", summaryFile)
+        }
+    }
+    if ctx.Err() != nil {
+        logger.Warn("运行已中断，未完成的下载已保留，再次运行时继续")
+        return exitInterrupted
+    }
+    return exitCode(r)
+}
+
+func exitCode(r downloader.SummaryReport) int {
+    switch {
+    case r.ReposFail == 0:
+        return exitOK
+    case r.ReposFail == len(r.Repos):
+        return exitError
+    default:
+        return exitPartial
+    }
+}
+
+func printSummary(r downloader.SummaryReport) {
+    logger.Info("======== 运行汇总 ========")
+    logger.Info("仓库: 成功 %d，已是最新 %d，失败 %d", r.ReposOK, r.ReposSame, r.ReposFail)
+    logger.Info("资产: 下载 %d，跳过 %d，失败 %d", r.Downloaded, r.Skipped, r.Failed)
+    if r.Quarantined > 0 {
+        logger.Error("🚨 %d 个资产交叉校验不一致，已隔离到下载目录的 .quarantine 目录", r.Quarantined)
+    }
+    logger.Info("传输: %s，耗时 %.1f 秒", downloader.ByteCountIEC(r.Bytes), r.Duration)
+
+    proxies := make([]string, 0, len(r.Proxies))
+    for p := range r.Proxies {
+        proxies = append(proxies, p)
+    }
+    sort.Strings(proxies)
+    for _, p := range proxies {
+        logger.Info("代理 %s: %d 个文件", p, r.Proxies[p])
+    }
+    for _, rs := range r.Repos {
+        if rs.Status == downloader.RepoFailed {
+            if rs.Error != "" {
+                logger.Error("失败: %s: %s", rs.Repo, rs.Error)
+            } else {
+                logger.Error("失败: %s（%d 个资产下载失败，%d 个版本校验失败）", rs.Repo, rs.Failed, rs.FailedRels)
+            }
+        }
+    }
+}
+
+// writeSummary 将汇总写入 JSON 文件（先写临时文件再重命名）
+func writeSummary(path string, r downloader.SummaryReport) error {
+    data, err := json.MarshalIndent(r, "", "  ")
+    if err != nil {
+        return err
+    }
+    tmp := path + ".tmp"
+    if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+        return err
+    }
+    return os.Rename(tmp, path)
+}
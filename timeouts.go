@@ -0,0 +1,51 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+    "time"
+
+    "github-downloader/downloader"
+)
+
+// timeoutFlags 是下载超时的命令行选项，仓库配置中的同名选项优先
+type timeoutFlags struct {
+    connect *time.Duration
+    tls     *time.Duration
+    header  *time.Duration
+    idle    *time.Duration
+    total   *time.Duration
+}
+
+func registerTimeouts(fs *flag.FlagSet) *timeoutFlags {
+    def := downloader.DefaultTimeouts
+    return &timeoutFlags{
+        connect: fs.Duration("connect-timeout", def.Connect, "建立连接的超时"),
+        tls:     fs.Duration("tls-timeout", def.TLS, "TLS 握手的超时"),
+        header:  fs.Duration("header-timeout", def.Header, "发送请求后等待响应头的超时"),
+        idle:    fs.Duration("idle-timeout", def.Idle, "下载停滞的超时：超过该时间没有收到数据时放弃本次下载，经过代理时更换代理"),
+        total:   fs.Duration("timeout", def.Total, "单次下载一个文件的最长时间，0 表示不限制"),
+    }
+}
+
+// validate 检查选项取值
+func (f *timeoutFlags) validate() error {
+    if *f.connect <= 0 || *f.tls <= 0 || *f.header <= 0 || *f.idle <= 0 {
+        return fmt.Errorf("-connect-timeout、-tls-timeout、-header-timeout 和 -idle-timeout 必须大于 0")
+    }
+    if *f.total < 0 {
+        return fmt.Errorf("-timeout 不能为负数")
+    }
+    return nil
+}
+
+// apply 设置下载器的全局超时
+func (f *timeoutFlags) apply(d *downloader.Downloader) {
+    d.SetTimeouts(downloader.Timeouts{
+        Connect: *f.connect,
+        TLS:     *f.tls,
+        Header:  *f.header,
+        Idle:    *f.idle,
+        Total:   *f.total,
+    })
+}
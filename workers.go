@@ -0,0 +1,39 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+
+    "github-downloader/downloader"
+)
+
+// workerFlags 是下载连接数的命令行选项，对所有仓库和版本的下载统一生效
+type workerFlags struct {
+    workers  *int
+    perHost  *int
+    perProxy *int
+}
+
+func registerWorkers(fs *flag.FlagSet) *workerFlags {
+    return &workerFlags{
+        workers:  fs.Int("workers", 4, "同时进行的下载总数（所有仓库、版本和资产共享），1 表示逐个下载"),
+        perHost:  fs.Int("per-host", 4, "直接下载时每个主机同时进行的下载数，0 表示不限制"),
+        perProxy: fs.Int("per-proxy", 2, "每个加速代理同时进行的下载数，0 表示不限制"),
+    }
+}
+
+// validate 检查选项取值
+func (f *workerFlags) validate() error {
+    if *f.workers < 1 {
+        return fmt.Errorf("-workers 至少为 1")
+    }
+    if *f.perHost < 0 || *f.perProxy < 0 {
+        return fmt.Errorf("-per-host 和 -per-proxy 不能为负数")
+    }
+    return nil
+}
+
+// apply 设置下载器的连接数限制
+func (f *workerFlags) apply(d *downloader.Downloader) {
+    d.SetWorkers(*f.workers, *f.perHost, *f.perProxy)
+}